@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"alertengine/engine"
+	"alertengine/rule"
+	"alertengine/web"
+
+	"go.uber.org/zap"
+)
+
+// runBacktest 回测规则在历史时间段内产生的告警事件
+func runBacktest(args []string) int {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s backtest [flags]\n\nReplay a rule over historical data and print the alert events it would have generated.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "config.yml", "Configuration file path")
+	ruleFile := fs.String("rule", "", "Rule file in the gateway JSON format")
+	expr := fs.String("expr", "", "Alert expression, used when -rule is not set")
+	forDuration := fs.String("for", "", "Pending duration of the rule, used when -rule is not set")
+	promID := fs.Int64("prom-id", 0, "Prometheus ID, overrides prom_id of the rule file")
+	promURL := fs.String("prom-url", "", "Prometheus URL, fetched from the gateway when not set")
+	start := fs.String("start", "", "Start time in RFC3339 format")
+	end := fs.String("end", "", "End time in RFC3339 format, defaults to now")
+	since := fs.Duration("since", 24*time.Hour, "Backtest window ending at -end, used when -start is not set")
+	step := fs.Duration("step", 0, "Evaluation step, defaults to evaluation_interval")
+	output := fs.String("output", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	if err := backtest(backtestOptions{
+		configFile:  *configFile,
+		configSet:   isFlagSet(fs, "config"),
+		ruleFile:    *ruleFile,
+		expr:        *expr,
+		forDuration: *forDuration,
+		promID:      *promID,
+		promURL:     *promURL,
+		start:       *start,
+		end:         *end,
+		since:       *since,
+		step:        *step,
+		output:      *output,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Backtest failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type backtestOptions struct {
+	configFile  string
+	configSet   bool
+	ruleFile    string
+	expr        string
+	forDuration string
+	promID      int64
+	promURL     string
+	start       string
+	end         string
+	since       time.Duration
+	step        time.Duration
+	output      string
+}
+
+func backtest(opts backtestOptions) error {
+	if opts.output != "text" && opts.output != "json" {
+		return fmt.Errorf("unknown output format %q", opts.output)
+	}
+
+	cfg, err := loadConfig(opts.configFile, opts.configSet)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	r := rule.Rule{Expr: opts.expr, For: opts.forDuration}
+	if opts.ruleFile != "" {
+		data, err := os.ReadFile(opts.ruleFile)
+		if err != nil {
+			return fmt.Errorf("failed to read rule file: %w", err)
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("failed to parse rule file: %w", err)
+		}
+	}
+	if opts.promID != 0 {
+		r.PromID = opts.promID
+	}
+
+	endTime := time.Now()
+	if opts.end != "" {
+		if endTime, err = time.Parse(time.RFC3339, opts.end); err != nil {
+			return fmt.Errorf("invalid end time: %w", err)
+		}
+	}
+	startTime := endTime.Add(-opts.since)
+	if opts.start != "" {
+		if startTime, err = time.Parse(time.RFC3339, opts.start); err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	logger := zap.NewNop()
+	prom := rule.Prom{ID: r.PromID, URL: opts.promURL}
+	if prom.URL == "" {
+		proms, err := engine.FetchProms(ctx, cfg, logger)
+		if err != nil {
+			return fmt.Errorf("failed to fetch proms: %w", err)
+		}
+		for _, p := range proms {
+			if p.ID == r.PromID {
+				prom = p
+				break
+			}
+		}
+		if prom.URL == "" {
+			return fmt.Errorf("prom %d not found in gateway, use -prom-url", r.PromID)
+		}
+	}
+
+	result, err := engine.BacktestRule(ctx, prom, cfg, r, startTime, endTime, opts.step, logger)
+	if err != nil {
+		return err
+	}
+
+	if opts.output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(web.NewBacktestResult(result))
+	}
+
+	fmt.Printf("Query: %s\n", result.Expr)
+	fmt.Printf("Range: %s - %s, step %s\n",
+		result.Start.Format(time.RFC3339), result.End.Format(time.RFC3339), result.Step)
+	fmt.Printf("Fired %d times, firing for %s\n\n", result.FiringCount, result.FiringDuration)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSTATE\tVALUE\tLABELS")
+	for _, e := range result.Events {
+		state := e.State
+		if e.Dropped {
+			state += " (dropped)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%g\t%s\n", e.Time.Format(time.RFC3339), state, e.Value, e.Labels)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"alertengine/config"
+	"alertengine/engine"
+)
+
+// runCheck 执行 check 子命令, 任一文件检查失败时返回非零退出码
+func runCheck(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s check config <config-file>...\n       %s check rules <rule-file>...\n", os.Args[0], os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	var check func(string) bool
+	switch args[0] {
+	case "config":
+		check = checkConfig
+	case "rules":
+		check = checkRules
+	default:
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("check "+args[0], flag.ExitOnError)
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		usage()
+		return 2
+	}
+
+	failed := false
+	for _, f := range fs.Args() {
+		if !check(f) {
+			failed = true
+		}
+		fmt.Println()
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// checkConfig 校验配置文件并检查运行环境
+func checkConfig(filename string) bool {
+	fmt.Println("Checking", filename)
+
+	cfg, err := loadConfig(filename, true)
+	if err != nil {
+		printFailed(err)
+		return false
+	}
+
+	var errs []error
+	if err := cfg.Validate(); err != nil {
+		var verrs config.ValidationErrors
+		if errors.As(err, &verrs) {
+			errs = append(errs, verrs...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.Check()...)
+	if len(errs) > 0 {
+		printFailed(errs...)
+		return false
+	}
+
+	fmt.Printf("  SUCCESS: %s is valid\n", filename)
+	return true
+}
+
+// checkRules 离线校验规则文件
+func checkRules(filename string) bool {
+	fmt.Println("Checking", filename)
+
+	n, errs := engine.CheckRuleFile(filename)
+	if len(errs) > 0 {
+		printFailed(errs...)
+		return false
+	}
+
+	fmt.Printf("  SUCCESS: %d rules found\n", n)
+	return true
+}
+
+func printFailed(errs ...error) {
+	fmt.Fprintln(os.Stderr, "  FAILED:")
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "   ", err)
+	}
+}
+
+// runConfig 执行 config 子命令
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config show [-config <file>]\n", os.Args[0])
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	configFile := fs.String("config", "config.yml", "Configuration file path")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig(*configFile, isFlagSet(fs, "config"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+		return 1
+	}
+
+	// 展示各类接口实际使用的监听地址
+	cfg.Web.ListenAddresses = cfg.ListenAddresses()
+
+	// 密钥和密码显示为 <secret>
+	fmt.Print(cfg)
+	return 0
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"alertengine/rule"
+	"alertengine/web"
+)
+
+// runHistory 执行 history 子命令, 通过运行中告警引擎的HTTP接口查看、比较和回滚规则版本
+func runHistory(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history list -prom <id> [flags]\n       %s history diff -prom <id> -from <version> [-to <version>] [flags]\n       %s history rollback -prom <id> -version <version> [-reason <reason>] [flags]\n", os.Args[0], os.Args[0], os.Args[0])
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("history "+args[0], flag.ExitOnError)
+	addr := fs.String("url", "http://localhost:8080", "Alert engine address, credentials for basic auth can be set in the URL")
+	promID := fs.Int64("prom", 0, "Prometheus datasource ID")
+
+	var run func(*historyClient) error
+	switch args[0] {
+	case "list":
+		limit := fs.Int("limit", 20, "Maximum number of versions to list, 0 for all")
+		run = func(c *historyClient) error { return c.list(*promID, *limit) }
+	case "diff":
+		from := fs.String("from", "", "Version to compare from")
+		to := fs.String("to", "", "Version to compare to, defaults to the current rules")
+		run = func(c *historyClient) error {
+			if *from == "" {
+				return errors.New("-from is required")
+			}
+			return c.diff(*promID, *from, *to)
+		}
+	case "rollback":
+		version := fs.Int64("version", 0, "Version to roll back to")
+		reason := fs.String("reason", "", "Reason for the rollback")
+		run = func(c *historyClient) error {
+			if *version <= 0 {
+				return errors.New("-version is required")
+			}
+			return c.rollback(*promID, *version, *reason)
+		}
+	default:
+		usage()
+		return 2
+	}
+
+	fs.Parse(args[1:])
+	if *promID == 0 {
+		fmt.Fprintln(os.Stderr, "-prom is required")
+		return 2
+	}
+
+	c := &historyClient{
+		url:    strings.TrimSuffix(*addr, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if err := run(c); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// historyClient 调用告警引擎的历史版本接口
+type historyClient struct {
+	url    string
+	client *http.Client
+}
+
+func (c *historyClient) list(promID int64, limit int) error {
+	var versions []web.RuleVersion
+	path := fmt.Sprintf("/api/v1/history/%d?limit=%d", promID, limit)
+	if err := c.do(http.MethodGet, path, nil, &versions); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tCREATED\tRULES\tSOURCE\tHASH\tREASON")
+	for _, v := range versions {
+		version := strconv.FormatInt(v.Version, 10)
+		if v.Pinned {
+			version += "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%.12s\t%s\n", version, v.CreatedAt.Format(time.RFC3339), v.RuleCount, v.Source, v.Hash, v.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Pinned {
+			fmt.Printf("\n* version %d is pinned until the gateway rules change\n", v.Version)
+		}
+	}
+	return nil
+}
+
+func (c *historyClient) diff(promID int64, from, to string) error {
+	var d web.RuleDiff
+	q := url.Values{"from": {from}, "to": {to}}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/history/%d/rules/diff?%s", promID, q.Encode()), nil, &d); err != nil {
+		return err
+	}
+
+	if to == "" {
+		to = "current"
+	}
+	fmt.Printf("Comparing version %s with %s\n", from, to)
+	if len(d.Changes) == 0 {
+		fmt.Println("  no rule changes")
+		return nil
+	}
+	for _, ch := range d.Changes {
+		switch ch.Type {
+		case "added":
+			fmt.Printf("+ rule %d\n", ch.ID)
+			printRule("    ", ch.New)
+		case "removed":
+			fmt.Printf("- rule %d\n", ch.ID)
+			printRule("    ", ch.Old)
+		default:
+			fmt.Printf("~ rule %d (%s)\n", ch.ID, strings.Join(ch.Fields, ", "))
+			for _, f := range ch.Fields {
+				fmt.Printf("    %s:\n      - %s\n      + %s\n", f, ruleField(ch.Old, f), ruleField(ch.New, f))
+			}
+		}
+	}
+	return nil
+}
+
+func (c *historyClient) rollback(promID, version int64, reason string) error {
+	body, err := json.Marshal(web.RollbackRequest{Version: version, Reason: reason})
+	if err != nil {
+		return err
+	}
+
+	var res web.RollbackResult
+	if err := c.do(http.MethodPost, fmt.Sprintf("/api/v1/history/%d/rollback", promID), body, &res); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rolled back prom %d to version %d, pinned as version %d\n", promID, version, res.Version.Version)
+	fmt.Printf("  added: %d, removed: %d, modified: %d\n", len(res.Added), len(res.Removed), len(res.Modified))
+	fmt.Println("  the version stays pinned until the gateway rules change")
+	return nil
+}
+
+// do 发送请求并解析接口响应中的 data 字段
+func (c *historyClient) do(method, path string, body []byte, v interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("unexpected response (status %d): %.200s", resp.StatusCode, data)
+	}
+	if res.Status != "success" {
+		return fmt.Errorf("%s (status %d)", res.Error, resp.StatusCode)
+	}
+	return json.Unmarshal(res.Data, v)
+}
+
+func printRule(indent string, r *rule.Rule) {
+	if r == nil {
+		return
+	}
+	for _, f := range []string{"expr", "for", "labels", "summary", "description"} {
+		if v := ruleField(r, f); v != "" && v != "{}" {
+			fmt.Printf("%s%s: %s\n", indent, f, v)
+		}
+	}
+}
+
+func ruleField(r *rule.Rule, field string) string {
+	switch field {
+	case "expr":
+		return r.Expr
+	case "for":
+		return r.For
+	case "labels":
+		return r.Labels.String()
+	case "summary":
+		return r.Summary
+	case "description":
+		return r.Description
+	}
+	return ""
+}
@@ -4,20 +4,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"alertengine/config"
 	"alertengine/engine"
 	"alertengine/rule"
+	"alertengine/silence"
+	"alertengine/web"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -31,6 +31,21 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backtest":
+			os.Exit(runBacktest(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -38,7 +53,7 @@ func main() {
 		os.Exit(0)
 	}
 
-	cfg, err := loadConfig(*configFile)
+	cfg, err := loadConfig(*configFile, isFlagSet(flag.CommandLine, "config"))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
 		os.Exit(1)
@@ -49,7 +64,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger, err := initLogger(cfg.Log)
+	logger, level, err := initLogger(cfg.Log)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 		os.Exit(1)
@@ -72,79 +87,114 @@ func main() {
 		logger.Fatal("failed to create storage", zap.Error(err))
 	}
 
+	// 创建静默存储
+	silencePath := cfg.Silence.DataFile
+	if silencePath == "" {
+		silencePath = filepath.Join(cfg.Storage.RuleDir, "silences.json")
+	}
+	silences, err := silence.NewStore(silencePath, time.Duration(cfg.Silence.Retention), logger)
+	if err != nil {
+		logger.Fatal("failed to create silence store", zap.Error(err))
+	}
+
 	// 创建监控指标
 	metrics := engine.NewMetrics()
+	metrics.ConfigLastReloadSuccessful.Set(1)
+	metrics.ConfigLastReloadSuccessTime.SetToCurrentTime()
 
 	// 创建重载器
-	reloader := engine.NewReloader(cfg, storage, logger, metrics)
-
-	// 启动指标服务器
-	go startMetricsServer(cfg.MetricsPort, logger)
-
-	// 启动健康检查服务器
-	go startHealthServer(reloader, logger)
+	reloader, err := engine.NewReloader(cfg, storage, silences, logger, metrics)
+	if err != nil {
+		logger.Fatal("failed to create reloader", zap.Error(err))
+	}
 
 	// 启动清理任务
 	if cfg.Storage.EnableHistory {
 		go startCleanupTask(storage, logger)
 	}
 
+	// 启动静默清理任务
+	go startSilenceGCTask(silences, logger)
+
 	// 设置信号处理
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	cfgReloader := &configReloader{
+		filename: *configFile,
+		explicit: isFlagSet(flag.CommandLine, "config"),
+		level:    level,
+		reloader: reloader,
+		metrics:  metrics,
+		logger:   logger,
+	}
+
+	// 启动HTTP服务
+	webHandler := web.New(cfg, reloader, cfgReloader.reload, web.NewAPI(reloader, storage, silences, logger), logger)
+	webDone := make(chan struct{})
+	go func() {
+		defer close(webDone)
+		if err := webHandler.Run(ctx); err != nil {
+			logger.Error("web server failed", zap.Error(err))
+		}
+	}()
+
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
-		sig := <-sigChan
-		logger.Info("received signal", zap.String("signal", sig.String()))
-		cancel()
-		reloader.Stop()
+		for sig := range sigChan {
+			logger.Info("received signal", zap.String("signal", sig.String()))
+			if sig == syscall.SIGHUP {
+				if err := cfgReloader.reload(); err != nil {
+					continue
+				}
+				if err := reloader.Reload(ctx); err != nil {
+					logger.Error("failed to reload rules", zap.Error(err))
+				}
+				continue
+			}
+			cancel()
+			reloader.Stop()
+			return
+		}
 	}()
 
 	// 运行重载器
 	reloader.Run()
 	reloader.Loop()
 
+	cancel()
+	<-webDone
+
 	logger.Info("alert engine stopped")
 }
 
-func loadConfig(path string) (*config.Config, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+// loadConfig 加载配置文件, 仅当未显式指定的默认配置文件不存在时使用默认配置
+func loadConfig(path string, explicit bool) (*config.Config, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) && !explicit {
 		return config.DefaultConfig(), nil
 	}
+	return config.LoadFile(path)
+}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	cfg := config.DefaultConfig()
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
-	}
-
-	return cfg, nil
+// isFlagSet 判断参数是否在命令行中显式指定
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
-func initLogger(cfg config.LogConfig) (*zap.Logger, error) {
-	var level zapcore.Level
-	switch cfg.Level {
-	case "debug":
-		level = zapcore.DebugLevel
-	case "info":
-		level = zapcore.InfoLevel
-	case "warn":
-		level = zapcore.WarnLevel
-	case "error":
-		level = zapcore.ErrorLevel
-	default:
-		level = zapcore.InfoLevel
-	}
+// initLogger 创建日志记录器, 返回的日志级别可在配置重载时调整
+func initLogger(cfg config.LogConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevelAt(logLevel(cfg.Level))
 
 	zapConfig := zap.Config{
-		Level:            zap.NewAtomicLevelAt(level),
+		Level:            level,
 		Development:      false,
 		Encoding:         cfg.Format,
 		EncoderConfig:    zap.NewProductionEncoderConfig(),
@@ -152,43 +202,23 @@ func initLogger(cfg config.LogConfig) (*zap.Logger, error) {
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
-	return zapConfig.Build()
+	logger, err := zapConfig.Build()
+	return logger, level, err
 }
 
-func startMetricsServer(port int, logger *zap.Logger) {
-	http.Handle("/metrics", promhttp.Handler())
-
-	addr := fmt.Sprintf(":%d", port)
-	logger.Info("starting metrics server", zap.String("addr", addr))
-
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		logger.Error("metrics server failed", zap.Error(err))
-	}
-}
-
-func startHealthServer(reloader *engine.Reloader, logger *zap.Logger) {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-		if reloader.GetManagerCount() > 0 {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Ready"))
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("Not Ready"))
-		}
-	})
-
-	addr := ":8080"
-	logger.Info("starting health server", zap.String("addr", addr))
-
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		logger.Error("health server failed", zap.Error(err))
+// logLevel 解析日志级别, 未知级别使用 info
+func logLevel(s string) zapcore.Level {
+	switch s {
+	case "debug":
+		return zapcore.DebugLevel
+	case "info":
+		return zapcore.InfoLevel
+	case "warn":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
 	}
 }
 
@@ -205,3 +235,19 @@ func startCleanupTask(storage *rule.Storage, logger *zap.Logger) {
 		}
 	}
 }
+
+func startSilenceGCTask(silences *silence.Store, logger *zap.Logger) {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		removed, err := silences.GC()
+		if err != nil {
+			logger.Error("silence gc failed", zap.Error(err))
+			continue
+		}
+		if removed > 0 {
+			logger.Info("expired silences removed", zap.Int("count", removed))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"alertengine/engine"
+
+	"go.uber.org/zap"
+)
+
+// configReloader 重新加载配置文件并应用到运行中的组件, 校验失败时保留旧配置
+type configReloader struct {
+	filename string
+	explicit bool
+	level    zap.AtomicLevel
+	reloader *engine.Reloader
+	metrics  *engine.Metrics
+	logger   *zap.Logger
+	mu       sync.Mutex
+}
+
+// reload 重新加载配置, SIGHUP 与 /-/reload 触发的重载串行执行
+func (c *configReloader) reload() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger.Info("loading configuration file", zap.String("filename", c.filename))
+	defer func() {
+		if err != nil {
+			c.metrics.ConfigLastReloadSuccessful.Set(0)
+			c.logger.Error("failed to reload configuration", zap.String("filename", c.filename), zap.Error(err))
+			return
+		}
+		c.metrics.ConfigLastReloadSuccessful.Set(1)
+		c.metrics.ConfigLastReloadSuccessTime.SetToCurrentTime()
+		c.logger.Info("completed loading of configuration file", zap.String("filename", c.filename))
+	}()
+
+	cfg, err := loadConfig(c.filename, c.explicit)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if err := c.reloader.ApplyConfig(cfg); err != nil {
+		return err
+	}
+
+	c.level.SetLevel(logLevel(cfg.Log.Level))
+	return nil
+}
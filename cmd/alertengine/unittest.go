@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"alertengine/config"
+	"alertengine/engine"
+)
+
+// runTest 执行 test 子命令
+func runTest(args []string) int {
+	if len(args) == 0 || args[0] != "rules" {
+		fmt.Fprintf(os.Stderr, "Usage: %s test rules [flags] <test-file>...\n", os.Args[0])
+		return 2
+	}
+
+	fs := flag.NewFlagSet("test rules", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s test rules [flags] <test-file>...\n\nRun promtool-style unit tests for gateway rules.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "", "Configuration file providing external_labels and alert_relabel_configs")
+	fs.Parse(args[1:])
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := config.DefaultConfig()
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile, true); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			return 1
+		}
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config: %v\n", err)
+			return 1
+		}
+	}
+
+	if !engine.RunRuleUnitTests(os.Stdout, cfg, fs.Args()...) {
+		return 1
+	}
+	return 0
+}
@@ -199,6 +199,22 @@ func Equal(ls, o Labels) bool {
 	return true
 }
 
+// Merge returns the labels with the labels of o added for names that are not
+// already present. Labels of ls take precedence.
+func (ls Labels) Merge(o Labels) Labels {
+	if len(o) == 0 {
+		return ls
+	}
+
+	b := NewBuilder(ls)
+	for _, l := range o {
+		if !ls.Has(l.Name) {
+			b.Set(l.Name, l.Value)
+		}
+	}
+	return b.Labels()
+}
+
 // Map returns a string map of the labels.
 func (ls Labels) Map() map[string]string {
 	m := make(map[string]string, len(ls))
@@ -0,0 +1,328 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchType is an enum for label matching types.
+type MatchType int
+
+// Possible MatchTypes.
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+var matchTypeToStr = [...]string{
+	MatchEqual:     "=",
+	MatchNotEqual:  "!=",
+	MatchRegexp:    "=~",
+	MatchNotRegexp: "!~",
+}
+
+func (m MatchType) String() string {
+	if m < MatchEqual || m > MatchNotRegexp {
+		panic("unknown match type")
+	}
+	return matchTypeToStr[m]
+}
+
+// ParseMatchType returns the MatchType for the given operator string.
+func ParseMatchType(s string) (MatchType, error) {
+	for t, op := range matchTypeToStr {
+		if op == s {
+			return MatchType(t), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown match type %q", s)
+}
+
+// Matcher models the matching of a label.
+type Matcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// NewMatcher returns a matcher object. Regular expressions are fully
+// anchored, as in Prometheus.
+func NewMatcher(t MatchType, n, v string) (*Matcher, error) {
+	m := &Matcher{
+		Type:  t,
+		Name:  n,
+		Value: v,
+	}
+	if n == "" {
+		return nil, fmt.Errorf("matcher label name cannot be empty")
+	}
+	if t < MatchEqual || t > MatchNotRegexp {
+		return nil, fmt.Errorf("unknown match type %d", t)
+	}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q for label %q: %w", v, n, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// MustNewMatcher panics on error - only for use in tests!
+func MustNewMatcher(t MatchType, n, v string) *Matcher {
+	m, err := NewMatcher(t, n, v)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%s", m.Name, m.Type, strconv.Quote(m.Value))
+}
+
+// Matches returns whether the matcher matches the given string value.
+func (m *Matcher) Matches(s string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return s == m.Value
+	case MatchNotEqual:
+		return s != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(s)
+	case MatchNotRegexp:
+		return !m.re.MatchString(s)
+	}
+	panic("common.Matcher.Matches: invalid match type")
+}
+
+type matcherJSON struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *Matcher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(matcherJSON{
+		Name:  m.Name,
+		Value: m.Value,
+		Type:  m.Type.String(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Matcher) UnmarshalJSON(b []byte) error {
+	var v matcherJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	t, err := ParseMatchType(v.Type)
+	if err != nil {
+		return err
+	}
+	nm, err := NewMatcher(t, v.Name, v.Value)
+	if err != nil {
+		return err
+	}
+
+	*m = *nm
+	return nil
+}
+
+// Matchers is a set of matchers that all have to match.
+type Matchers []*Matcher
+
+// Matches returns whether all matchers match the given label set. Missing
+// labels are treated as having an empty value.
+func (ms Matchers) Matches(lset Labels) bool {
+	for _, m := range ms {
+		if !m.Matches(lset.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ms Matchers) String() string {
+	var b bytes.Buffer
+
+	b.WriteByte('{')
+	for i, m := range ms {
+		if i > 0 {
+			b.WriteByte(',')
+			b.WriteByte(' ')
+		}
+		b.WriteString(m.String())
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// ParseMatcher parses a single matcher such as `foo=~"bar.*"`.
+func ParseMatcher(s string) (*Matcher, error) {
+	ms, err := ParseMatchers(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) != 1 {
+		return nil, fmt.Errorf("expected exactly one matcher in %q, got %d", s, len(ms))
+	}
+	return ms[0], nil
+}
+
+// ParseMatchers parses a selector such as `{a="b", c!~"d"}`. The surrounding
+// braces are optional. Values may be double-quoted, backquoted or, when they
+// contain no comma, unquoted.
+func ParseMatchers(s string) (Matchers, error) {
+	p := &matcherParser{input: strings.TrimSpace(s)}
+
+	if strings.HasPrefix(p.input, "{") {
+		if !strings.HasSuffix(p.input, "}") {
+			return nil, fmt.Errorf("missing closing '}' in %q", s)
+		}
+		p.input = p.input[1 : len(p.input)-1]
+	} else if strings.HasSuffix(p.input, "}") {
+		return nil, fmt.Errorf("missing opening '{' in %q", s)
+	}
+
+	return p.parse()
+}
+
+type matcherParser struct {
+	input string
+	pos   int
+}
+
+func (p *matcherParser) parse() (Matchers, error) {
+	var ms Matchers
+
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			return ms, nil
+		}
+
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		t, err := p.parseOp()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := NewMatcher(t, name, value)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+
+		p.skipSpaces()
+		if p.eof() {
+			return ms, nil
+		}
+		if p.input[p.pos] != ',' {
+			return nil, p.errorf("expected ',' but got %q", p.input[p.pos])
+		}
+		p.pos++
+	}
+}
+
+func (p *matcherParser) parseName() (string, error) {
+	start := p.pos
+	for !p.eof() && isLabelNameChar(p.input[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected label name")
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *matcherParser) parseOp() (MatchType, error) {
+	rest := p.input[p.pos:]
+	for _, t := range []MatchType{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if strings.HasPrefix(rest, t.String()) {
+			p.pos += len(t.String())
+			return t, nil
+		}
+	}
+	return 0, p.errorf("expected one of '=', '!=', '=~', '!~'")
+}
+
+func (p *matcherParser) parseValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf("expected label value")
+	}
+
+	switch q := p.input[p.pos]; q {
+	case '"', '`':
+		start := p.pos
+		p.pos++
+		for !p.eof() && p.input[p.pos] != q {
+			if q == '"' && p.input[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.eof() {
+			return "", fmt.Errorf("unterminated quoted string at position %d in %q", start, p.input)
+		}
+		p.pos++
+		v, err := strconv.Unquote(p.input[start:p.pos])
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string at position %d in %q: %w", start, p.input, err)
+		}
+		return v, nil
+	default:
+		start := p.pos
+		for !p.eof() && p.input[p.pos] != ',' {
+			p.pos++
+		}
+		v := strings.TrimSpace(p.input[start:p.pos])
+		if strings.ContainsAny(v, "\"`{}") {
+			return "", fmt.Errorf("invalid unquoted value %q in %q", v, p.input)
+		}
+		if !utf8.ValidString(v) {
+			return "", fmt.Errorf("invalid UTF-8 value %q in %q", v, p.input)
+		}
+		return v, nil
+	}
+}
+
+func (p *matcherParser) skipSpaces() {
+	for !p.eof() && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *matcherParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *matcherParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d in %q", fmt.Sprintf(format, args...), p.pos, p.input)
+}
+
+func isLabelNameChar(b byte, first bool) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' || (!first && b >= '0' && b <= '9')
+}
@@ -0,0 +1,283 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatcherMatches(t *testing.T) {
+	tests := []struct {
+		matcher *Matcher
+		value   string
+		match   bool
+	}{
+		{MustNewMatcher(MatchEqual, "foo", "bar"), "bar", true},
+		{MustNewMatcher(MatchEqual, "foo", "bar"), "baz", false},
+		{MustNewMatcher(MatchEqual, "foo", ""), "", true},
+		{MustNewMatcher(MatchNotEqual, "foo", "bar"), "bar", false},
+		{MustNewMatcher(MatchNotEqual, "foo", "bar"), "baz", true},
+		{MustNewMatcher(MatchNotEqual, "foo", ""), "", false},
+		{MustNewMatcher(MatchRegexp, "foo", "ba."), "bar", true},
+		{MustNewMatcher(MatchRegexp, "foo", "ba."), "xbar", false},
+		{MustNewMatcher(MatchRegexp, "foo", "ba."), "barx", false},
+		{MustNewMatcher(MatchRegexp, "foo", "bar|baz"), "baz", true},
+		{MustNewMatcher(MatchRegexp, "foo", ".*"), "", true},
+		{MustNewMatcher(MatchNotRegexp, "foo", "ba."), "bar", false},
+		{MustNewMatcher(MatchNotRegexp, "foo", "ba."), "foo", true},
+		{MustNewMatcher(MatchNotRegexp, "foo", ".+"), "", true},
+	}
+
+	for _, tc := range tests {
+		if got := tc.matcher.Matches(tc.value); got != tc.match {
+			t.Errorf("%s.Matches(%q) = %v, want %v", tc.matcher, tc.value, got, tc.match)
+		}
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	if _, err := NewMatcher(MatchRegexp, "foo", "(unclosed"); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+	if _, err := NewMatcher(MatchNotRegexp, "foo", "[a-"); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+	if _, err := NewMatcher(MatchEqual, "", "bar"); err == nil {
+		t.Error("expected error for empty label name")
+	}
+	if _, err := NewMatcher(MatchType(42), "foo", "bar"); err == nil {
+		t.Error("expected error for unknown match type")
+	}
+}
+
+func TestMatchTypeString(t *testing.T) {
+	for typ, want := range map[MatchType]string{
+		MatchEqual:     "=",
+		MatchNotEqual:  "!=",
+		MatchRegexp:    "=~",
+		MatchNotRegexp: "!~",
+	} {
+		if got := typ.String(); got != want {
+			t.Errorf("MatchType(%d).String() = %q, want %q", typ, got, want)
+		}
+		parsed, err := ParseMatchType(want)
+		if err != nil {
+			t.Errorf("ParseMatchType(%q) returned error: %v", want, err)
+		}
+		if parsed != typ {
+			t.Errorf("ParseMatchType(%q) = %v, want %v", want, parsed, typ)
+		}
+	}
+
+	if _, err := ParseMatchType("=="); err == nil {
+		t.Error("expected error for unknown operator")
+	}
+}
+
+func TestMatchersMatches(t *testing.T) {
+	lset := FromStrings("alertname", "HighCPU", "instance", "host-1:9100", "severity", "warning")
+
+	tests := []struct {
+		input string
+		match bool
+	}{
+		{`{}`, true},
+		{`{alertname="HighCPU"}`, true},
+		{`{alertname="HighCPU", severity="critical"}`, false},
+		{`{alertname=~"High.*", instance!~"host-2.*"}`, true},
+		{`{alertname!="HighCPU"}`, false},
+		{`{missing=""}`, true},
+		{`{missing!=""}`, false},
+		{`{missing=~".+"}`, false},
+		{`{missing!~".+"}`, true},
+		{`{instance=~"host-1"}`, false},
+	}
+
+	for _, tc := range tests {
+		ms, err := ParseMatchers(tc.input)
+		if err != nil {
+			t.Fatalf("ParseMatchers(%q) returned error: %v", tc.input, err)
+		}
+		if got := ms.Matches(lset); got != tc.match {
+			t.Errorf("%s.Matches(%s) = %v, want %v", tc.input, lset, got, tc.match)
+		}
+	}
+}
+
+func TestParseMatchers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Matchers
+	}{
+		{
+			input: `{a="b", c!~"d"}`,
+			want: Matchers{
+				MustNewMatcher(MatchEqual, "a", "b"),
+				MustNewMatcher(MatchNotRegexp, "c", "d"),
+			},
+		},
+		{
+			input: `a="b",c!="d",e=~"f.*",g!~"h"`,
+			want: Matchers{
+				MustNewMatcher(MatchEqual, "a", "b"),
+				MustNewMatcher(MatchNotEqual, "c", "d"),
+				MustNewMatcher(MatchRegexp, "e", "f.*"),
+				MustNewMatcher(MatchNotRegexp, "g", "h"),
+			},
+		},
+		{
+			input: ` { foo = "bar" , } `,
+			want:  Matchers{MustNewMatcher(MatchEqual, "foo", "bar")},
+		},
+		{
+			input: `foo=bar, baz =~ qu.x`,
+			want: Matchers{
+				MustNewMatcher(MatchEqual, "foo", "bar"),
+				MustNewMatcher(MatchRegexp, "baz", "qu.x"),
+			},
+		},
+		{
+			input: `{foo="a \"quoted\", value\\n"}`,
+			want:  Matchers{MustNewMatcher(MatchEqual, "foo", `a "quoted", value\n`)},
+		},
+		{
+			input: "{foo=~`\\d+`}",
+			want:  Matchers{MustNewMatcher(MatchRegexp, "foo", `\d+`)},
+		},
+		{
+			input: `{foo=""}`,
+			want:  Matchers{MustNewMatcher(MatchEqual, "foo", "")},
+		},
+		{
+			input: `{_foo_1="中文"}`,
+			want:  Matchers{MustNewMatcher(MatchEqual, "_foo_1", "中文")},
+		},
+		{
+			input: `{}`,
+			want:  nil,
+		},
+		{
+			input: ``,
+			want:  nil,
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseMatchers(tc.input)
+		if err != nil {
+			t.Errorf("ParseMatchers(%q) returned error: %v", tc.input, err)
+			continue
+		}
+		if !equalMatchers(got, tc.want) {
+			t.Errorf("ParseMatchers(%q) = %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestParseMatchersErrors(t *testing.T) {
+	for _, input := range []string{
+		`{a="b"`,
+		`a="b"}`,
+		`{="b"}`,
+		`{1a="b"}`,
+		`{a}`,
+		`{a=="b"}`,
+		`{a:"b"}`,
+		`{a="b}`,
+		`{a="b" c="d"}`,
+		`{a=~"(b"}`,
+		`{a="\q"}`,
+		`{a=}`,
+		`{a=b"c}`,
+	} {
+		if ms, err := ParseMatchers(input); err == nil {
+			t.Errorf("ParseMatchers(%q) = %s, expected error", input, ms)
+		}
+	}
+}
+
+func TestParseMatcher(t *testing.T) {
+	m, err := ParseMatcher(`severity=~"critical|warning"`)
+	if err != nil {
+		t.Fatalf("ParseMatcher returned error: %v", err)
+	}
+	if !equalMatchers(Matchers{m}, Matchers{MustNewMatcher(MatchRegexp, "severity", "critical|warning")}) {
+		t.Errorf("unexpected matcher %s", m)
+	}
+
+	if _, err := ParseMatcher(`a="b", c="d"`); err == nil {
+		t.Error("expected error for multiple matchers")
+	}
+	if _, err := ParseMatcher(``); err == nil {
+		t.Error("expected error for empty input")
+	}
+}
+
+func TestMatchersStringRoundTrip(t *testing.T) {
+	ms := Matchers{
+		MustNewMatcher(MatchEqual, "a", `b"c`),
+		MustNewMatcher(MatchNotEqual, "d", ""),
+		MustNewMatcher(MatchRegexp, "e", `f\.g|h`),
+		MustNewMatcher(MatchNotRegexp, "i", "j, k"),
+	}
+
+	s := ms.String()
+	if want := `{a="b\"c", d!="", e=~"f\\.g|h", i!~"j, k"}`; s != want {
+		t.Errorf("String() = %s, want %s", s, want)
+	}
+
+	parsed, err := ParseMatchers(s)
+	if err != nil {
+		t.Fatalf("ParseMatchers(%q) returned error: %v", s, err)
+	}
+	if !equalMatchers(parsed, ms) {
+		t.Errorf("round trip mismatch: got %s, want %s", parsed, ms)
+	}
+}
+
+func TestMatcherJSON(t *testing.T) {
+	ms := Matchers{
+		MustNewMatcher(MatchEqual, "a", "b"),
+		MustNewMatcher(MatchNotRegexp, "c", "d.*"),
+	}
+
+	b, err := json.Marshal(ms)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if want := `[{"name":"a","value":"b","type":"="},{"name":"c","value":"d.*","type":"!~"}]`; string(b) != want {
+		t.Errorf("json = %s, want %s", b, want)
+	}
+
+	var parsed Matchers
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !equalMatchers(parsed, ms) {
+		t.Errorf("json round trip mismatch: got %s, want %s", parsed, ms)
+	}
+	if !parsed.Matches(FromStrings("a", "b", "c", "x")) {
+		t.Error("unmarshalled regexp matcher was not compiled")
+	}
+
+	for _, input := range []string{
+		`[{"name":"a","value":"b","type":"=="}]`,
+		`[{"name":"a","value":"(b","type":"=~"}]`,
+		`[{"name":"","value":"b","type":"="}]`,
+	} {
+		if err := json.Unmarshal([]byte(input), &parsed); err == nil {
+			t.Errorf("json.Unmarshal(%s) expected error", input)
+		}
+	}
+}
+
+func equalMatchers(a, b Matchers) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Name != b[i].Name || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+
+	config_util "github.com/prometheus/common/config"
+)
+
+// DatasourceAuthConfig 数据源认证配置
+type DatasourceAuthConfig struct {
+	// 默认认证配置, 适用于未单独配置且网关未下发认证信息的数据源
+	Default *DatasourceAuth `yaml:"default" json:"default"`
+
+	// 按数据源ID覆盖的认证配置, 优先级高于网关下发的认证信息
+	Proms map[int64]*DatasourceAuth `yaml:"proms" json:"proms"`
+
+	// 兼容旧版本, 未配置其他认证的数据源使用 auth_token 进行 Basic 认证. 已废弃, 请使用 default
+	LegacyAuthToken bool `yaml:"legacy_auth_token,omitempty" json:"legacy_auth_token,omitempty"`
+}
+
+// Resolve 返回数据源生效的认证配置, 优先级: 按ID配置 > 网关下发 > 默认配置, 均未配置时返回 nil
+func (c DatasourceAuthConfig) Resolve(promID int64, gatewayAuth *DatasourceAuth) *DatasourceAuth {
+	if auth, ok := c.Proms[promID]; ok && auth != nil {
+		return auth
+	}
+	if gatewayAuth != nil {
+		return gatewayAuth
+	}
+	return c.Default
+}
+
+// DatasourceAuth Prometheus 数据源认证配置, 凭据均支持从文件读取, 文件在每次请求时重新读取以便轮换
+type DatasourceAuth struct {
+	// Bearer Token
+	BearerToken config_util.Secret `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+
+	// Bearer Token 文件路径
+	BearerTokenFile string `yaml:"bearer_token_file,omitempty" json:"bearer_token_file,omitempty"`
+
+	// Basic 认证
+	BasicAuth *BasicAuth `yaml:"basic_auth,omitempty" json:"basic_auth,omitempty"`
+
+	// 自定义请求头
+	Headers map[string]config_util.Secret `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// 从文件读取取值的自定义请求头, 值为文件路径
+	HeaderFiles map[string]string `yaml:"header_files,omitempty" json:"header_files,omitempty"`
+
+	// TLS 配置
+	TLSConfig TLSConfig `yaml:"tls_config,omitempty" json:"tls_config,omitempty"`
+}
+
+// BasicAuth Basic 认证配置
+type BasicAuth struct {
+	// 用户名
+	Username string `yaml:"username" json:"username"`
+
+	// 密码
+	Password config_util.Secret `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// 密码文件路径
+	PasswordFile string `yaml:"password_file,omitempty" json:"password_file,omitempty"`
+}
+
+// TLSConfig TLS 客户端配置, 证书文件变更后自动重新加载
+type TLSConfig struct {
+	// CA 证书文件路径
+	CAFile string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+
+	// 客户端证书文件路径
+	CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+
+	// 客户端私钥文件路径
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+
+	// 校验服务端证书时使用的服务名
+	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"`
+
+	// 是否跳过服务端证书校验
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+}
+
+// Validate 校验认证配置
+func (a *DatasourceAuth) Validate() error {
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("at most one of bearer_token & bearer_token_file must be configured")
+	}
+	if a.BasicAuth != nil {
+		if a.BearerToken != "" || a.BearerTokenFile != "" {
+			return fmt.Errorf("at most one of basic_auth & bearer_token must be configured")
+		}
+		if a.BasicAuth.Password != "" && a.BasicAuth.PasswordFile != "" {
+			return fmt.Errorf("at most one of basic_auth password & password_file must be configured")
+		}
+	}
+	if (a.TLSConfig.CertFile == "") != (a.TLSConfig.KeyFile == "") {
+		return fmt.Errorf("tls_config cert_file and key_file must be configured together")
+	}
+	for name := range a.Headers {
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			return fmt.Errorf("authorization header must be configured with bearer_token or basic_auth")
+		}
+	}
+	for _, name := range sortedKeys(a.HeaderFiles) {
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			return fmt.Errorf("authorization header must be configured with bearer_token or basic_auth")
+		}
+		if _, ok := a.Headers[name]; ok {
+			return fmt.Errorf("header %q must be configured in only one of headers & header_files", name)
+		}
+		if a.HeaderFiles[name] == "" {
+			return fmt.Errorf("header_files: file for header %q cannot be empty", name)
+		}
+	}
+	cfg := a.HTTPClientConfig()
+	return cfg.Validate()
+}
+
+// HTTPClientConfig 转换为 Prometheus 通用的 HTTP 客户端配置, 自定义请求头不包含在内
+func (a *DatasourceAuth) HTTPClientConfig() config_util.HTTPClientConfig {
+	cfg := config_util.DefaultHTTPClientConfig
+
+	if a.BearerToken != "" || a.BearerTokenFile != "" {
+		cfg.Authorization = &config_util.Authorization{
+			Type:            "Bearer",
+			Credentials:     a.BearerToken,
+			CredentialsFile: a.BearerTokenFile,
+		}
+	}
+
+	if a.BasicAuth != nil {
+		cfg.BasicAuth = &config_util.BasicAuth{
+			Username:     a.BasicAuth.Username,
+			Password:     a.BasicAuth.Password,
+			PasswordFile: a.BasicAuth.PasswordFile,
+		}
+	}
+
+	cfg.TLSConfig = config_util.TLSConfig{
+		CAFile:             a.TLSConfig.CAFile,
+		CertFile:           a.TLSConfig.CertFile,
+		KeyFile:            a.TLSConfig.KeyFile,
+		ServerName:         a.TLSConfig.ServerName,
+		InsecureSkipVerify: a.TLSConfig.InsecureSkipVerify,
+	}
+
+	return cfg
+}
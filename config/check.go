@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Check 在 Validate 的基础上检查运行环境: 目录是否可写, 引用的证书和密钥文件是否可读.
+// 返回发现的所有问题, 用于 check config 子命令.
+func (c *Config) Check() []error {
+	var errs []error
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	add(checkFile("auth_token_file", c.AuthTokenFile))
+	if c.Gateway.Auth != nil {
+		errs = append(errs, c.Gateway.Auth.checkFiles("gateway.auth")...)
+	}
+	for i, src := range c.RuleSources {
+		for _, pattern := range src.Files {
+			if matches, err := filepath.Glob(pattern); err == nil && len(matches) == 0 {
+				add(fmt.Errorf("rule_sources[%d].files: %q does not match any file", i, pattern))
+			}
+		}
+	}
+	add(checkWritableDir("storage.rule_dir", c.Storage.RuleDir))
+	if c.Log.OutputPath != "" && c.Log.OutputPath != "stdout" && c.Log.OutputPath != "stderr" {
+		add(checkWritableDir("log.output_path", filepath.Dir(c.Log.OutputPath)))
+	}
+	if c.Silence.DataFile != "" {
+		add(checkWritableDir("silence.data_file", filepath.Dir(c.Silence.DataFile)))
+	}
+
+	if t := c.Web.TLSServerConfig; t != nil {
+		add(checkFile("web.tls_server_config.cert_file", t.CertFile))
+		add(checkFile("web.tls_server_config.key_file", t.KeyFile))
+		add(checkFile("web.tls_server_config.client_ca_file", t.ClientCAFile))
+	}
+
+	if c.DatasourceAuth.Default != nil {
+		errs = append(errs, c.DatasourceAuth.Default.checkFiles("datasource_auth.default")...)
+	}
+	ids := make([]int64, 0, len(c.DatasourceAuth.Proms))
+	for id := range c.DatasourceAuth.Proms {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if auth := c.DatasourceAuth.Proms[id]; auth != nil {
+			errs = append(errs, auth.checkFiles(fmt.Sprintf("datasource_auth.proms[%d]", id))...)
+		}
+	}
+
+	return errs
+}
+
+// checkFiles 检查认证配置引用的文件是否可读
+func (a *DatasourceAuth) checkFiles(prefix string) []error {
+	var errs []error
+	files := [][2]string{
+		{"bearer_token_file", a.BearerTokenFile},
+		{"tls_config.ca_file", a.TLSConfig.CAFile},
+		{"tls_config.cert_file", a.TLSConfig.CertFile},
+		{"tls_config.key_file", a.TLSConfig.KeyFile},
+	}
+	if a.BasicAuth != nil {
+		files = append(files, [2]string{"basic_auth.password_file", a.BasicAuth.PasswordFile})
+	}
+	for _, name := range sortedKeys(a.HeaderFiles) {
+		files = append(files, [2]string{fmt.Sprintf("header_files[%s]", name), a.HeaderFiles[name]})
+	}
+	for _, f := range files {
+		if err := checkFile(prefix+"."+f[0], f[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// checkFile 检查文件是否存在且可读, 路径为空时跳过
+func checkFile(name, path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: %s is a directory", name, path)
+	}
+	return nil
+}
+
+// checkWritableDir 检查目录是否可写, 目录不存在时检查能否在最近的已存在上级目录中创建
+func checkWritableDir(name, dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	path := dir
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s: %s is not a directory", name, path)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		path = parent
+	}
+
+	f, err := os.CreateTemp(path, ".alertengine-check-*")
+	if err != nil {
+		if path != dir {
+			return fmt.Errorf("%s: %s does not exist and cannot be created: %w", name, dir, err)
+		}
+		return fmt.Errorf("%s: %s is not writable: %w", name, dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
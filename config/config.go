@@ -1,8 +1,17 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"alertengine/common"
+	"alertengine/relabel"
+
+	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 )
 
@@ -14,14 +23,23 @@ type Config struct {
 	// 网关服务配置
 	Gateway GatewayConfig `yaml:"gateway" json:"gateway"`
 
+	// 规则来源, 未配置时只从网关获取规则和数据源
+	RuleSources []RuleSourceConfig `yaml:"rule_sources,omitempty" json:"rule_sources,omitempty"`
+
 	// 规则评估间隔 (如: 30s)
 	EvaluationInterval model.Duration `yaml:"evaluation_interval" json:"evaluation_interval"`
 
 	// 规则重载间隔 (如: 5m)
 	ReloadInterval model.Duration `yaml:"reload_interval" json:"reload_interval"`
 
-	// 认证Token
-	AuthToken string `yaml:"auth_token" json:"auth_token"`
+	// 认证Token, 未配置 gateway.auth 时作为网关的 Token 请求头, 兼容旧版本
+	AuthToken config_util.Secret `yaml:"auth_token" json:"auth_token"`
+
+	// 认证Token文件路径, 每次请求时重新读取以便轮换
+	AuthTokenFile string `yaml:"auth_token_file" json:"auth_token_file"`
+
+	// Prometheus 数据源认证配置
+	DatasourceAuth DatasourceAuthConfig `yaml:"datasource_auth" json:"datasource_auth"`
 
 	// 规则存储配置
 	Storage StorageConfig `yaml:"storage" json:"storage"`
@@ -32,8 +50,23 @@ type Config struct {
 	// 指标暴露端口
 	MetricsPort int `yaml:"metrics_port" json:"metrics_port"`
 
+	// 本地HTTP服务的 TLS 与认证配置
+	Web WebConfig `yaml:"web" json:"web"`
+
 	// 是否开启告警通知
 	EnableNotify bool `yaml:"enable_notify" json:"enable_notify"`
+
+	// 告警静默配置
+	Silence SilenceConfig `yaml:"silence" json:"silence"`
+
+	// 告警抑制规则
+	InhibitRules []InhibitRule `yaml:"inhibit_rules" json:"inhibit_rules"`
+
+	// 外部标签, 合并到每个告警和生成的规则文件中
+	ExternalLabels common.Labels `yaml:"external_labels" json:"external_labels"`
+
+	// 告警发送前的标签重标记配置
+	AlertRelabelConfigs []*relabel.Config `yaml:"alert_relabel_configs" json:"alert_relabel_configs"`
 }
 
 // GatewayConfig 网关配置
@@ -52,6 +85,12 @@ type GatewayConfig struct {
 
 	// 请求超时时间
 	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// 分页获取规则列表时每页的规则数量, 为 0 时一次获取全部规则
+	PageSize int `yaml:"page_size,omitempty" json:"page_size,omitempty"`
+
+	// 网关认证配置
+	Auth *GatewayAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
 }
 
 // StorageConfig 存储配置
@@ -66,6 +105,39 @@ type StorageConfig struct {
 	EnableHistory bool `yaml:"enable_history" json:"enable_history"`
 }
 
+// SilenceConfig 告警静默配置
+type SilenceConfig struct {
+	// 静默数据文件路径, 为空时存放在 storage.rule_dir 下
+	DataFile string `yaml:"data_file" json:"data_file"`
+
+	// 已过期静默的保留时长
+	Retention model.Duration `yaml:"retention" json:"retention"`
+}
+
+// InhibitRule 告警抑制规则, 源告警触发期间抑制匹配的目标告警
+type InhibitRule struct {
+	// 源告警匹配器, 如 severity="critical"
+	SourceMatchers []string `yaml:"source_matchers" json:"source_matchers"`
+
+	// 目标告警匹配器, 如 alertname=~"High.*"
+	TargetMatchers []string `yaml:"target_matchers" json:"target_matchers"`
+
+	// 源告警标签等值匹配
+	SourceMatch map[string]string `yaml:"source_match" json:"source_match"`
+
+	// 源告警标签正则匹配
+	SourceMatchRE map[string]string `yaml:"source_match_re" json:"source_match_re"`
+
+	// 目标告警标签等值匹配
+	TargetMatch map[string]string `yaml:"target_match" json:"target_match"`
+
+	// 目标告警标签正则匹配
+	TargetMatchRE map[string]string `yaml:"target_match_re" json:"target_match_re"`
+
+	// 源告警与目标告警取值必须相同的标签
+	Equal []string `yaml:"equal" json:"equal"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	// 日志级别: debug, info, warn, error
@@ -102,22 +174,228 @@ func DefaultConfig() *Config {
 			OutputPath: "/var/log/alertengine/alertengine.log",
 		},
 		MetricsPort: 9090,
+		Web: WebConfig{
+			ListenAddresses: ListenAddresses{
+				API: ":8080",
+			},
+			ShutdownTimeout: model.Duration(30 * time.Second),
+		},
+		Silence: SilenceConfig{
+			Retention: model.Duration(120 * time.Hour),
+		},
+	}
+}
+
+// ListenAddresses 返回各类接口实际使用的监听地址
+func (c *Config) ListenAddresses() ListenAddresses {
+	addrs := c.Web.ListenAddresses
+	if addrs.Metrics == "" {
+		addrs.Metrics = fmt.Sprintf(":%d", c.MetricsPort)
+	}
+	if addrs.Health == "" {
+		addrs.Health = addrs.API
+	}
+	if addrs.Admin == "" {
+		addrs.Admin = addrs.API
 	}
+	return addrs
 }
 
-// Validate 验证配置
+// Validate 验证配置, 返回所有发现的问题
 func (c *Config) Validate() error {
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, ErrInvalidConfig(fmt.Sprintf(format, args...)))
+	}
+
+	if c.NotifyRetries < 1 {
+		add("notify_retries must be at least 1")
+	}
+
 	if c.Gateway.URL == "" {
-		return ErrInvalidConfig("gateway.url cannot be empty")
+		add("gateway.url cannot be empty")
+	} else if err := validateURL(c.Gateway.URL); err != nil {
+		add("gateway.url: %v", err)
+	}
+	for _, p := range [][2]string{
+		{"gateway.rule_path", c.Gateway.RulePath},
+		{"gateway.prom_path", c.Gateway.PromPath},
+		{"gateway.notify_path", c.Gateway.NotifyPath},
+	} {
+		if !strings.HasPrefix(p[1], "/") {
+			add("%s: %q must start with /", p[0], p[1])
+		}
+	}
+	if c.Gateway.Timeout <= 0 {
+		add("gateway.timeout must be positive")
+	}
+	if c.Gateway.PageSize < 0 {
+		add("gateway.page_size cannot be negative")
+	}
+	if c.AuthToken != "" && c.AuthTokenFile != "" {
+		add("at most one of auth_token & auth_token_file must be configured")
+	}
+	if c.Gateway.Auth != nil {
+		if c.AuthToken != "" || c.AuthTokenFile != "" {
+			add("auth_token cannot be used together with gateway.auth, use gateway.auth.token instead")
+		}
+		if err := c.Gateway.Auth.Validate(); err != nil {
+			add("gateway.auth: %v", err)
+		}
 	}
+
+	names := map[string]bool{}
+	gateways := 0
+	for i, src := range c.Sources() {
+		if names[src.Name] {
+			add("rule_sources[%d]: duplicate source name %q", i, src.Name)
+		}
+		names[src.Name] = true
+		if src.Type == SourceGateway {
+			if gateways++; gateways > 1 {
+				add("rule_sources[%d]: at most one gateway source can be configured", i)
+			}
+		}
+		if err := src.Validate(); err != nil {
+			add("rule_sources[%d]: %v", i, err)
+		}
+	}
+
 	if c.EvaluationInterval <= 0 {
-		return ErrInvalidConfig("evaluation_interval must be positive")
+		add("evaluation_interval must be positive")
 	}
 	if c.ReloadInterval <= 0 {
-		return ErrInvalidConfig("reload_interval must be positive")
+		add("reload_interval must be positive")
 	}
+
 	if c.Storage.RuleDir == "" {
-		return ErrInvalidConfig("storage.rule_dir cannot be empty")
+		add("storage.rule_dir cannot be empty")
+	}
+	if c.Storage.RetentionDays < 0 {
+		add("storage.retention_days cannot be negative")
+	} else if c.Storage.EnableHistory && c.Storage.RetentionDays == 0 {
+		add("storage.retention_days must be positive when storage.enable_history is true")
+	}
+
+	if _, ok := logLevels[c.Log.Level]; !ok {
+		add("log.level: unknown level %q, must be one of debug, info, warn, error", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "console" {
+		add("log.format: unknown format %q, must be json or console", c.Log.Format)
+	}
+	if c.Log.OutputPath == "" {
+		add("log.output_path cannot be empty")
+	}
+
+	if c.Web.ListenAddresses.Metrics == "" && (c.MetricsPort <= 0 || c.MetricsPort > 65535) {
+		add("metrics_port: %d is not a valid port", c.MetricsPort)
+	}
+	for _, err := range c.Web.Validate() {
+		add("web.%v", err)
+	}
+
+	if c.Silence.Retention < 0 {
+		add("silence.retention cannot be negative")
+	}
+
+	if c.DatasourceAuth.LegacyAuthToken && c.AuthToken == "" && c.AuthTokenFile == "" {
+		add("datasource_auth.legacy_auth_token requires auth_token or auth_token_file")
+	}
+	if c.DatasourceAuth.Default != nil {
+		if err := c.DatasourceAuth.Default.Validate(); err != nil {
+			add("datasource_auth.default: %v", err)
+		}
+	}
+	ids := make([]int64, 0, len(c.DatasourceAuth.Proms))
+	for id := range c.DatasourceAuth.Proms {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		auth := c.DatasourceAuth.Proms[id]
+		if auth == nil {
+			continue
+		}
+		if err := auth.Validate(); err != nil {
+			add("datasource_auth.proms[%d]: %v", id, err)
+		}
+	}
+
+	for _, l := range c.ExternalLabels {
+		if !model.LabelName(l.Name).IsValid() {
+			add("external_labels: %q is not a valid label name", l.Name)
+		}
+	}
+	for i, rc := range c.AlertRelabelConfigs {
+		if rc == nil {
+			add("alert_relabel_configs[%d]: empty relabel config", i)
+			continue
+		}
+		if err := rc.Validate(); err != nil {
+			add("alert_relabel_configs[%d]: %v", i, err)
+		}
+	}
+
+	for i, r := range c.InhibitRules {
+		if len(r.SourceMatchers)+len(r.SourceMatch)+len(r.SourceMatchRE) == 0 {
+			add("inhibit_rules[%d]: source matchers cannot be empty", i)
+		}
+		if len(r.TargetMatchers)+len(r.TargetMatch)+len(r.TargetMatchRE) == 0 {
+			add("inhibit_rules[%d]: target matchers cannot be empty", i)
+		}
+		for _, ms := range [][]string{r.SourceMatchers, r.TargetMatchers} {
+			for _, m := range ms {
+				if _, err := common.ParseMatchers(m); err != nil {
+					add("inhibit_rules[%d]: invalid matcher %q: %v", i, m, err)
+				}
+			}
+		}
+		for _, name := range sortedKeys(r.SourceMatchRE) {
+			if _, err := regexp.Compile(r.SourceMatchRE[name]); err != nil {
+				add("inhibit_rules[%d]: invalid source_match_re for %q: %v", i, name, err)
+			}
+		}
+		for _, name := range sortedKeys(r.TargetMatchRE) {
+			if _, err := regexp.Compile(r.TargetMatchRE[name]); err != nil {
+				add("inhibit_rules[%d]: invalid target_match_re for %q: %v", i, name, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// logLevels 支持的日志级别
+var logLevels = map[string]struct{}{
+	"debug": {},
+	"info":  {},
+	"warn":  {},
+	"error": {},
+}
+
+// validateURL 检查地址是否为有效的 HTTP(S) 地址
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q in %q", u.Scheme, s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", s)
 	}
 	return nil
 }
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
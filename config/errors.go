@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ConfigError struct {
 	Message string
@@ -13,3 +16,25 @@ func (e ConfigError) Error() string {
 func ErrInvalidConfig(msg string) error {
 	return ConfigError{Message: msg}
 }
+
+// ValidationErrors 配置校验发现的所有问题
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d config errors:", len(e))
+	for _, err := range e {
+		sb.WriteString("\n  - ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap 支持 errors.Is 和 errors.As 匹配其中的单个错误
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"alertengine/common"
+	"alertengine/relabel"
+)
+
+// envRef 匹配 ${VAR} 和 ${VAR:-default} 形式的环境变量引用, $${VAR} 表示原样保留. 不展开 $VAR 和 ${1}.
+var envRef = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+var (
+	labelsType = reflect.TypeOf(common.Labels{})
+
+	// relabelType 重标记配置中的 ${name} 是正则分组引用, 不展开环境变量
+	relabelType = reflect.TypeOf(relabel.Config{})
+)
+
+// expandEnv 展开解析后的配置中所有字符串字段 (包括 Secret、map 的键和值) 的环境变量引用, 重标记配置除外.
+// 在 YAML 解析之后展开, 变量值中的 #、: 和 * 等 YAML 特殊字符按原样使用.
+// 变量未设置且没有默认值时返回错误.
+func expandEnv(v interface{}) error {
+	e := &envExpander{seen: make(map[string]bool)}
+	e.walk(reflect.ValueOf(v))
+
+	if len(e.missing) > 0 {
+		sort.Strings(e.missing)
+		return fmt.Errorf("environment variables not set: %s", strings.Join(e.missing, ", "))
+	}
+	return nil
+}
+
+// envExpander 递归展开字符串字段, 记录未设置的变量
+type envExpander struct {
+	missing []string
+	seen    map[string]bool
+}
+
+func (e *envExpander) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			e.walk(v.Elem())
+		}
+	case reflect.Struct:
+		if v.Type() == relabelType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				e.walk(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.walk(v.Index(i))
+		}
+		if v.Type() == labelsType {
+			// 标签名展开后需要重新排序
+			sort.Sort(v.Interface().(common.Labels))
+		}
+	case reflect.Map:
+		// map 中的值不可寻址, 复制后展开再写回, 键展开后替换原来的键
+		for _, key := range v.MapKeys() {
+			newKey := reflect.New(key.Type()).Elem()
+			newKey.Set(key)
+			e.walk(newKey)
+
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			e.walk(value)
+
+			if !newKey.Equal(key) {
+				v.SetMapIndex(key, reflect.Value{})
+			}
+			v.SetMapIndex(newKey, value)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(e.expand(v.String()))
+		}
+	}
+}
+
+// expand 展开字符串中的环境变量引用
+func (e *envExpander) expand(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		if m[1] != "" {
+			return ref[1:]
+		}
+
+		name := m[2]
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		if m[3] != "" {
+			return m[3][2:]
+		}
+		if _, ok := os.LookupEnv(name); !ok && !e.seen[name] {
+			e.seen[name] = true
+			e.missing = append(e.missing, name)
+		}
+		return ""
+	})
+}
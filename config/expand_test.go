@@ -0,0 +1,179 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"alertengine/common"
+	"alertengine/relabel"
+)
+
+func TestLoadExpandSpecialCharacters(t *testing.T) {
+	for _, value := range []string{
+		"abc #def",
+		"a: b",
+		"*star",
+		"&anchor",
+		"!tag",
+		"{x}",
+		"[1, 2]",
+		"'quoted\"",
+		"line1\nline2",
+	} {
+		t.Setenv("TOK", value)
+		cfg, err := Load([]byte("auth_token: ${TOK}\n"))
+		if err != nil {
+			t.Errorf("TOK=%q: unexpected error: %v", value, err)
+			continue
+		}
+		if got := string(cfg.AuthToken); got != value {
+			t.Errorf("TOK=%q: auth_token = %q", value, got)
+		}
+	}
+}
+
+func TestLoadExpandDefault(t *testing.T) {
+	data := []byte(`
+gateway:
+  url: "${GW_URL:-http://gateway:8080}"
+auth_token_file: ${TOKEN_DIR:-/etc/alertengine}/token
+`)
+
+	cfg, err := Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Gateway.URL != "http://gateway:8080" {
+		t.Errorf("gateway.url = %q", cfg.Gateway.URL)
+	}
+	if cfg.AuthTokenFile != "/etc/alertengine/token" {
+		t.Errorf("auth_token_file = %q", cfg.AuthTokenFile)
+	}
+
+	// 空值同样使用默认值
+	t.Setenv("GW_URL", "")
+	t.Setenv("TOKEN_DIR", "/run/secrets")
+	cfg, err = Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Gateway.URL != "http://gateway:8080" {
+		t.Errorf("gateway.url = %q", cfg.Gateway.URL)
+	}
+	if cfg.AuthTokenFile != "/run/secrets/token" {
+		t.Errorf("auth_token_file = %q", cfg.AuthTokenFile)
+	}
+}
+
+func TestLoadExpandEscape(t *testing.T) {
+	t.Setenv("TOK", "secret")
+	cfg, err := Load([]byte(`auth_token: "$${TOK}-${TOK}"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(cfg.AuthToken); got != "${TOK}-secret" {
+		t.Errorf("auth_token = %q", got)
+	}
+}
+
+func TestLoadExpandMissing(t *testing.T) {
+	_, err := Load([]byte(`
+auth_token: ${MISSING_A}
+gateway:
+  url: ${MISSING_B}
+log:
+  output_path: ${MISSING_A}
+`))
+	if err == nil {
+		t.Fatal("expected error for missing variables")
+	}
+	if !strings.Contains(err.Error(), "MISSING_A, MISSING_B") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// 设置为空字符串的变量不视为缺失
+	t.Setenv("EMPTY", "")
+	cfg, err := Load([]byte(`auth_token: "${EMPTY}"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AuthToken != "" {
+		t.Errorf("auth_token = %q", cfg.AuthToken)
+	}
+}
+
+func TestLoadExpandNested(t *testing.T) {
+	t.Setenv("REGION", "cn-beijing")
+	t.Setenv("HEADER_VALUE", "a: b # c")
+	t.Setenv("LABEL_NAME", "aaa")
+
+	cfg, err := Load([]byte(`
+external_labels:
+  region: ${REGION}
+  ${LABEL_NAME}: x
+  zzz: y
+datasource_auth:
+  default:
+    headers:
+      X-Scope: ${HEADER_VALUE}
+# 注释中的引用不展开: ${NOT_SET}
+alert_relabel_configs:
+  - source_labels: [instance]
+    regex: "(.*):.*"
+    target_label: host
+    replacement: "${1}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := common.FromMap(map[string]string{"region": "cn-beijing", "aaa": "x", "zzz": "y"})
+	if !common.Equal(cfg.ExternalLabels, want) {
+		t.Errorf("external_labels = %s, want %s", cfg.ExternalLabels, want)
+	}
+	if got := string(cfg.DatasourceAuth.Default.Headers["X-Scope"]); got != "a: b # c" {
+		t.Errorf("header = %q", got)
+	}
+	if got := cfg.AlertRelabelConfigs[0].Replacement; got != "${1}" {
+		t.Errorf("replacement = %q", got)
+	}
+}
+
+func TestLoadExpandSkipsRelabel(t *testing.T) {
+	t.Setenv("HOSTNAME", "engine-0")
+	t.Setenv("TEAM", "infra")
+
+	cfg, err := Load([]byte(`
+external_labels:
+  team: ${TEAM}
+alert_relabel_configs:
+  - source_labels: [instance]
+    regex: "(?P<host>[^:]+):(?P<port>.*)"
+    target_label: ${host}_port
+    replacement: "${port}"
+  - source_labels: [instance]
+    regex: "(?P<HOSTNAME>[^:]+):.*"
+    target_label: host
+    replacement: "${HOSTNAME}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.ExternalLabels.Get("team"); got != "infra" {
+		t.Errorf("external label team = %q, want infra", got)
+	}
+	// 重标记中的分组引用不报告为未设置的变量, 也不替换为同名环境变量
+	rc := cfg.AlertRelabelConfigs
+	if rc[0].TargetLabel != "${host}_port" || rc[0].Replacement != "${port}" {
+		t.Errorf("relabel config 0 = %+v", rc[0])
+	}
+	if rc[1].Replacement != "${HOSTNAME}" {
+		t.Errorf("relabel config 1 replacement = %q, want ${HOSTNAME}", rc[1].Replacement)
+	}
+
+	lset, keep := relabel.Process(common.FromMap(map[string]string{"instance": "node1:9100"}), rc...)
+	if !keep || lset.Get("node1_port") != "9100" || lset.Get("host") != "node1" {
+		t.Errorf("relabeled labels = %s", lset)
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+
+	config_util "github.com/prometheus/common/config"
+)
+
+// GatewayAuth 网关认证配置, 与数据源认证相互独立. 用于获取规则和数据源列表以及发送告警通知.
+type GatewayAuth struct {
+	// Token 请求头
+	Token config_util.Secret `yaml:"token,omitempty" json:"token,omitempty"`
+
+	// Token 文件路径
+	TokenFile string `yaml:"token_file,omitempty" json:"token_file,omitempty"`
+
+	// Bearer Token
+	BearerToken config_util.Secret `yaml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+
+	// Bearer Token 文件路径
+	BearerTokenFile string `yaml:"bearer_token_file,omitempty" json:"bearer_token_file,omitempty"`
+
+	// HMAC 请求签名
+	HMAC *HMACConfig `yaml:"hmac,omitempty" json:"hmac,omitempty"`
+
+	// TLS 配置, 配置客户端证书时使用双向 TLS
+	TLSConfig TLSConfig `yaml:"tls_config,omitempty" json:"tls_config,omitempty"`
+}
+
+// HMACConfig HMAC-SHA256 请求签名配置
+type HMACConfig struct {
+	// 签名密钥
+	Secret config_util.Secret `yaml:"secret,omitempty" json:"secret,omitempty"`
+
+	// 签名密钥文件路径
+	SecretFile string `yaml:"secret_file,omitempty" json:"secret_file,omitempty"`
+}
+
+// GatewayAuth 返回生效的网关认证配置, 未配置 gateway.auth 时使用 auth_token 作为 Token 请求头, 均未配置时返回 nil
+func (c *Config) GatewayAuth() *GatewayAuth {
+	if c.Gateway.Auth != nil {
+		return c.Gateway.Auth
+	}
+	if c.AuthToken != "" || c.AuthTokenFile != "" {
+		return &GatewayAuth{Token: c.AuthToken, TokenFile: c.AuthTokenFile}
+	}
+	return nil
+}
+
+// Validate 校验网关认证配置
+func (a *GatewayAuth) Validate() error {
+	if a.Token != "" && a.TokenFile != "" {
+		return fmt.Errorf("at most one of token & token_file must be configured")
+	}
+	if a.BearerToken != "" && a.BearerTokenFile != "" {
+		return fmt.Errorf("at most one of bearer_token & bearer_token_file must be configured")
+	}
+	if a.HMAC != nil && (a.HMAC.Secret == "") == (a.HMAC.SecretFile == "") {
+		return fmt.Errorf("exactly one of hmac secret & secret_file must be configured")
+	}
+	if (a.TLSConfig.CertFile == "") != (a.TLSConfig.KeyFile == "") {
+		return fmt.Errorf("tls_config cert_file and key_file must be configured together")
+	}
+	cfg := a.HTTPClientConfig()
+	return cfg.Validate()
+}
+
+// HTTPClientConfig 转换为 Prometheus 通用的 HTTP 客户端配置, Token 请求头和 HMAC 签名不包含在内
+func (a *GatewayAuth) HTTPClientConfig() config_util.HTTPClientConfig {
+	cfg := config_util.DefaultHTTPClientConfig
+
+	if a.BearerToken != "" || a.BearerTokenFile != "" {
+		cfg.Authorization = &config_util.Authorization{
+			Type:            "Bearer",
+			Credentials:     a.BearerToken,
+			CredentialsFile: a.BearerTokenFile,
+		}
+	}
+
+	cfg.TLSConfig = config_util.TLSConfig{
+		CAFile:             a.TLSConfig.CAFile,
+		CertFile:           a.TLSConfig.CertFile,
+		KeyFile:            a.TLSConfig.KeyFile,
+		ServerName:         a.TLSConfig.ServerName,
+		InsecureSkipVerify: a.TLSConfig.InsecureSkipVerify,
+	}
+
+	return cfg
+}
+
+// ReadToken 返回 Token 请求头的取值, 配置了 token_file 时每次读取文件以便轮换
+func (a *GatewayAuth) ReadToken() (string, error) {
+	if a.TokenFile == "" {
+		return string(a.Token), nil
+	}
+	return ReadSecretFile(a.TokenFile)
+}
+
+// ReadSecret 返回 HMAC 签名密钥, 配置了 secret_file 时每次读取文件以便轮换
+func (h *HMACConfig) ReadSecret() (string, error) {
+	if h.SecretFile == "" {
+		return string(h.Secret), nil
+	}
+	return ReadSecretFile(h.SecretFile)
+}
+
+// checkFiles 检查网关认证配置引用的文件是否可读
+func (a *GatewayAuth) checkFiles(prefix string) []error {
+	var errs []error
+	files := [][2]string{
+		{"token_file", a.TokenFile},
+		{"bearer_token_file", a.BearerTokenFile},
+		{"tls_config.ca_file", a.TLSConfig.CAFile},
+		{"tls_config.cert_file", a.TLSConfig.CertFile},
+		{"tls_config.key_file", a.TLSConfig.KeyFile},
+	}
+	if a.HMAC != nil {
+		files = append(files, [2]string{"hmac.secret_file", a.HMAC.SecretFile})
+	}
+	for _, f := range files {
+		if err := checkFile(prefix+"."+f[0], f[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
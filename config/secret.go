@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Token 返回访问网关使用的认证Token, 配置了 auth_token_file 时每次读取文件以便轮换
+func (c *Config) Token() (string, error) {
+	if c.AuthTokenFile == "" {
+		return string(c.AuthToken), nil
+	}
+	return ReadSecretFile(c.AuthTokenFile)
+}
+
+// String 返回 YAML 格式的配置, 密钥和密码显示为 <secret>, 用于日志和接口展示
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprintf("<error creating config string: %s>", err)
+	}
+	return string(out)
+}
+
+// ReadSecretFile 读取密钥文件, 去除首尾空白
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// 规则来源类型
+const (
+	// SourceGateway 从网关接口获取规则和数据源
+	SourceGateway = "gateway"
+
+	// SourceFile 本地网关格式的规则文件, YAML 或 JSON, 可以同时定义数据源
+	SourceFile = "file"
+
+	// SourcePrometheus 原生 Prometheus 规则文件, 通过 datasource_mapping 指定规则所属的数据源
+	SourcePrometheus = "prometheus"
+)
+
+// RuleSourceConfig 规则来源配置. 配置多个来源时合并所有来源的规则和数据源, ID 冲突时配置在前的来源优先
+type RuleSourceConfig struct {
+	// 来源名称, 用于日志和冲突提示, 默认与类型相同
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// 来源类型: gateway, file, prometheus
+	Type string `yaml:"type" json:"type"`
+
+	// 规则文件路径, 支持通配符, 仅 file 和 prometheus 类型使用
+	Files []string `yaml:"files,omitempty" json:"files,omitempty"`
+
+	// 是否监听规则文件变化, 文件变化后立即同步规则, 默认开启
+	Watch *bool `yaml:"watch,omitempty" json:"watch,omitempty"`
+
+	// 原生规则所属的数据源, 仅 prometheus 类型使用
+	DatasourceMapping *DatasourceMapping `yaml:"datasource_mapping,omitempty" json:"datasource_mapping,omitempty"`
+}
+
+// DatasourceMapping 原生 Prometheus 规则组与数据源的对应关系
+type DatasourceMapping struct {
+	// 未单独指定的规则组使用的数据源ID, 为 0 时未指定的规则组视为配置错误
+	Default int64 `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// 按规则组名称指定数据源ID
+	Groups map[string]int64 `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// Sources 返回生效的规则来源, 未配置 rule_sources 时只使用网关
+func (c *Config) Sources() []RuleSourceConfig {
+	if len(c.RuleSources) == 0 {
+		return []RuleSourceConfig{{Name: SourceGateway, Type: SourceGateway}}
+	}
+
+	sources := make([]RuleSourceConfig, len(c.RuleSources))
+	for i, src := range c.RuleSources {
+		if src.Name == "" {
+			src.Name = src.Type
+		}
+		sources[i] = src
+	}
+	return sources
+}
+
+// WatchEnabled 判断是否监听规则文件变化
+func (c RuleSourceConfig) WatchEnabled() bool {
+	return c.Watch == nil || *c.Watch
+}
+
+// Validate 校验规则来源配置
+func (c RuleSourceConfig) Validate() error {
+	switch c.Type {
+	case SourceGateway:
+		if len(c.Files) > 0 || c.DatasourceMapping != nil {
+			return fmt.Errorf("files and datasource_mapping cannot be used with %s source", c.Type)
+		}
+		return nil
+	case SourceFile:
+		if c.DatasourceMapping != nil {
+			return fmt.Errorf("datasource_mapping cannot be used with %s source", c.Type)
+		}
+	case SourcePrometheus:
+		if c.DatasourceMapping == nil {
+			return fmt.Errorf("datasource_mapping is required for %s source", c.Type)
+		}
+		if err := c.DatasourceMapping.Validate(); err != nil {
+			return fmt.Errorf("datasource_mapping: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown source type %q, must be one of gateway, file, prometheus", c.Type)
+	}
+
+	if len(c.Files) == 0 {
+		return fmt.Errorf("files cannot be empty for %s source", c.Type)
+	}
+	for _, pattern := range c.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Validate 校验数据源映射
+func (m *DatasourceMapping) Validate() error {
+	if m.Default < 0 {
+		return fmt.Errorf("default datasource id cannot be negative")
+	}
+	if m.Default == 0 && len(m.Groups) == 0 {
+		return fmt.Errorf("at least one of default & groups must be configured")
+	}
+
+	groups := make([]string, 0, len(m.Groups))
+	for g := range m.Groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	for _, g := range groups {
+		if m.Groups[g] <= 0 {
+			return fmt.Errorf("group %q: datasource id must be positive", g)
+		}
+	}
+	return nil
+}
+
+// Datasource 返回规则组对应的数据源ID, 没有对应的数据源时返回 false
+func (m *DatasourceMapping) Datasource(group string) (int64, bool) {
+	if id, ok := m.Groups[group]; ok {
+		return id, true
+	}
+	return m.Default, m.Default > 0
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// WebConfig 本地HTTP服务配置, TLS 与认证部分的格式参考 Prometheus 的 web 配置文件
+type WebConfig struct {
+	// 各类接口的监听地址, 地址相同的接口共用同一个监听端口
+	ListenAddresses ListenAddresses `yaml:"listen_addresses" json:"listen_addresses"`
+
+	// 优雅退出时等待请求处理完成的最长时间
+	ShutdownTimeout model.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	// TLS 服务端配置, 为空时使用 HTTP
+	TLSServerConfig *TLSServerConfig `yaml:"tls_server_config" json:"tls_server_config"`
+
+	// Basic 认证用户, 值为 bcrypt 哈希后的密码
+	BasicAuthUsers map[string]config_util.Secret `yaml:"basic_auth_users" json:"basic_auth_users"`
+}
+
+// ListenAddresses 各类接口的监听地址
+type ListenAddresses struct {
+	// 本地API接口 /api/v1/*
+	API string `yaml:"api" json:"api"`
+
+	// 指标接口 /metrics, 为空时使用 metrics_port
+	Metrics string `yaml:"metrics" json:"metrics"`
+
+	// 健康检查接口 /-/healthy, /-/ready, 为空时与 api 共用
+	Health string `yaml:"health" json:"health"`
+
+	// 管理接口 /-/reload, /debug/pprof, 为空时与 api 共用
+	Admin string `yaml:"admin" json:"admin"`
+}
+
+// TLSServerConfig TLS 服务端配置, 证书文件变更后自动重新加载
+type TLSServerConfig struct {
+	// 服务端证书文件路径
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+
+	// 服务端私钥文件路径
+	KeyFile string `yaml:"key_file" json:"key_file"`
+
+	// 客户端证书校验方式: NoClientCert, RequestClientCert, RequireAnyClientCert,
+	// VerifyClientCertIfGiven, RequireAndVerifyClientCert
+	ClientAuthType string `yaml:"client_auth_type" json:"client_auth_type"`
+
+	// 校验客户端证书使用的 CA 文件路径
+	ClientCAFile string `yaml:"client_ca_file" json:"client_ca_file"`
+
+	// 最低 TLS 版本: TLS10, TLS11, TLS12, TLS13
+	MinVersion string `yaml:"min_version" json:"min_version"`
+}
+
+// Validate 校验 web 配置, 返回所有发现的问题, 错误信息以配置项路径开头
+func (c *WebConfig) Validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.ListenAddresses.API == "" {
+		add("listen_addresses.api cannot be empty")
+	}
+	for _, l := range []struct{ role, addr string }{
+		{"api", c.ListenAddresses.API},
+		{"metrics", c.ListenAddresses.Metrics},
+		{"health", c.ListenAddresses.Health},
+		{"admin", c.ListenAddresses.Admin},
+	} {
+		if l.addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(l.addr); err != nil {
+			add("listen_addresses.%s: invalid address %q: %v", l.role, l.addr, err)
+		}
+	}
+	if c.ShutdownTimeout < 0 {
+		add("shutdown_timeout cannot be negative")
+	}
+	if t := c.TLSServerConfig; t != nil {
+		if t.CertFile == "" || t.KeyFile == "" {
+			add("tls_server_config: both cert_file and key_file are required")
+		}
+		switch t.ClientAuthType {
+		case "", "NoClientCert", "RequestClientCert", "RequireAnyClientCert":
+		case "VerifyClientCertIfGiven", "RequireAndVerifyClientCert":
+			if t.ClientCAFile == "" {
+				add("tls_server_config.client_auth_type: %s requires client_ca_file", t.ClientAuthType)
+			}
+		default:
+			add("tls_server_config.client_auth_type: invalid value %q", t.ClientAuthType)
+		}
+		switch t.MinVersion {
+		case "", "TLS10", "TLS11", "TLS12", "TLS13":
+		default:
+			add("tls_server_config.min_version: invalid value %q", t.MinVersion)
+		}
+	}
+
+	users := make([]string, 0, len(c.BasicAuthUsers))
+	for user := range c.BasicAuthUsers {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		if user == "" {
+			add("basic_auth_users: user name cannot be empty")
+			continue
+		}
+		if _, err := bcrypt.Cost([]byte(c.BasicAuthUsers[user])); err != nil {
+			add("basic_auth_users: invalid bcrypt hash for user %q: %v", user, err)
+		}
+	}
+
+	return errs
+}
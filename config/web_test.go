@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+)
+
+func TestValidateWebReportsAllErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Web.ListenAddresses.Metrics = "9090"
+	cfg.Web.ShutdownTimeout = model.Duration(-1)
+	cfg.Web.TLSServerConfig = &TLSServerConfig{
+		CertFile:       "server.pem",
+		ClientAuthType: "RequireAndVerifyClientCert",
+		MinVersion:     "TLS9",
+	}
+	cfg.Web.BasicAuthUsers = map[string]config_util.Secret{"admin": "plaintext"}
+
+	err := cfg.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := []string{
+		`web.listen_addresses.metrics: invalid address "9090"`,
+		"web.shutdown_timeout cannot be negative",
+		"web.tls_server_config: both cert_file and key_file are required",
+		"web.tls_server_config.client_auth_type: RequireAndVerifyClientCert requires client_ca_file",
+		`web.tls_server_config.min_version: invalid value "TLS9"`,
+		`web.basic_auth_users: invalid bcrypt hash for user "admin"`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(errs), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(errs[i].Error(), w) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], w)
+		}
+	}
+}
+
+func TestValidateWebValid(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Web.Validate(); err != nil {
+		t.Errorf("unexpected errors: %v", err)
+	}
+}
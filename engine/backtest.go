@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+// maxBacktestPoints 单次回测的最大评估次数, 与 Prometheus 范围查询的点数限制一致
+const maxBacktestPoints = 11000
+
+// ErrInvalidRange 回测的时间范围无效
+var ErrInvalidRange = errors.New("invalid range")
+
+// BacktestEvent 回测过程中规则状态变化产生的告警事件
+type BacktestEvent struct {
+	Time    time.Time
+	State   string
+	Value   float64
+	Labels  common.Labels
+	Dropped bool
+}
+
+// BacktestResult 规则回测结果
+type BacktestResult struct {
+	Expr           string
+	Start          time.Time
+	End            time.Time
+	Step           time.Duration
+	Events         []BacktestEvent
+	FiringCount    int
+	FiringDuration time.Duration
+}
+
+// Backtest 使用范围查询回放规则在历史时间段内的评估过程, 状态变化与规则评估使用同一个状态机, 不修改规则状态, 也不发送通知.
+// step 为 0 时使用规则评估间隔.
+func (m *Manager) Backtest(ctx context.Context, r rule.Rule, start, end time.Time, step time.Duration) (*BacktestResult, error) {
+	if err := validateRule(r); err != nil {
+		return nil, err
+	}
+
+	if step == 0 {
+		step = m.Interval()
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("%w: step must be positive", ErrInvalidRange)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidRange)
+	}
+	if points := end.Sub(start)/step + 1; points > maxBacktestPoints {
+		return nil, fmt.Errorf("%w: exceeded maximum resolution of %d points, try increasing the step", ErrInvalidRange, maxBacktestPoints)
+	}
+
+	evalRule := newEvalRule(r)
+	value, _, err := m.promAPI.QueryRange(ctx, evalRule.Expr, v1.Range{Start: start, End: end, Step: step})
+	if err != nil {
+		return nil, err
+	}
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %s for range query", value.Type())
+	}
+	// 即时查询返回的向量按标签排序, 回放时与规则评估一样取第一个序列
+	sort.Sort(matrix)
+
+	res := &BacktestResult{
+		Expr:  evalRule.Expr,
+		Start: start,
+		End:   end,
+		Step:  step,
+	}
+
+	var e RuleEvaluator
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		hasValue, value, metricLabels := sampleAt(matrix, ts, step)
+		if hasValue {
+			evalRule.Labels = mergeMetricLabels(evalRule.RuleLabels, metricLabels)
+		}
+
+		prev := evalRule.State
+		state, snapshot := e.updateRuleState(&evalRule, hasValue, value, ts)
+
+		switch {
+		case prev == StateInactive && evalRule.State == StatePending:
+			res.Events = append(res.Events, m.backtestEvent(evalRule, "pending", ts))
+		case prev == StatePending && evalRule.State == StateInactive:
+			res.Events = append(res.Events, m.backtestEvent(evalRule, "inactive", ts))
+		case prev == StatePending && evalRule.State == StateFiring:
+			res.Events = append(res.Events, m.backtestEvent(snapshot, state, ts))
+			res.FiringCount++
+		case state == "resolved":
+			res.Events = append(res.Events, m.backtestEvent(snapshot, state, ts))
+			res.FiringDuration += ts.Sub(snapshot.FiredAt)
+		}
+	}
+	if evalRule.State == StateFiring {
+		res.FiringDuration += end.Sub(evalRule.FiredAt)
+	}
+
+	return res, nil
+}
+
+// BacktestRule 在指定数据源上回测规则, 只创建数据源客户端, 不创建管理器. 用于不运行告警引擎的回测子命令
+func BacktestRule(ctx context.Context, prom rule.Prom, cfg *config.Config, r rule.Rule, start, end time.Time, step time.Duration, logger *zap.Logger) (*BacktestResult, error) {
+	client, err := newPromClient(prom, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prometheus client: %w", err)
+	}
+
+	m := &Manager{
+		prom:    prom,
+		promAPI: v1.NewAPI(client),
+		logger:  logger,
+	}
+	m.cfg.Store(cfg)
+	m.evaluator = &RuleEvaluator{interval: time.Duration(cfg.EvaluationInterval)}
+	return m.Backtest(ctx, r, start, end, step)
+}
+
+// backtestEvent 生成回测事件, 标签经过与通知相同的处理
+func (m *Manager) backtestEvent(r EvalRule, state string, ts time.Time) BacktestEvent {
+	event := BacktestEvent{
+		Time:  ts,
+		State: state,
+		Value: r.LastValue,
+	}
+	lset, keep := m.processLabels(r)
+	if !keep {
+		event.Labels = r.Labels
+		event.Dropped = true
+	} else {
+		event.Labels = lset
+	}
+	return event
+}
+
+// sampleAt 返回第一个在指定时间点有值的序列, 样本时间与评估时间的偏差在半个步长以内即视为匹配
+func sampleAt(matrix model.Matrix, ts time.Time, step time.Duration) (bool, float64, map[string]string) {
+	from := model.TimeFromUnixNano(ts.Add(-step / 2).UnixNano())
+	to := model.TimeFromUnixNano(ts.Add(step / 2).UnixNano())
+
+	for _, series := range matrix {
+		i := sort.Search(len(series.Values), func(i int) bool {
+			return series.Values[i].Timestamp.After(from)
+		})
+		if i == len(series.Values) || series.Values[i].Timestamp.After(to) {
+			continue
+		}
+
+		labels := make(map[string]string, len(series.Metric))
+		for k, v := range series.Metric {
+			labels[string(k)] = string(v)
+		}
+		return true, float64(series.Values[i].Value), labels
+	}
+	return false, 0, nil
+}
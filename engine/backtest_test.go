@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alertengine/config"
+	"alertengine/rule"
+
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// newTestRangeServer 模拟 Prometheus 范围查询接口, series 为序列标签到各时间点取值的映射, 缺少的时间点没有样本
+func newTestRangeServer(t *testing.T, start time.Time, series map[string]map[int]float64) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		queries = append(queries, r.FormValue("query"))
+		mu.Unlock()
+
+		var result []map[string]interface{}
+		for instance, points := range series {
+			var values [][]interface{}
+			for i := 0; i < 60; i++ {
+				if v, ok := points[i]; ok {
+					values = append(values, []interface{}{start.Add(time.Duration(i) * time.Minute).Unix(), fmt.Sprint(v)})
+				}
+			}
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{"__name__": "node_load1", "instance": instance},
+				"values": values,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "matrix", "result": result},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), queries...)
+	}
+}
+
+func TestBacktestRule(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	srv, queries := newTestRangeServer(t, start, map[string]map[int]float64{
+		"node-1": {1: 5, 2: 6, 3: 7, 4: 8, 7: 5},
+		// node-1 没有样本时使用下一个序列
+		"node-2": {7: 9, 8: 9, 9: 9},
+	})
+
+	cfg := config.DefaultConfig()
+	if err := yaml.UnmarshalStrict([]byte("- source_labels: [instance]\n  regex: node-2\n  action: drop\n"), &cfg.AlertRelabelConfigs); err != nil {
+		t.Fatal(err)
+	}
+	r := rule.Rule{ID: 1, PromID: 1, Expr: "node_load1", Op: ">", Value: "4", For: "2m"}
+
+	res, err := BacktestRule(context.Background(), rule.Prom{ID: 1, URL: srv.URL}, cfg, r, start, start.Add(9*time.Minute), time.Minute, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := queries(); len(q) != 1 || !strings.Contains(q[0], "node_load1") {
+		t.Errorf("queries = %q", q)
+	}
+
+	want := []struct {
+		minute   int
+		state    string
+		instance string
+		dropped  bool
+	}{
+		{1, "pending", "node-1", false},
+		{3, "firing", "node-1", false},
+		{5, "resolved", "node-1", false},
+		{7, "pending", "node-1", false},
+		// 重标记丢弃的告警仍然记录事件
+		{9, "firing", "node-2", true},
+	}
+	if len(res.Events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(res.Events), len(want), res.Events)
+	}
+	for i, w := range want {
+		e := res.Events[i]
+		if !e.Time.Equal(start.Add(time.Duration(w.minute)*time.Minute)) || e.State != w.state ||
+			e.Labels.Get("instance") != w.instance || e.Dropped != w.dropped {
+			t.Errorf("event %d = %s %s %s dropped=%v, want %dm %s %s dropped=%v",
+				i, e.Time.Sub(start), e.State, e.Labels, e.Dropped, w.minute, w.state, w.instance, w.dropped)
+		}
+	}
+	if res.FiringCount != 2 || res.FiringDuration != 2*time.Minute {
+		t.Errorf("fired %d times for %s, want 2 times for 2m", res.FiringCount, res.FiringDuration)
+	}
+	if res.Events[1].Value != 7 || res.Events[1].Labels.Get("__name__") != "node_load1" {
+		t.Errorf("firing event = %+v", res.Events[1])
+	}
+}
+
+func TestBacktestStillFiringAtEnd(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	srv, _ := newTestRangeServer(t, start, map[string]map[int]float64{
+		"node-1": {0: 5, 1: 5, 2: 5, 3: 5, 4: 5},
+	})
+	r := rule.Rule{ID: 1, PromID: 1, Expr: "node_load1", For: "1m"}
+
+	// 未指定步长时使用评估间隔
+	cfg := config.DefaultConfig()
+	cfg.EvaluationInterval = model.Duration(time.Minute)
+	res, err := BacktestRule(context.Background(), rule.Prom{ID: 1, URL: srv.URL}, cfg, r, start, start.Add(4*time.Minute), 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Step != time.Duration(cfg.EvaluationInterval) {
+		t.Errorf("step = %s, want %s", res.Step, time.Duration(cfg.EvaluationInterval))
+	}
+	// 结束时仍然 firing 的告警计算到结束时间
+	if res.FiringCount != 1 || res.FiringDuration != 3*time.Minute {
+		t.Errorf("fired %d times for %s, want 1 time for 3m", res.FiringCount, res.FiringDuration)
+	}
+}
+
+func TestBacktestErrors(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	srv, queries := newTestRangeServer(t, start, nil)
+	prom := rule.Prom{ID: 1, URL: srv.URL}
+	valid := rule.Rule{ID: 1, PromID: 1, Expr: "up"}
+
+	tests := []struct {
+		name       string
+		rule       rule.Rule
+		end        time.Time
+		step       time.Duration
+		invalidErr bool
+		err        string
+	}{
+		{"negative step", valid, start.Add(time.Hour), -time.Minute, true, "step must be positive"},
+		{"end before start", valid, start.Add(-time.Hour), time.Minute, true, "end must be after start"},
+		{"end equals start", valid, start, time.Minute, true, "end must be after start"},
+		{"too many points", valid, start.Add(11000 * time.Second), time.Second, true, "exceeded maximum resolution"},
+		{"invalid rule", rule.Rule{ID: 1, PromID: 1}, start.Add(time.Hour), time.Minute, false, "expr is required"},
+	}
+
+	for _, tc := range tests {
+		_, err := BacktestRule(context.Background(), prom, config.DefaultConfig(), tc.rule, start, tc.end, tc.step, zap.NewNop())
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+			continue
+		}
+		if errors.Is(err, ErrInvalidRange) != tc.invalidErr {
+			t.Errorf("%s: errors.Is(ErrInvalidRange) = %v", tc.name, !tc.invalidErr)
+		}
+	}
+	// 参数无效时不查询数据源
+	if q := queries(); len(q) != 0 {
+		t.Errorf("got queries %q, want none", q)
+	}
+}
+
+func TestSampleAt(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	srv, _ := newTestRangeServer(t, start, map[string]map[int]float64{"node-1": {2: 1}})
+	// 步长为 5m 时 2m 的样本在 0m 评估点的半个步长以内
+	res, err := BacktestRule(context.Background(), rule.Prom{ID: 1, URL: srv.URL}, config.DefaultConfig(),
+		rule.Rule{ID: 1, PromID: 1, Expr: "up"}, start, start.Add(10*time.Minute), 5*time.Minute, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Events) == 0 || !res.Events[0].Time.Equal(start) {
+		t.Errorf("events = %+v, want the first event at start", res.Events)
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"alertengine/common"
+	"alertengine/rule"
+)
+
+// ErrInvalidRule 试运行的规则无效
+var ErrInvalidRule = errors.New("invalid rule")
+
+// TestResult 规则试运行结果
+type TestResult struct {
+	Expr        string
+	EvaluatedAt time.Time
+	Duration    time.Duration
+	Samples     []TestSample
+}
+
+// TestSample 试运行匹配到的序列, 以及该序列触发告警时发送的标签和注解
+type TestSample struct {
+	Metric      common.Labels
+	Value       float64
+	Labels      common.Labels
+	Annotations map[string]string
+	Dropped     bool
+	Inhibited   bool
+	Silenced    bool
+}
+
+// TestRule 使用与规则评估相同的查询和标签处理流程试运行规则, 不修改规则状态, 也不发送通知
+func (m *Manager) TestRule(ctx context.Context, r rule.Rule) (*TestResult, error) {
+	if err := validateRule(r); err != nil {
+		return nil, err
+	}
+
+	evalRule := newEvalRule(r)
+	start := time.Now()
+	samples, err := m.query(ctx, evalRule.Expr, start)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &TestResult{
+		Expr:        evalRule.Expr,
+		EvaluatedAt: start,
+		Duration:    time.Since(start),
+		Samples:     make([]TestSample, 0, len(samples)),
+	}
+	for _, s := range samples {
+		metric := make(map[string]string, len(s.Metric))
+		for k, v := range s.Metric {
+			metric[string(k)] = string(v)
+		}
+
+		alert := evalRule
+		alert.Labels = mergeMetricLabels(evalRule.RuleLabels, metric)
+		alert.LastValue = float64(s.Value)
+
+		sample := TestSample{
+			Metric:      common.FromMap(metric),
+			Value:       float64(s.Value),
+			Annotations: m.expandAnnotations(alert),
+		}
+		lset, keep := m.processLabels(alert)
+		if !keep {
+			sample.Dropped = true
+		} else {
+			alert.Labels = lset
+			sample.Labels = lset
+			sample.Inhibited = m.inhibitor != nil && m.inhibitor.Mutes(alert.muteLabels())
+			sample.Silenced = m.silencer != nil && m.silencer.Mutes(alert.muteLabels())
+		}
+		res.Samples = append(res.Samples, sample)
+	}
+
+	return res, nil
+}
+
+// validateRule 校验试运行和回测的规则
+func validateRule(r rule.Rule) error {
+	if strings.TrimSpace(r.Expr) == "" {
+		return fmt.Errorf("%w: expr is required", ErrInvalidRule)
+	}
+	if r.For != "" {
+		if _, err := time.ParseDuration(r.For); err != nil {
+			return fmt.Errorf("%w: invalid for duration %q: %v", ErrInvalidRule, r.For, err)
+		}
+	}
+	return nil
+}
@@ -5,10 +5,11 @@ package engine
 import (
 	"alertengine/common"
 	"context"
+	"sync"
 	"time"
 )
 
-type QueryFunc func(ctx context.Context, expr string) (bool, float64, map[string]string, error)
+type QueryFunc func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error)
 type NotifyFunc func(rule EvalRule, state string)
 
 type RuleEvaluator struct {
@@ -16,10 +17,106 @@ type RuleEvaluator struct {
 	interval   time.Duration
 	queryFunc  QueryFunc
 	notifyFunc NotifyFunc
+	mu         sync.RWMutex
+
+	// index 规则ID到 rules 下标的索引, 替换规则列表时清空, 查找时重建
+	index map[string]int
+
+	lastEvaluation time.Time
+	evaluationTime time.Duration
 }
 
 func (e *RuleEvaluator) UpdateRules(rules []EvalRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.rules = rules
+	e.index = nil
+}
+
+// applyRules 替换规则列表, keep 中的规则沿用当前的评估状态, 其余规则从初始状态开始评估
+func (e *RuleEvaluator) applyRules(rules []EvalRule, keep map[string]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	current := make(map[string]EvalRule, len(e.rules))
+	for _, r := range e.rules {
+		current[r.ID] = r
+	}
+	for i, r := range rules {
+		if old, ok := current[r.ID]; ok && keep[r.ID] {
+			rules[i] = old
+		}
+	}
+	e.rules = rules
+	e.index = nil
+}
+
+// lookup 返回评估开始时规则列表 rules 中第 i 条规则在当前规则列表中的位置, 调用方需持有写锁.
+// 评估期间规则列表被替换时按规则ID查找, 规则被删除或修改时返回 nil, 本次评估结果丢弃.
+func (e *RuleEvaluator) lookup(rules []EvalRule, i int) *EvalRule {
+	if len(e.rules) == len(rules) && &e.rules[0] == &rules[0] {
+		return &e.rules[i]
+	}
+
+	return e.find(rules[i])
+}
+
+// find 按规则ID查找当前规则列表中的规则, 规则已被删除或修改时返回 nil, 调用方需持有写锁
+func (e *RuleEvaluator) find(rule EvalRule) *EvalRule {
+	if e.index == nil {
+		e.index = make(map[string]int, len(e.rules))
+		for j, r := range e.rules {
+			e.index[r.ID] = j
+		}
+	}
+	j, ok := e.index[rule.ID]
+	if !ok || e.rules[j].generation != rule.generation {
+		return nil
+	}
+	return &e.rules[j]
+}
+
+// setAlertLabels 记录规则本次评估处理后的告警标签, 规则已被替换或已经重新评估时忽略
+func (e *RuleEvaluator) setAlertLabels(rule EvalRule, lset common.Labels, keep bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r := e.find(rule)
+	if r == nil || !r.LastEvaluation.Equal(rule.LastEvaluation) {
+		return
+	}
+	r.alertLabels, r.alertKeep, r.alertLabelsAt = lset, keep, rule.LastEvaluation
+}
+
+// Rules 返回所有规则的快照
+func (e *RuleEvaluator) Rules() []EvalRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules := make([]EvalRule, len(e.rules))
+	copy(rules, e.rules)
+	return rules
+}
+
+// LastEvaluation 返回最近一轮评估的开始时间和耗时
+func (e *RuleEvaluator) LastEvaluation() (time.Time, time.Duration) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lastEvaluation, e.evaluationTime
+}
+
+// FiringRules 返回当前处于 firing 状态的规则快照
+func (e *RuleEvaluator) FiringRules() []EvalRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var rules []EvalRule
+	for _, rule := range e.rules {
+		if rule.State == StateFiring {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
 }
 
 func (e *RuleEvaluator) Run(ctx context.Context) {
@@ -31,41 +128,62 @@ func (e *RuleEvaluator) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			e.evaluate(ctx)
+			e.evaluate(ctx, time.Now())
 		}
 	}
 }
 
-func (e *RuleEvaluator) evaluate(ctx context.Context) {
-	now := time.Now()
+// evaluate 以 now 作为评估时间执行一轮规则评估
+func (e *RuleEvaluator) evaluate(ctx context.Context, now time.Time) {
+	evalStart := time.Now()
+
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
 
-	for i := range e.rules {
-		rule := &e.rules[i]
+	// rules 只用于读取规则定义, 评估结果写入当前的规则列表, 评估期间规则列表可能被 applyRules 替换
+	for i := range rules {
+		start := time.Now()
+		hasValue, value, metricLabels, err := e.queryFunc(ctx, rules[i].Expr, now)
 
-		hasValue, value, metricLabels, err := e.queryFunc(ctx, rule.Expr)
+		e.mu.Lock()
+		rule := e.lookup(rules, i)
+		if rule == nil {
+			e.mu.Unlock()
+			continue
+		}
+		rule.LastEvaluation = start
+		rule.EvaluationDuration = time.Since(start)
 		if err != nil {
+			rule.Health = HealthBad
+			rule.LastError = err.Error()
+			e.mu.Unlock()
 			continue
 		}
+		rule.Health = HealthGood
+		rule.LastError = ""
 
 		if hasValue && metricLabels != nil {
-			mergedLabels := make(map[string]string)
+			rule.Labels = mergeMetricLabels(rule.RuleLabels, metricLabels)
+		}
 
-			for _, label := range rule.Labels {
-				mergedLabels[label.Name] = label.Value
-			}
+		state, snapshot := e.updateRuleState(rule, hasValue, value, now)
+		e.mu.Unlock()
 
-			for k, v := range metricLabels {
-				mergedLabels[k] = v
-			}
-			
-			rule.Labels = common.FromMap(mergedLabels)
+		// 通知在锁外发送, 抑制判断需要读取所有评估器的告警状态
+		if state != "" && e.notifyFunc != nil {
+			e.notifyFunc(snapshot, state)
 		}
-
-		e.updateRuleState(rule, hasValue, value, now)
 	}
+
+	e.mu.Lock()
+	e.lastEvaluation = now
+	e.evaluationTime = time.Since(evalStart)
+	e.mu.Unlock()
 }
 
-func (e *RuleEvaluator) updateRuleState(rule *EvalRule, hasValue bool, value float64, now time.Time) {
+// updateRuleState 推进规则状态机, 返回需要发送的通知状态及发送时的规则快照, 无需通知时状态为空
+func (e *RuleEvaluator) updateRuleState(rule *EvalRule, hasValue bool, value float64, now time.Time) (string, EvalRule) {
 	rule.LastValue = value
 
 	switch rule.State {
@@ -82,24 +200,32 @@ func (e *RuleEvaluator) updateRuleState(rule *EvalRule, hasValue bool, value flo
 		} else if now.Sub(rule.ActiveAt) >= rule.For {
 			rule.State = StateFiring
 			rule.FiredAt = now
-			if e.notifyFunc != nil {
-				e.notifyFunc(*rule, "firing")
-			}
+			return "firing", *rule
 		}
 
 	case StateFiring:
 		if !hasValue {
-			if e.notifyFunc != nil {
-				e.notifyFunc(*rule, "resolved")
-			}
+			snapshot := *rule
 			rule.State = StateInactive
 			rule.ActiveAt = time.Time{}
 			rule.FiredAt = time.Time{}
-		} else {
-			// 持续 firing
-			if e.notifyFunc != nil {
-				e.notifyFunc(*rule, "firing")
-			}
+			return "resolved", snapshot
 		}
+		// 持续 firing
+		return "firing", *rule
+	}
+
+	return "", EvalRule{}
+}
+
+// mergeMetricLabels 合并规则标签与查询结果的序列标签, 序列标签优先
+func mergeMetricLabels(ruleLabels common.Labels, metricLabels map[string]string) common.Labels {
+	merged := make(map[string]string, len(ruleLabels)+len(metricLabels))
+	for _, label := range ruleLabels {
+		merged[label.Name] = label.Value
+	}
+	for k, v := range metricLabels {
+		merged[k] = v
 	}
+	return common.FromMap(merged)
 }
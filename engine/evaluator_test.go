@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"alertengine/rule"
+)
+
+func testEvalRule(id int64, value string) EvalRule {
+	return newEvalRule(rule.Rule{ID: id, PromID: 1, Expr: "up", Op: ">", Value: value})
+}
+
+func findEvalRule(rules []EvalRule, id string) (EvalRule, bool) {
+	for _, r := range rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return EvalRule{}, false
+}
+
+func TestEvaluatorApplyRulesDuringEvaluation(t *testing.T) {
+	var (
+		firing   = true
+		applyNow func()
+		notified []string
+	)
+	e := &RuleEvaluator{
+		rules: []EvalRule{testEvalRule(1, "0"), testEvalRule(2, "0"), testEvalRule(3, "0")},
+		queryFunc: func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+			if applyNow != nil {
+				// 第一条规则查询期间规则列表被替换
+				applyNow()
+				applyNow = nil
+			}
+			return firing, 1, nil, nil
+		},
+		notifyFunc: func(r EvalRule, state string) {
+			notified = append(notified, fmt.Sprintf("%s:%s", r.ID, state))
+		},
+	}
+
+	t0 := time.Now()
+	e.evaluate(context.Background(), t0)
+	for _, r := range e.Rules() {
+		if r.State != StatePending {
+			t.Fatalf("rule %s: state = %v, want pending", r.ID, r.State)
+		}
+	}
+
+	// 规则 1 未变化, 规则 2 被修改, 规则 3 被删除, 新增规则 4
+	applyNow = func() {
+		e.applyRules(
+			[]EvalRule{testEvalRule(1, "0"), testEvalRule(2, "5"), testEvalRule(4, "0")},
+			map[string]bool{"1": true},
+		)
+	}
+	t1 := t0.Add(time.Minute)
+	e.evaluate(context.Background(), t1)
+
+	rules := e.Rules()
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	r1, _ := findEvalRule(rules, "1")
+	if r1.State != StateFiring || !r1.ActiveAt.Equal(t0) || !r1.FiredAt.Equal(t1) {
+		t.Errorf("unchanged rule lost its state: state=%v active_at=%v fired_at=%v", r1.State, r1.ActiveAt, r1.FiredAt)
+	}
+	// 修改后的规则丢弃旧规则的评估结果, 从初始状态开始
+	if r2, _ := findEvalRule(rules, "2"); r2.State != StateInactive || !r2.LastEvaluation.IsZero() {
+		t.Errorf("modified rule: state=%v last_evaluation=%v, want inactive and not evaluated", r2.State, r2.LastEvaluation)
+	}
+	if r4, _ := findEvalRule(rules, "4"); r4.State != StateInactive {
+		t.Errorf("added rule: state = %v, want inactive", r4.State)
+	}
+	if _, ok := findEvalRule(rules, "3"); ok {
+		t.Error("removed rule is still present")
+	}
+
+	// 告警恢复期间再次替换规则列表, 恢复状态同样保留
+	firing = false
+	applyNow = func() {
+		e.applyRules([]EvalRule{testEvalRule(1, "0")}, map[string]bool{"1": true})
+	}
+	e.evaluate(context.Background(), t1.Add(time.Minute))
+	if r1, _ := findEvalRule(e.Rules(), "1"); r1.State != StateInactive || !r1.ActiveAt.IsZero() {
+		t.Errorf("resolved rule: state=%v active_at=%v, want inactive", r1.State, r1.ActiveAt)
+	}
+
+	// 之后的评估不会重复发送 firing 或 resolved
+	e.evaluate(context.Background(), t1.Add(2*time.Minute))
+
+	want := []string{"1:firing", "1:resolved"}
+	if fmt.Sprint(notified) != fmt.Sprint(want) {
+		t.Errorf("notifications = %v, want %v", notified, want)
+	}
+}
+
+func TestEvaluatorDuplicateIDs(t *testing.T) {
+	// 单元测试的规则文件中告警名称可能重复, 规则列表未替换时按位置写入评估结果
+	a := testEvalRule(1, "0")
+	b := a
+	b.Expr = "down"
+	e := &RuleEvaluator{
+		rules: []EvalRule{a, b},
+		queryFunc: func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+			return expr == "down", 1, nil, nil
+		},
+	}
+
+	e.evaluate(context.Background(), time.Now())
+	rules := e.Rules()
+	if rules[0].State != StateInactive || rules[1].State != StatePending {
+		t.Errorf("states = %v, %v, want inactive, pending", rules[0].State, rules[1].State)
+	}
+}
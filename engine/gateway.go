@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alertengine/config"
+
+	config_util "github.com/prometheus/common/config"
+)
+
+const (
+	// signatureHeader HMAC 签名请求头, 取值为 sha256=<十六进制签名>
+	signatureHeader = "X-AlertEngine-Signature"
+
+	// timestampHeader 签名时间请求头, 取值为 Unix 秒级时间戳
+	timestampHeader = "X-AlertEngine-Timestamp"
+)
+
+// gatewayRoundTripper 为网关请求设置 Token 请求头和 HMAC 签名, Bearer 认证和 TLS 由内层处理
+type gatewayRoundTripper struct {
+	rt   http.RoundTripper
+	auth *config.GatewayAuth
+}
+
+// newGatewayRoundTripper 按网关认证配置创建请求客户端
+func newGatewayRoundTripper(cfg *config.Config) (*gatewayRoundTripper, error) {
+	auth := cfg.GatewayAuth()
+	if auth == nil {
+		return &gatewayRoundTripper{rt: http.DefaultTransport}, nil
+	}
+
+	rt, err := config_util.NewRoundTripperFromConfig(auth.HTTPClientConfig(), "gateway")
+	if err != nil {
+		return nil, err
+	}
+	return &gatewayRoundTripper{rt: rt, auth: auth}, nil
+}
+
+func (rt *gatewayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.auth == nil {
+		return rt.rt.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	if rt.auth.Token != "" || rt.auth.TokenFile != "" {
+		token, err := rt.auth.ReadToken()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Token", token)
+	}
+
+	if rt.auth.HMAC != nil {
+		secret, err := rt.auth.HMAC.ReadSecret()
+		if err != nil {
+			return nil, err
+		}
+		var body []byte
+		if req.GetBody != nil {
+			rc, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			body, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(timestampHeader, ts)
+		req.Header.Set(signatureHeader, "sha256="+signRequest(secret, ts, req.Method, req.URL.RequestURI(), body))
+	}
+
+	return rt.rt.RoundTrip(req)
+}
+
+// signRequest 计算请求签名, 签名内容为时间戳、请求方法、路径和请求体以换行符连接
+func signRequest(secret, ts, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, ts+"\n"+method+"\n"+uri+"\n")
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"alertengine/config"
+	"alertengine/rule"
+)
+
+func TestNotificationHMAC(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "hmac")
+	writeTestFile(t, secretFile, "secret-1\n")
+
+	type request struct {
+		uri, ts, signature, token string
+		body                      []byte
+	}
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{
+			uri:       r.URL.RequestURI(),
+			ts:        r.Header.Get(timestampHeader),
+			signature: r.Header.Get(signatureHeader),
+			token:     r.Header.Get("Token"),
+			body:      body,
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Gateway.URL = srv.URL
+	cfg.Gateway.NotifyPath = "/api/v1/alerts?source=alertengine"
+	cfg.Gateway.Auth = &config.GatewayAuth{
+		Token: "token-1",
+		HMAC:  &config.HMACConfig{SecretFile: secretFile},
+	}
+	cfg.NotifyRetries = 1
+	m := newTestManager(t, cfg, rule.Prom{ID: 1})
+
+	verify := func(secret string) {
+		t.Helper()
+		r := <-requests
+		if r.uri != cfg.Gateway.NotifyPath || r.token != "token-1" || len(r.body) == 0 {
+			t.Fatalf("unexpected request: %+v", r)
+		}
+		ts, err := strconv.ParseInt(r.ts, 10, 64)
+		if err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+			t.Errorf("timestamp = %q", r.ts)
+		}
+		// 签名覆盖时间戳、请求方法、路径及查询参数和请求体
+		if want := "sha256=" + signRequest(secret, r.ts, http.MethodPost, r.uri, r.body); r.signature != want {
+			t.Errorf("signature = %q, want %q", r.signature, want)
+		}
+		if r.signature == "sha256="+signRequest(secret, r.ts, http.MethodPost, r.uri, append(r.body, ' ')) {
+			t.Error("signature does not cover the body")
+		}
+	}
+
+	m.sendNotification(testEvalRule(1, "0"), "firing")
+	verify("secret-1")
+
+	// 密钥文件每次请求时重新读取
+	if err := os.WriteFile(secretFile, []byte("secret-2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m.sendNotification(testEvalRule(1, "0"), "resolved")
+	verify("secret-2")
+}
+
+func TestSignRequest(t *testing.T) {
+	// 与 README 中网关校验示例的计算方式一致:
+	// hmac.new(b"secret", b"1700000000\nPOST\n/api/v1/alerts\n" + b"[]", hashlib.sha256).hexdigest()
+	got := signRequest("secret", "1700000000", "POST", "/api/v1/alerts", []byte("[]"))
+	if want := "222da1c4accc63c4a243d1da10b24d7e68be70752e58fec05821143fedb56a1c"; got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
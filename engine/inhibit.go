@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+
+	"alertengine/common"
+	"alertengine/config"
+
+	"go.uber.org/zap"
+)
+
+// AlertsFunc 返回当前所有正在触发的告警标签
+type AlertsFunc func() []common.Labels
+
+// InhibitRule 告警抑制规则
+type InhibitRule struct {
+	SourceMatchers common.Matchers
+	TargetMatchers common.Matchers
+	Equal          []string
+}
+
+// Inhibitor 告警抑制器, 源告警触发期间抑制匹配的目标告警
+type Inhibitor struct {
+	rules  []*InhibitRule
+	alerts AlertsFunc
+	logger *zap.Logger
+	mu     sync.RWMutex
+}
+
+// NewInhibitor 创建告警抑制器, 无效的规则会被忽略
+func NewInhibitor(cfgs []config.InhibitRule, alerts AlertsFunc, logger *zap.Logger) *Inhibitor {
+	ih := &Inhibitor{
+		alerts: alerts,
+		logger: logger,
+	}
+	ih.ApplyConfig(cfgs)
+
+	return ih
+}
+
+// ApplyConfig 替换抑制规则, 无效的规则会被忽略
+func (ih *Inhibitor) ApplyConfig(cfgs []config.InhibitRule) {
+	var rules []*InhibitRule
+	for i, cfg := range cfgs {
+		r, err := newInhibitRule(cfg)
+		if err != nil {
+			ih.logger.Error("skipping invalid inhibit rule", zap.Int("index", i), zap.Error(err))
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	ih.mu.Lock()
+	ih.rules = rules
+	ih.mu.Unlock()
+}
+
+func newInhibitRule(cfg config.InhibitRule) (*InhibitRule, error) {
+	source, err := buildMatchers(cfg.SourceMatchers, cfg.SourceMatch, cfg.SourceMatchRE)
+	if err != nil {
+		return nil, err
+	}
+	target, err := buildMatchers(cfg.TargetMatchers, cfg.TargetMatch, cfg.TargetMatchRE)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InhibitRule{
+		SourceMatchers: source,
+		TargetMatchers: target,
+		Equal:          cfg.Equal,
+	}, nil
+}
+
+// buildMatchers 合并匹配器表达式与等值/正则匹配配置
+func buildMatchers(selectors []string, match, matchRE map[string]string) (common.Matchers, error) {
+	var ms common.Matchers
+	for _, s := range selectors {
+		parsed, err := common.ParseMatchers(s)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, parsed...)
+	}
+	for name, value := range match {
+		m, err := common.NewMatcher(common.MatchEqual, name, value)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	for name, value := range matchRE {
+		m, err := common.NewMatcher(common.MatchRegexp, name, value)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	return ms, nil
+}
+
+// Mutes 判断目标告警是否被某个正在触发的源告警抑制
+func (ih *Inhibitor) Mutes(lset common.Labels) bool {
+	ih.mu.RLock()
+	rules := ih.rules
+	ih.mu.RUnlock()
+
+	if len(rules) == 0 {
+		return false
+	}
+
+	var alerts []common.Labels
+	for _, r := range rules {
+		if !r.TargetMatchers.Matches(lset) {
+			continue
+		}
+		if alerts == nil {
+			alerts = ih.alerts()
+		}
+		for _, source := range alerts {
+			if r.inhibits(source, lset) {
+				ih.logger.Debug("alert inhibited",
+					zap.String("target", lset.String()),
+					zap.String("source", source.String()),
+				)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// inhibits 判断源告警是否抑制目标告警, 告警不会抑制自身
+func (r *InhibitRule) inhibits(source, target common.Labels) bool {
+	if common.Equal(source, target) {
+		return false
+	}
+	if !r.SourceMatchers.Matches(source) {
+		return false
+	}
+	for _, name := range r.Equal {
+		if source.Get(name) != target.Get(name) {
+			return false
+		}
+	}
+	return true
+}
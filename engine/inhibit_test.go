@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	"go.uber.org/zap"
+)
+
+func TestInhibitorMutes(t *testing.T) {
+	var calls atomic.Int32
+	var alerts []common.Labels
+	ih := NewInhibitor([]config.InhibitRule{
+		{
+			SourceMatchers: []string{`severity="critical"`},
+			TargetMatchers: []string{`severity=~"warning|info"`},
+			Equal:          []string{"instance", "cluster"},
+		},
+		{
+			SourceMatch:   map[string]string{"alertname": "NodeDown"},
+			TargetMatchRE: map[string]string{"alertname": "Node.*"},
+		},
+		// 无效的规则被忽略
+		{SourceMatchers: []string{`severity=~"("`}},
+	}, func() []common.Labels {
+		calls.Add(1)
+		return alerts
+	}, zap.NewNop())
+
+	critical := common.FromMap(map[string]string{"alertname": "HighLoad", "severity": "critical", "instance": "node-1"})
+	alerts = []common.Labels{critical}
+
+	tests := []struct {
+		name  string
+		alert map[string]string
+		muted bool
+	}{
+		{"same instance", map[string]string{"alertname": "HighLoad", "severity": "warning", "instance": "node-1"}, true},
+		{"other instance", map[string]string{"alertname": "HighLoad", "severity": "warning", "instance": "node-2"}, false},
+		// equal 中的标签只在目标告警中存在时取值不同
+		{"missing equal label", map[string]string{"alertname": "HighLoad", "severity": "info", "instance": "node-1", "cluster": "prod"}, false},
+		{"target not matched", map[string]string{"alertname": "HighLoad", "severity": "critical", "instance": "node-1", "job": "node"}, false},
+		// 源告警同时匹配目标匹配器时不抑制自身
+		{"self", critical.Map(), false},
+	}
+	for _, tc := range tests {
+		if got := ih.Mutes(common.FromMap(tc.alert)); got != tc.muted {
+			t.Errorf("%s: muted = %v, want %v", tc.name, got, tc.muted)
+		}
+	}
+
+	// 目标告警不匹配任何规则时不获取告警列表
+	calls.Store(0)
+	if ih.Mutes(common.FromMap(map[string]string{"alertname": "Other"})) || calls.Load() != 0 {
+		t.Errorf("got %d calls to the alerts func, want 0", calls.Load())
+	}
+
+	alerts = []common.Labels{common.FromMap(map[string]string{"alertname": "NodeDown"})}
+	if !ih.Mutes(common.FromMap(map[string]string{"alertname": "NodeHighLoad", "instance": "node-3"})) {
+		t.Error("NodeHighLoad should be inhibited by NodeDown")
+	}
+	if ih.Mutes(common.FromMap(map[string]string{"alertname": "NodeDown"})) {
+		t.Error("NodeDown should not inhibit itself")
+	}
+
+	ih.ApplyConfig(nil)
+	if ih.Mutes(common.FromMap(map[string]string{"alertname": "NodeHighLoad"})) {
+		t.Error("alert muted after inhibit rules were removed")
+	}
+}
+
+func TestInhibitRuleInhibits(t *testing.T) {
+	r, err := newInhibitRule(config.InhibitRule{
+		SourceMatchers: []string{`alertname="ClusterDown"`},
+		Equal:          []string{"cluster"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := common.FromMap(map[string]string{"alertname": "ClusterDown", "cluster": "prod"})
+	tests := []struct {
+		source map[string]string
+		target map[string]string
+		want   bool
+	}{
+		{source.Map(), map[string]string{"alertname": "HighLoad", "cluster": "prod"}, true},
+		{source.Map(), map[string]string{"alertname": "HighLoad", "cluster": "test"}, false},
+		{source.Map(), map[string]string{"alertname": "HighLoad"}, false},
+		// 源告警与目标告警角色不能互换
+		{map[string]string{"alertname": "HighLoad", "cluster": "prod"}, source.Map(), false},
+		{source.Map(), source.Map(), false},
+	}
+	for _, tc := range tests {
+		if got := r.inhibits(common.FromMap(tc.source), common.FromMap(tc.target)); got != tc.want {
+			t.Errorf("inhibits(%v, %v) = %v, want %v", tc.source, tc.target, got, tc.want)
+		}
+	}
+}
+
+func TestManagerInhibition(t *testing.T) {
+	m, n := newNotifyTestManager(t)
+	m.inhibitor = NewInhibitor([]config.InhibitRule{{
+		SourceMatchers: []string{`severity="critical"`},
+		TargetMatchers: []string{`severity="warning"`},
+		Equal:          []string{"instance"},
+	}}, m.firingAlerts, zap.NewNop())
+
+	instances := map[string]string{"node_down": "node-1", "node_load": "node-1"}
+	m.evaluator.queryFunc = func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+		return true, 1, map[string]string{"instance": instances[expr]}, nil
+	}
+	m.evaluator.UpdateRules([]EvalRule{
+		newEvalRule(rule.Rule{ID: 1, PromID: 1, Expr: "node_down", Labels: common.FromMap(map[string]string{"severity": "critical"})}),
+		newEvalRule(rule.Rule{ID: 2, PromID: 1, Expr: "node_load", Labels: common.FromMap(map[string]string{"severity": "{{ if gt $value 0.0 }}warning{{ end }}"})}),
+	})
+
+	now := time.Now()
+	step := func(want string) {
+		t.Helper()
+		n.mu.Lock()
+		n.alerts = nil
+		n.mu.Unlock()
+
+		m.evaluator.evaluate(context.Background(), now)
+		now = now.Add(time.Minute)
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		var got []string
+		for _, a := range n.alerts {
+			got = append(got, a.Annotations["rule_id"]+"/"+a.Labels.Get("severity"))
+		}
+		if fmt.Sprint(got) != want {
+			t.Fatalf("notifications = %v, want %s", got, want)
+		}
+	}
+
+	step("[]")
+	// 目标告警的标签经过模板渲染后匹配抑制规则
+	step("[1/critical]")
+
+	// 处理后的告警标签记录在规则中, 同一次评估内不再重复渲染
+	for _, r := range m.evaluator.FiringRules() {
+		if !r.alertLabelsAt.Equal(r.LastEvaluation) || !r.alertKeep {
+			t.Errorf("rule %s: alert labels not recorded", r.ID)
+		}
+	}
+	if got := fmt.Sprint(m.firingAlerts()); got != `[{alertname="1", instance="node-1", severity="critical"} {alertname="2", instance="node-1", severity="warning"}]` {
+		t.Errorf("firing alerts = %s", got)
+	}
+
+	instances["node_load"] = "node-2"
+	step("[1/critical 2/warning]")
+}
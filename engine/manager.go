@@ -4,31 +4,47 @@ import (
 	"alertengine/common"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"alertengine/config"
+	"alertengine/relabel"
 	"alertengine/rule"
 
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"go.uber.org/zap"
 )
 
 // Manager 规则管理器
 type Manager struct {
-	prom      rule.Prom
-	rules     rule.Rules
-	config    *config.Config
+	prom  rule.Prom
+	rules rule.Rules
+	hash  string
+	// 最近一次从网关获取的规则内容哈希, 回滚时记录到固定版本中
+	gatewayHash string
+	// 保护规则更新和回滚
+	updateMu  sync.Mutex
+	cfg       atomic.Pointer[config.Config]
 	storage   *rule.Storage
+	silencer  Muter
+	inhibitor Muter
 	promAPI   v1.API
+	gateway   http.RoundTripper
 	evaluator *RuleEvaluator
 	logger    *zap.Logger
 	metrics   *Metrics
@@ -36,6 +52,11 @@ type Manager struct {
 	cancel    context.CancelFunc
 }
 
+// Muter 判断告警是否需要屏蔽通知
+type Muter interface {
+	Mutes(lset common.Labels) bool
+}
+
 type RuleState int
 
 const (
@@ -44,18 +65,61 @@ const (
 	StateFiring
 )
 
+func (s RuleState) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	default:
+		return "inactive"
+	}
+}
+
+// RuleHealth 规则健康状态
+type RuleHealth string
+
+const (
+	HealthUnknown RuleHealth = "unknown"
+	HealthGood    RuleHealth = "ok"
+	HealthBad     RuleHealth = "err"
+)
+
 // EvalRule 评估规则
 type EvalRule struct {
 	ID          string
 	PromID      int64
 	Expr        string
 	For         time.Duration
+	RuleLabels  common.Labels
 	Labels      common.Labels
 	Annotations map[string]string
 	State       RuleState
 	ActiveAt    time.Time
 	FiredAt     time.Time
 	LastValue   float64
+
+	Health             RuleHealth
+	LastError          string
+	LastEvaluation     time.Time
+	EvaluationDuration time.Duration
+
+	// generation 规则创建时分配的序号, 评估期间规则被修改时用于丢弃旧规则的评估结果
+	generation uint64
+
+	// alertLabels 经过模板渲染和重标记处理的告警标签, alertKeep 为 false 表示告警被重标记丢弃.
+	// alertLabelsAt 与 LastEvaluation 相同时为本次评估的结果, 告警抑制和通知共用, 每次评估只处理一次
+	alertLabels   common.Labels
+	alertKeep     bool
+	alertLabelsAt time.Time
+}
+
+// muteLabels 返回用于静默匹配的标签, 未设置 alertname 时以规则ID补充
+func (r EvalRule) muteLabels() common.Labels {
+	if r.Labels.Has(common.AlertName) {
+		return r.Labels
+	}
+	return common.NewBuilder(r.Labels).Set(common.AlertName, r.ID).Labels()
 }
 
 // Alert 告警数据
@@ -73,44 +137,36 @@ func NewManager(
 	prom rule.Prom,
 	cfg *config.Config,
 	storage *rule.Storage,
+	silencer Muter,
+	inhibitor Muter,
 	logger *zap.Logger,
 	metrics *Metrics,
 ) (*Manager, error) {
-	var client api.Client
-	var err error
-
-	if cfg.AuthToken != "" {
-		rt := &authRoundTripper{
-			rt:    api.DefaultRoundTripper,
-			token: cfg.AuthToken,
-		}
-		client, err = api.NewClient(api.Config{
-			Address:      prom.URL,
-			RoundTripper: rt,
-		})
-	} else {
-		client, err = api.NewClient(api.Config{
-			Address: prom.URL,
-		})
-	}
-
+	client, err := newPromClient(prom, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prometheus client: %w", err)
 	}
 
 	promAPI := v1.NewAPI(client)
+	gateway, err := newGatewayRoundTripper(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway client: %w", err)
+	}
 	mgrCtx, cancel := context.WithCancel(ctx)
 
 	m := &Manager{
-		prom:    prom,
-		config:  cfg,
-		storage: storage,
-		promAPI: promAPI,
-		logger:  logger,
-		metrics: metrics,
-		ctx:     mgrCtx,
-		cancel:  cancel,
+		prom:      prom,
+		storage:   storage,
+		silencer:  silencer,
+		inhibitor: inhibitor,
+		promAPI:   promAPI,
+		gateway:   gateway,
+		logger:    logger,
+		metrics:   metrics,
+		ctx:       mgrCtx,
+		cancel:    cancel,
 	}
+	m.cfg.Store(cfg)
 
 	m.evaluator = &RuleEvaluator{
 		rules:      []EvalRule{},
@@ -122,60 +178,308 @@ func NewManager(
 	return m, nil
 }
 
-func (m *Manager) Update(rules rule.Rules) error {
-	m.rules = rules
+// Update 更新规则. 规则文件内容未变化时跳过; 否则只替换新增和内容变化的规则, 其余规则保留评估状态.
+// 存在回滚固定的版本时, 网关规则与固定时相同则继续使用固定的版本, 网关规则变化后解除固定.
+func (m *Manager) Update(rules rule.Rules) (rule.RulesDiff, error) {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
 
-	content, err := rules.Content()
+	content, err := rules.Content(m.externalLabels())
 	if err != nil {
 		m.logger.Error("failed to generate rule content",
 			zap.Int64("prom_id", m.prom.ID),
 			zap.Error(err),
 		)
-		return err
+		return rule.RulesDiff{}, err
+	}
+	hash := contentHash(content)
+	m.gatewayHash = hash
+
+	pin, err := m.storage.Pinned(m.prom.ID)
+	if err != nil {
+		return rule.RulesDiff{}, err
+	}
+	if pin != nil {
+		if pin.GatewayHash == hash {
+			return m.applyPinned(pin)
+		}
+		if err := m.storage.Unpin(m.prom.ID); err != nil {
+			return rule.RulesDiff{}, err
+		}
+		m.logger.Info("gateway rules changed, unpinned rolled back version",
+			zap.Int64("prom_id", m.prom.ID),
+			zap.Int64("version", pin.Version),
+		)
+	}
+
+	if hash == m.hash {
+		m.logger.Debug("rules unchanged, skipping update", zap.Int64("prom_id", m.prom.ID))
+		return rule.RulesDiff{}, nil
+	}
+
+	diff, _, err := m.apply(rules, content, rule.SourceGateway, "")
+	return diff, err
+}
+
+// applyPinned 确保生效的规则为固定的版本, 管理器重建后首次更新时重新加载固定的版本
+func (m *Manager) applyPinned(pin *rule.Pin) (rule.RulesDiff, error) {
+	version, err := m.storage.Version(m.prom.ID, pin.Version)
+	if err != nil {
+		return rule.RulesDiff{}, fmt.Errorf("failed to load pinned version %d: %w", pin.Version, err)
+	}
+	if version.Hash == m.hash {
+		m.logger.Debug("rules pinned, skipping update",
+			zap.Int64("prom_id", m.prom.ID),
+			zap.Int64("version", pin.Version),
+		)
+		return rule.RulesDiff{}, nil
+	}
+
+	content, rules, err := m.readVersion(pin.Version)
+	if err != nil {
+		return rule.RulesDiff{}, err
+	}
+	diff, _, err := m.apply(rules, content, rule.SourceRollback, pin.Reason)
+	return diff, err
+}
+
+// Rollback 回滚到指定的历史版本, 并固定该版本直到网关规则发生变化. source 为生成的新版本的来源
+func (m *Manager) Rollback(version int64, source, reason string) (*rule.RuleVersion, rule.RulesDiff, error) {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+
+	if m.gatewayHash == "" {
+		return nil, rule.RulesDiff{}, errors.New("rules have not been loaded from gateway yet")
+	}
+
+	content, rules, err := m.readVersion(version)
+	if err != nil {
+		return nil, rule.RulesDiff{}, err
+	}
+	diff, saved, err := m.apply(rules, content, source, reason)
+	if err != nil {
+		return nil, rule.RulesDiff{}, err
+	}
+	if err := m.storage.Pin(m.prom.ID, saved.Version, m.gatewayHash, reason); err != nil {
+		return nil, rule.RulesDiff{}, fmt.Errorf("failed to pin version: %w", err)
+	}
+
+	m.logger.Info("rules rolled back",
+		zap.Int64("prom_id", m.prom.ID),
+		zap.Int64("from_version", version),
+		zap.Int64("pinned_version", saved.Version),
+		zap.String("source", source),
+		zap.String("reason", reason),
+	)
+	return saved, diff, nil
+}
+
+// readVersion 读取并解析历史版本的规则
+func (m *Manager) readVersion(version int64) ([]byte, rule.Rules, error) {
+	content, err := m.storage.ReadVersion(m.prom.ID, strconv.FormatInt(version, 10))
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := rule.ParseContent(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse version %d: %w", version, err)
 	}
+	return content, rules, nil
+}
 
-	_, err = m.storage.SaveRule(m.prom.ID, content)
+// apply 保存规则文件并替换生效的规则, 调用方需持有 updateMu
+func (m *Manager) apply(rules rule.Rules, content []byte, source, reason string) (rule.RulesDiff, *rule.RuleVersion, error) {
+	saved, err := m.storage.SaveRule(m.prom.ID, content, source, reason)
 	if err != nil {
 		m.logger.Error("failed to save rule file",
 			zap.Int64("prom_id", m.prom.ID),
 			zap.Error(err),
 		)
-		return err
+		return rule.RulesDiff{}, nil, err
+	}
+
+	externalLabels := m.externalLabels()
+	diff := rule.DiffRules(m.rules.Normalize(externalLabels), rules.Normalize(externalLabels))
+	changed := make(map[int64]bool, len(diff.Added)+len(diff.Modified))
+	for _, id := range diff.Added {
+		changed[id] = true
+	}
+	for _, id := range diff.Modified {
+		changed[id] = true
 	}
 
 	evalRules := make([]EvalRule, len(rules))
+	keep := make(map[string]bool, len(rules))
 	for i, r := range rules {
-		m.logger.Debug("processing rule",
-			zap.Int64("rule_id", r.ID),
-			zap.Any("original_rule_labels", r.Labels.Map()),
-			zap.String("original_rule_labels_str", r.Labels.String()),
-		)
-		forDuration, _ := time.ParseDuration(r.For)
-		evalRules[i] = EvalRule{
-			ID:     strconv.FormatInt(r.ID, 10),
-			PromID: r.PromID,
-			Expr:   strings.TrimSpace(r.Expr + " " + r.Op + " " + r.Value),
-			For:    forDuration,
-			Labels: r.Labels,
-			Annotations: map[string]string{
-				"rule_id":     strconv.FormatInt(r.ID, 10),
-				"prom_id":     strconv.FormatInt(r.PromID, 10),
-				"summary":     r.Summary,
-				"description": r.Description,
-			},
-			State: StateInactive,
+		evalRules[i] = newEvalRule(r)
+		if !changed[r.ID] {
+			keep[evalRules[i].ID] = true
 		}
 	}
+	m.evaluator.applyRules(evalRules, keep)
 
-	m.evaluator.UpdateRules(evalRules)
-	m.metrics.RulesLoaded.WithLabelValues(fmt.Sprintf("%d", m.prom.ID)).Set(float64(len(rules)))
+	m.rules = rules
+	m.hash = contentHash(content)
+
+	promID := strconv.FormatInt(m.prom.ID, 10)
+	m.metrics.RulesLoaded.WithLabelValues(promID).Set(float64(len(rules)))
+	m.metrics.RuleChanges.WithLabelValues(promID, "added").Add(float64(len(diff.Added)))
+	m.metrics.RuleChanges.WithLabelValues(promID, "removed").Add(float64(len(diff.Removed)))
+	m.metrics.RuleChanges.WithLabelValues(promID, "modified").Add(float64(len(diff.Modified)))
 
-	m.logger.Info("rules updated successfully",
+	fields := []zap.Field{
 		zap.Int64("prom_id", m.prom.ID),
+		zap.String("source", source),
 		zap.Int("rule_count", len(rules)),
-	)
+		zap.Int64s("added", diff.Added),
+		zap.Int64s("removed", diff.Removed),
+		zap.Int64s("modified", diff.Modified),
+	}
+	if saved != nil {
+		fields = append(fields, zap.Int64("version", saved.Version))
+	}
+	m.logger.Info("rules updated successfully", fields...)
+
+	return diff, saved, nil
+}
+
+// inherit 从被替换的管理器继承规则和评估状态
+func (m *Manager) inherit(old *Manager) {
+	old.updateMu.Lock()
+	defer old.updateMu.Unlock()
+
+	m.rules = old.rules
+	m.hash = old.hash
+	m.gatewayHash = old.gatewayHash
+	m.evaluator.UpdateRules(old.evaluator.Rules())
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// evalRuleGeneration 为每个新建的规则分配不同的 generation
+var evalRuleGeneration atomic.Uint64
+
+// newEvalRule 将网关规则转换为待评估的规则
+func newEvalRule(r rule.Rule) EvalRule {
+	forDuration, _ := time.ParseDuration(r.For)
+	return EvalRule{
+		ID:         strconv.FormatInt(r.ID, 10),
+		PromID:     r.PromID,
+		Expr:       strings.TrimSpace(r.Expr + " " + r.Op + " " + r.Value),
+		For:        forDuration,
+		RuleLabels: r.Labels,
+		Labels:     r.Labels,
+		Annotations: map[string]string{
+			"rule_id":     strconv.FormatInt(r.ID, 10),
+			"prom_id":     strconv.FormatInt(r.PromID, 10),
+			"summary":     r.Summary,
+			"description": r.Description,
+		},
+		State:      StateInactive,
+		Health:     HealthUnknown,
+		generation: evalRuleGeneration.Add(1),
+	}
+}
+
+// config 返回当前生效的配置
+func (m *Manager) config() *config.Config {
+	return m.cfg.Load()
+}
+
+// applyConfig 替换管理器使用的配置, 评估间隔和数据源认证的变更需要重建管理器
+func (m *Manager) applyConfig(cfg *config.Config) {
+	m.cfg.Store(cfg)
+}
+
+// needsRestart 判断新配置是否改变了评估间隔、网关认证或数据源认证
+func (m *Manager) needsRestart(cfg *config.Config) bool {
+	old := m.config()
+	if old.EvaluationInterval != cfg.EvaluationInterval {
+		return true
+	}
+	if !reflect.DeepEqual(old.GatewayAuth(), cfg.GatewayAuth()) {
+		return true
+	}
+
+	// 兼容使用的 auth_token 已包含在网关认证中
+	if old.DatasourceAuth.LegacyAuthToken != cfg.DatasourceAuth.LegacyAuthToken {
+		return true
+	}
+	oldAuth := old.DatasourceAuth.Resolve(m.prom.ID, m.prom.Auth)
+	newAuth := cfg.DatasourceAuth.Resolve(m.prom.ID, m.prom.Auth)
+	return !reflect.DeepEqual(oldAuth, newAuth)
+}
+
+// Prom 返回管理器对应的数据源
+func (m *Manager) Prom() rule.Prom {
+	return m.prom
+}
+
+// Interval 返回规则评估间隔
+func (m *Manager) Interval() time.Duration {
+	return m.evaluator.interval
+}
+
+// RuleFile 返回当前规则文件路径
+func (m *Manager) RuleFile() string {
+	return m.storage.GetCurrentRule(m.prom.ID)
+}
+
+// Rules 返回所有规则的当前状态
+func (m *Manager) Rules() []EvalRule {
+	return m.evaluator.Rules()
+}
+
+// LastEvaluation 返回最近一轮评估的开始时间和耗时
+func (m *Manager) LastEvaluation() (time.Time, time.Duration) {
+	return m.evaluator.LastEvaluation()
+}
+
+// ActiveAlert 处于 pending 或 firing 状态的告警实例
+type ActiveAlert struct {
+	RuleID      string
+	State       RuleState
+	Labels      common.Labels
+	Annotations map[string]string
+	Value       float64
+	ActiveAt    time.Time
+	FiredAt     time.Time
+}
+
+// ActiveAlert 返回规则当前的告警实例, 标签与注解经过与通知相同的处理, 无活跃告警或告警被重标记丢弃时返回 false
+func (m *Manager) ActiveAlert(rule EvalRule) (ActiveAlert, bool) {
+	if rule.State == StateInactive {
+		return ActiveAlert{}, false
+	}
+
+	lset, keep := m.processLabels(rule)
+	if !keep {
+		return ActiveAlert{}, false
+	}
 
-	return nil
+	return ActiveAlert{
+		RuleID:      rule.ID,
+		State:       rule.State,
+		Labels:      lset,
+		Annotations: m.expandAnnotations(rule),
+		Value:       rule.LastValue,
+		ActiveAt:    rule.ActiveAt,
+		FiredAt:     rule.FiredAt,
+	}, true
+}
+
+// ActiveAlerts 返回所有活跃的告警实例
+func (m *Manager) ActiveAlerts() []ActiveAlert {
+	var alerts []ActiveAlert
+	for _, rule := range m.evaluator.Rules() {
+		if alert, ok := m.ActiveAlert(rule); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
 }
 
 func (m *Manager) Run() {
@@ -188,42 +492,189 @@ func (m *Manager) Stop() {
 	m.cancel()
 }
 
-func (m *Manager) queryPrometheus(ctx context.Context, expr string) (bool, float64, map[string]string, error) {
-	value, _, err := m.promAPI.Query(ctx, expr, time.Now())
+func (m *Manager) queryPrometheus(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+	samples, err := m.query(ctx, expr, ts)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	if len(samples) == 0 {
+		return false, 0, nil, nil
+	}
+
+	labels := make(map[string]string, len(samples[0].Metric))
+	for k, v := range samples[0].Metric {
+		labels[string(k)] = string(v)
+	}
+	return true, float64(samples[0].Value), labels, nil
+}
+
+// query 执行即时查询, 返回所有匹配的序列, 标量结果转换为不带标签的单个样本
+func (m *Manager) query(ctx context.Context, expr string, ts time.Time) (model.Vector, error) {
+	value, _, err := m.promAPI.Query(ctx, expr, ts)
 	if err != nil {
 		m.logger.Debug("query failed",
 			zap.String("expr", expr),
 			zap.Error(err),
 		)
-		return false, 0, nil, err
+		return nil, err
 	}
 
 	switch v := value.(type) {
 	case model.Vector:
-		if len(v) > 0 {
-			labels := make(map[string]string)
-			for k, v := range v[0].Metric {
-				labels[string(k)] = string(v)
-			}
-			return true, float64(v[0].Value), labels, nil
+		if len(v) == 0 {
+			m.logger.Debug("query result vector empty", zap.String("expr", expr))
 		}
-		m.logger.Debug("query result vector empty", zap.String("expr", expr))
-		return false, 0, nil, nil
+		return v, nil
 	case *model.Scalar:
 		m.logger.Debug("query result scalar", zap.String("expr", expr), zap.Float64("value", float64(v.Value)))
-		return true, float64(v.Value), nil, nil
+		return model.Vector{&model.Sample{Metric: model.Metric{}, Value: v.Value, Timestamp: v.Timestamp}}, nil
 	default:
 		m.logger.Debug("query result unknown type", zap.String("expr", expr), zap.String("type", fmt.Sprintf("%T", v)))
-		return false, 0, nil, nil
+		return nil, nil
+	}
+}
+
+// externalLabels 返回当前数据源生效的外部标签, 数据源级别的标签覆盖全局标签
+func (m *Manager) externalLabels() common.Labels {
+	if len(m.prom.ExternalLabels) == 0 {
+		return m.config().ExternalLabels
+	}
+
+	lb := common.NewBuilder(m.config().ExternalLabels)
+	for _, l := range m.prom.ExternalLabels {
+		lb.Set(l.Name, l.Value)
+	}
+	return lb.Labels()
+}
+
+// templateData 返回规则当前的模板渲染数据
+func (m *Manager) templateData(rule EvalRule) templateData {
+	return templateData{
+		Labels:         rule.Labels.Map(),
+		ExternalLabels: m.externalLabels().Map(),
+		Value:          rule.LastValue,
+	}
+}
+
+// processLabels 返回告警发送时的标签: 渲染规则自身的标签模板, 合并序列标签和外部标签后执行重标记, 告警被丢弃时返回 false.
+// 序列标签来自查询结果, 原样使用, 不作为模板渲染.
+func (m *Manager) processLabels(rule EvalRule) (common.Labels, bool) {
+	data := m.templateData(rule)
+
+	lb := common.NewBuilder(rule.Labels)
+	for _, l := range rule.RuleLabels {
+		// 同名的序列标签优先, 见 mergeMetricLabels
+		if rule.Labels.Get(l.Name) != l.Value {
+			continue
+		}
+		v, err := expandTemplate("__alert_"+rule.ID, l.Value, data)
+		if err != nil {
+			m.logger.Warn("failed to expand label template",
+				zap.String("rule_id", rule.ID),
+				zap.String("label", l.Name),
+				zap.Error(err),
+			)
+			v = fmt.Sprintf("<error expanding template: %s>", err)
+		}
+		lb.Set(l.Name, v)
+	}
+	lset := lb.Labels().Merge(m.externalLabels())
+
+	if len(m.config().AlertRelabelConfigs) == 0 {
+		return lset, true
+	}
+	return relabel.Process(lset, m.config().AlertRelabelConfigs...)
+}
+
+// expandAnnotations 渲染注解模板, 返回新的注解集合
+func (m *Manager) expandAnnotations(rule EvalRule) map[string]string {
+	data := m.templateData(rule)
+
+	annotations := make(map[string]string, len(rule.Annotations))
+	for k, text := range rule.Annotations {
+		v, err := expandTemplate("__alert_"+rule.ID, text, data)
+		if err != nil {
+			m.logger.Warn("failed to expand annotation template",
+				zap.String("rule_id", rule.ID),
+				zap.String("annotation", k),
+				zap.Error(err),
+			)
+			v = fmt.Sprintf("<error expanding template: %s>", err)
+		}
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// alertLabels 返回规则处理后的告警标签, 本次评估已经处理过时使用记录的结果
+func (m *Manager) alertLabels(rule EvalRule) (common.Labels, bool) {
+	if !rule.alertLabelsAt.IsZero() && rule.alertLabelsAt.Equal(rule.LastEvaluation) {
+		return rule.alertLabels, rule.alertKeep
+	}
+	lset, keep := m.processLabels(rule)
+	m.evaluator.setAlertLabels(rule, lset, keep)
+	return lset, keep
+}
+
+// firingAlerts 返回当前处于 firing 状态的告警标签, 标签经过与通知相同的处理
+func (m *Manager) firingAlerts() []common.Labels {
+	var alerts []common.Labels
+	for _, rule := range m.evaluator.FiringRules() {
+		lset, keep := m.alertLabels(rule)
+		if !keep {
+			continue
+		}
+		rule.Labels = lset
+		alerts = append(alerts, rule.muteLabels())
 	}
+	return alerts
+}
+
+// mutes 判断 firing 通知是否被抑制或静默, rule 的标签需已经过 processLabels 处理
+func (m *Manager) mutes(rule EvalRule) bool {
+	if m.inhibitor != nil && m.inhibitor.Mutes(rule.muteLabels()) {
+		m.logger.Debug("notification inhibited",
+			zap.String("rule_id", rule.ID),
+			zap.String("labels", rule.Labels.String()),
+		)
+		m.metrics.NotificationsInhibited.Inc()
+		return true
+	}
+
+	if m.silencer != nil && m.silencer.Mutes(rule.muteLabels()) {
+		m.logger.Debug("notification silenced",
+			zap.String("rule_id", rule.ID),
+			zap.String("labels", rule.Labels.String()),
+		)
+		m.metrics.NotificationsSilenced.Inc()
+		return true
+	}
+	return false
 }
 
 func (m *Manager) sendNotification(rule EvalRule, state string) {
+	lset, keep := m.alertLabels(rule)
+	if !keep {
+		m.logger.Debug("notification dropped by relabeling",
+			zap.String("rule_id", rule.ID),
+			zap.String("state", state),
+			zap.String("labels", rule.Labels.String()),
+		)
+		m.metrics.NotificationsDropped.Inc()
+		return
+	}
+	annotations := m.expandAnnotations(rule)
+	rule.Labels = lset
+
+	// 静默和抑制只屏蔽 firing 通知, resolved 通知始终发送, 避免静默前已经发送的告警在网关中无法恢复
+	if state == "firing" && m.mutes(rule) {
+		return
+	}
 
 	alert := Alert{
 		State:       state,
 		Labels:      rule.Labels,
-		Annotations: rule.Annotations,
+		Annotations: annotations,
 		Value:       math.Round(rule.LastValue*100) / 100,
 		ActiveAt:    rule.ActiveAt.Format(time.RFC3339),
 	}
@@ -239,7 +690,7 @@ func (m *Manager) sendNotification(rule EvalRule, state string) {
 		return
 	}
 
-	url := fmt.Sprintf("%s%s", m.config.Gateway.URL, m.config.Gateway.NotifyPath)
+	url := fmt.Sprintf("%s%s", m.config().Gateway.URL, m.config().Gateway.NotifyPath)
 
 	m.logger.Info("preparing notification",
 		zap.String("url", url),
@@ -252,10 +703,9 @@ func (m *Manager) sendNotification(rule EvalRule, state string) {
 		zap.String("fired_at", alert.FiredAt),
 	)
 
-	for i := 1; i <= m.config.NotifyRetries; i++ {
-		client := &http.Client{Timeout: 5 * time.Second}
+	for i := 1; i <= m.config().NotifyRetries; i++ {
+		client := &http.Client{Transport: m.gateway, Timeout: 5 * time.Second}
 		req, _ := http.NewRequest("POST", url, bytes.NewReader(data))
-		req.Header.Set("Token", m.config.AuthToken)
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := client.Do(req)
@@ -289,12 +739,73 @@ func (m *Manager) sendNotification(rule EvalRule, state string) {
 	}
 }
 
+// newPromClient 创建数据源客户端. 未配置数据源认证时不携带凭据, 仅在显式配置 datasource_auth.legacy_auth_token 时
+// 兼容旧版本使用 auth_token 进行 Basic 认证, 网关凭据默认不会发送给数据源.
+func newPromClient(prom rule.Prom, cfg *config.Config) (api.Client, error) {
+	auth := cfg.DatasourceAuth.Resolve(prom.ID, prom.Auth)
+	if auth == nil {
+		if !cfg.DatasourceAuth.LegacyAuthToken {
+			return api.NewClient(api.Config{Address: prom.URL})
+		}
+		return api.NewClient(api.Config{
+			Address: prom.URL,
+			RoundTripper: &authRoundTripper{
+				rt:    api.DefaultRoundTripper,
+				token: cfg.Token,
+			},
+		})
+	}
+
+	if err := auth.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth config: %w", err)
+	}
+
+	rt, err := config_util.NewRoundTripperFromConfig(auth.HTTPClientConfig(), fmt.Sprintf("prom_%d", prom.ID))
+	if err != nil {
+		return nil, err
+	}
+	if len(auth.Headers) > 0 || len(auth.HeaderFiles) > 0 {
+		rt = &headersRoundTripper{rt: rt, headers: auth.Headers, files: auth.HeaderFiles}
+	}
+
+	return api.NewClient(api.Config{
+		Address:      prom.URL,
+		RoundTripper: rt,
+	})
+}
+
 type authRoundTripper struct {
 	rt    http.RoundTripper
-	token string
+	token func() (string, error)
 }
 
 func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Set("Authorization", "Basic "+rt.token)
+	token, err := rt.token()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Basic "+token)
+	return rt.rt.RoundTrip(req)
+}
+
+// headersRoundTripper 设置自定义请求头, 从文件读取的取值在每次请求时重新读取
+type headersRoundTripper struct {
+	rt      http.RoundTripper
+	headers map[string]config_util.Secret
+	files   map[string]string
+}
+
+func (rt *headersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		req.Header.Set(k, string(v))
+	}
+	for k, file := range rt.files {
+		v, err := config.ReadSecretFile(file)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
 	return rt.rt.RoundTrip(req)
 }
@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// testMetrics 指标注册到默认注册表, 所有测试共用
+var testMetrics = NewMetrics()
+
+func newTestManager(t *testing.T, cfg *config.Config, prom rule.Prom) *Manager {
+	t.Helper()
+	if prom.URL == "" {
+		prom.URL = "http://prometheus:9090"
+	}
+	m, err := NewManager(context.Background(), prom, cfg, nil, nil, nil, zap.NewNop(), testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Stop)
+	return m
+}
+
+func TestProcessLabels(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ExternalLabels = common.FromMap(map[string]string{"cluster": "prod", "region": "{{ $value }}"})
+	m := newTestManager(t, cfg, rule.Prom{ID: 1})
+
+	r := newEvalRule(rule.Rule{
+		ID:     1,
+		PromID: 1,
+		Expr:   "up == 0",
+		Labels: common.FromMap(map[string]string{
+			"severity": "critical",
+			"summary":  "{{ $labels.instance }} in {{ $externalLabels.cluster }}",
+			"job":      "{{ $labels.job }}-rule",
+			"broken":   "{{ .Missing }}",
+		}),
+	})
+	r.LastValue = 1
+	r.Labels = mergeMetricLabels(r.RuleLabels, map[string]string{
+		"instance": "node-1:9100",
+		// 序列标签值包含模板语法时原样使用
+		"path": "/api/{{ .Value }}",
+		"job":  "{{ $labels.instance }}",
+	})
+
+	lset, keep := m.processLabels(r)
+	if !keep {
+		t.Fatal("alert dropped")
+	}
+
+	want := map[string]string{
+		"severity": "critical",
+		"summary":  "node-1:9100 in prod",
+		"instance": "node-1:9100",
+		"path":     "/api/{{ .Value }}",
+		// 同名的序列标签优先, 不渲染
+		"job":     "{{ $labels.instance }}",
+		"cluster": "prod",
+		// 外部标签原样使用
+		"region": "{{ $value }}",
+	}
+	for name, v := range want {
+		if got := lset.Get(name); got != v {
+			t.Errorf("label %s = %q, want %q", name, got, v)
+		}
+	}
+	if got := lset.Get("broken"); !strings.HasPrefix(got, "<error expanding template: ") {
+		t.Errorf("label broken = %q, want template error", got)
+	}
+	if len(lset) != len(want)+1 {
+		t.Errorf("got labels %s", lset)
+	}
+}
+
+func TestProcessLabelsRelabel(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ExternalLabels = common.FromMap(map[string]string{"cluster": "prod"})
+	if err := yaml.UnmarshalStrict([]byte(`
+- source_labels: [cluster, instance]
+  separator: "/"
+  target_label: origin
+- source_labels: [env]
+  regex: test
+  action: drop
+`), &cfg.AlertRelabelConfigs); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestManager(t, cfg, rule.Prom{ID: 1})
+
+	r := newEvalRule(rule.Rule{ID: 1, PromID: 1, Expr: "up"})
+	r.Labels = mergeMetricLabels(r.RuleLabels, map[string]string{"instance": "node-1"})
+	lset, keep := m.processLabels(r)
+	if !keep || lset.Get("origin") != "prod/node-1" {
+		t.Errorf("got %s, %v, want origin prod/node-1", lset, keep)
+	}
+
+	r.Labels = mergeMetricLabels(r.RuleLabels, map[string]string{"instance": "node-1", "env": "test"})
+	if lset, keep := m.processLabels(r); keep {
+		t.Errorf("got %s, want the alert to be dropped", lset)
+	}
+}
+
+func TestExpandAnnotations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ExternalLabels = common.FromMap(map[string]string{"cluster": "prod"})
+	m := newTestManager(t, cfg, rule.Prom{ID: 1, ExternalLabels: common.FromMap(map[string]string{"cluster": "staging"})})
+
+	r := newEvalRule(rule.Rule{
+		ID:          7,
+		PromID:      1,
+		Expr:        "node_load1",
+		Summary:     "load {{ humanize $value }} on {{ $labels.instance }}",
+		Description: "cluster {{ $externalLabels.cluster }} {{ .Missing }}",
+	})
+	r.LastValue = 4321
+	r.Labels = mergeMetricLabels(r.RuleLabels, map[string]string{"instance": "{{ $value }}"})
+
+	annotations := m.expandAnnotations(r)
+	if got := annotations["summary"]; got != "load 4.321k on {{ $value }}" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := annotations["description"]; !strings.HasPrefix(got, "<error expanding template: ") {
+		t.Errorf("description = %q, want template error", got)
+	}
+	if annotations["rule_id"] != "7" || annotations["prom_id"] != "1" {
+		t.Errorf("unexpected annotations: %v", annotations)
+	}
+
+	// 数据源的外部标签覆盖全局外部标签
+	r.Annotations["description"] = "cluster {{ $externalLabels.cluster }}"
+	if got := m.expandAnnotations(r)["description"]; got != "cluster staging" {
+		t.Errorf("description = %q, want cluster staging", got)
+	}
+}
+
+// testMuter 可以切换是否屏蔽所有告警
+type testMuter struct {
+	muted atomic.Bool
+}
+
+func (m *testMuter) Mutes(common.Labels) bool {
+	return m.muted.Load()
+}
+
+// testNotifier 模拟网关的通知接口, 记录收到的告警
+type testNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *testNotifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var alerts []Alert
+	if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.mu.Lock()
+	n.alerts = append(n.alerts, alerts...)
+	n.mu.Unlock()
+}
+
+func (n *testNotifier) states() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var states []string
+	for _, a := range n.alerts {
+		states = append(states, a.State)
+	}
+	return states
+}
+
+func newNotifyTestManager(t *testing.T) (*Manager, *testNotifier) {
+	t.Helper()
+	n := &testNotifier{}
+	srv := httptest.NewServer(n)
+	t.Cleanup(srv.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Gateway.URL = srv.URL
+	cfg.NotifyRetries = 1
+	return newTestManager(t, cfg, rule.Prom{ID: 1}), n
+}
+
+func TestSendNotificationResolvedAfterMute(t *testing.T) {
+	for _, name := range []string{"silence", "inhibit"} {
+		m, n := newNotifyTestManager(t)
+		muter := &testMuter{}
+		if name == "silence" {
+			m.silencer = muter
+		} else {
+			m.inhibitor = muter
+		}
+
+		firing := true
+		m.evaluator.queryFunc = func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+			return firing, 1, map[string]string{"instance": "node-1"}, nil
+		}
+		m.evaluator.UpdateRules([]EvalRule{testEvalRule(1, "0")})
+
+		now := time.Now()
+		step := func(want ...string) {
+			t.Helper()
+			m.evaluator.evaluate(context.Background(), now)
+			now = now.Add(time.Minute)
+			if got := n.states(); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("%s: notifications = %v, want %v", name, got, want)
+			}
+		}
+
+		step()         // pending
+		step("firing") // firing 通知已发送到网关
+
+		// 静默或抑制期间不再发送 firing 通知, 恢复时仍然发送 resolved 通知
+		muter.muted.Store(true)
+		step("firing")
+		firing = false
+		step("firing", "resolved")
+
+		// 从一开始就被屏蔽的告警同样发送 resolved 通知
+		firing = true
+		step("firing", "resolved")
+		step("firing", "resolved")
+		firing = false
+		step("firing", "resolved", "resolved")
+	}
+}
+
+func TestPromClientAuthToken(t *testing.T) {
+	auth := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": []}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name   string
+		legacy bool
+		def    *config.DatasourceAuth
+		want   string
+	}{
+		// 网关凭据默认不发送给数据源
+		{"auth_token only", false, nil, ""},
+		{"legacy auth token", true, nil, "Basic token-1"},
+		{"default auth", true, &config.DatasourceAuth{BearerToken: "prom-token"}, "Bearer prom-token"},
+	}
+
+	for _, tc := range tests {
+		cfg := config.DefaultConfig()
+		cfg.AuthToken = "token-1"
+		cfg.DatasourceAuth.LegacyAuthToken = tc.legacy
+		cfg.DatasourceAuth.Default = tc.def
+		m := newTestManager(t, cfg, rule.Prom{ID: 1, URL: srv.URL})
+
+		if _, _, _, err := m.queryPrometheus(context.Background(), "up", time.Now()); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := <-auth; got != tc.want {
+			t.Errorf("%s: Authorization = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNeedsRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(cfg *config.Config)
+		want   bool
+	}{
+		{"unchanged", func(cfg *config.Config) {}, false},
+		{"evaluation interval", func(cfg *config.Config) { cfg.EvaluationInterval = model.Duration(time.Minute) }, true},
+		{"reload interval", func(cfg *config.Config) { cfg.ReloadInterval = model.Duration(time.Minute) }, false},
+		{"notify retries", func(cfg *config.Config) { cfg.NotifyRetries = 5 }, false},
+		{"auth token", func(cfg *config.Config) { cfg.AuthToken = "token-2" }, true},
+		{"gateway auth", func(cfg *config.Config) { cfg.Gateway.Auth = &config.GatewayAuth{BearerToken: "gateway"} }, true},
+		// 数据源是否使用 auth_token 取决于 legacy_auth_token
+		{"legacy auth token", func(cfg *config.Config) { cfg.DatasourceAuth.LegacyAuthToken = true }, true},
+		{"default auth", func(cfg *config.Config) {
+			cfg.DatasourceAuth.Default = &config.DatasourceAuth{BearerToken: "prom-token"}
+		}, true},
+		{"prom auth", func(cfg *config.Config) {
+			cfg.DatasourceAuth.Proms = map[int64]*config.DatasourceAuth{1: {BearerToken: "prom-token"}}
+		}, true},
+		{"other prom auth", func(cfg *config.Config) {
+			cfg.DatasourceAuth.Proms = map[int64]*config.DatasourceAuth{2: {BearerToken: "prom-token"}}
+		}, false},
+		{"inhibit rules", func(cfg *config.Config) {
+			cfg.InhibitRules = []config.InhibitRule{{SourceMatchers: []string{`severity="critical"`}}}
+		}, false},
+	}
+
+	for _, tc := range tests {
+		cfg := config.DefaultConfig()
+		cfg.AuthToken = "token-1"
+		m := newTestManager(t, cfg, rule.Prom{ID: 1})
+
+		newCfg := config.DefaultConfig()
+		newCfg.AuthToken = "token-1"
+		tc.update(newCfg)
+		if got := m.needsRestart(newCfg); got != tc.want {
+			t.Errorf("%s: needsRestart = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
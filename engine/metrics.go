@@ -16,12 +16,39 @@ type Metrics struct {
 	// 告警通知错误数量
 	NotifyErrors prometheus.Counter
 
+	// 被静默跳过的告警通知数量
+	NotificationsSilenced prometheus.Counter
+
+	// 被抑制规则跳过的告警通知数量
+	NotificationsInhibited prometheus.Counter
+
+	// 被重标记丢弃的告警通知数量
+	NotificationsDropped prometheus.Counter
+
+	// 规则变化数量, 按变化类型 added, removed, modified 统计
+	RuleChanges *prometheus.CounterVec
+
 	// 规则重载成功次数
 	ReloadSuccess prometheus.Counter
 
 	// 规则重载失败次数
 	ReloadErrors prometheus.Counter
 
+	// 最近一次配置重载是否成功
+	ConfigLastReloadSuccessful prometheus.Gauge
+
+	// 最近一次配置重载成功的时间
+	ConfigLastReloadSuccessTime prometheus.Gauge
+
+	// 是否处于降级状态, 网关不可用时使用快照中的规则启动
+	Degraded prometheus.Gauge
+
+	// 最近一次从网关同步规则成功的时间
+	RuleSyncLastSuccessTime prometheus.Gauge
+
+	// 最近一次同步时规则来源之间的冲突数量
+	RuleSourceConflicts prometheus.Gauge
+
 	// 规则评估持续时间
 	EvaluationDuration prometheus.Histogram
 
@@ -50,6 +77,31 @@ func NewMetrics() *Metrics {
 				Help: "Total number of notification errors",
 			},
 		),
+		NotificationsSilenced: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "alertengine_notifications_silenced_total",
+				Help: "Total number of alert notifications skipped by silences",
+			},
+		),
+		NotificationsInhibited: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "alertengine_notifications_inhibited_total",
+				Help: "Total number of alert notifications skipped by inhibition rules",
+			},
+		),
+		NotificationsDropped: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "alertengine_notifications_dropped_total",
+				Help: "Total number of alert notifications dropped by relabeling",
+			},
+		),
+		RuleChanges: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "alertengine_rule_changes_total",
+				Help: "Total number of alert rules added, removed or modified by reloads per Prometheus instance",
+			},
+			[]string{"prom_id", "type"},
+		),
 		ReloadSuccess: promauto.NewCounter(
 			prometheus.CounterOpts{
 				Name: "alertengine_reload_success_total",
@@ -62,6 +114,36 @@ func NewMetrics() *Metrics {
 				Help: "Total number of rule reload errors",
 			},
 		),
+		ConfigLastReloadSuccessful: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "alertengine_config_last_reload_successful",
+				Help: "Whether the last configuration reload attempt was successful",
+			},
+		),
+		ConfigLastReloadSuccessTime: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "alertengine_config_last_reload_success_timestamp_seconds",
+				Help: "Timestamp of the last successful configuration reload",
+			},
+		),
+		Degraded: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "alertengine_degraded",
+				Help: "Whether the engine is serving rules from the last-known-good snapshot because the gateway is unavailable",
+			},
+		),
+		RuleSyncLastSuccessTime: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "alertengine_rule_sync_last_success_timestamp_seconds",
+				Help: "Timestamp of the last successful rule sync from the gateway",
+			},
+		),
+		RuleSourceConflicts: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "alertengine_rule_source_conflicts",
+				Help: "Number of conflicting rule or prom definitions between rule sources in the last sync",
+			},
+		),
 		EvaluationDuration: promauto.NewHistogram(
 			prometheus.HistogramOpts{
 				Name:    "alertengine_evaluation_duration_seconds",
@@ -2,12 +2,16 @@ package engine
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"alertengine/common"
 	"alertengine/config"
 	"alertengine/rule"
 
@@ -16,13 +20,22 @@ import (
 
 // Reloader 规则重载器
 type Reloader struct {
-	config   *config.Config
-	storage  *rule.Storage
-	managers map[int64]*Manager
-	mu       sync.RWMutex
-	ctx      context.Context
-	cancel   context.CancelFunc
-	running  bool
+	cfg       atomic.Pointer[config.Config]
+	gateway   atomic.Pointer[gatewayRoundTripper]
+	storage   *rule.Storage
+	silencer  Muter
+	inhibitor *Inhibitor
+	managers  map[int64]*Manager
+	mu        sync.RWMutex
+	sources   atomic.Pointer[ruleSources]
+	reloadCh  chan chan error
+	configCh  chan struct{}
+	changeCh  chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	running   bool
+	// 网关不可用时启动所使用的快照, 为 nil 表示规则来自网关
+	snapshot atomic.Pointer[rule.Snapshot]
 	logger   *zap.Logger
 	metrics  *Metrics
 }
@@ -31,21 +44,63 @@ type Reloader struct {
 func NewReloader(
 	cfg *config.Config,
 	storage *rule.Storage,
+	silencer Muter,
 	logger *zap.Logger,
 	metrics *Metrics,
-) *Reloader {
+) (*Reloader, error) {
+	gateway, err := newGatewayRoundTripper(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway client: %w", err)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Reloader{
-		config:   cfg,
+	r := &Reloader{
 		storage:  storage,
+		silencer: silencer,
 		managers: make(map[int64]*Manager),
+		reloadCh: make(chan chan error),
+		configCh: make(chan struct{}, 1),
+		changeCh: make(chan struct{}, 1),
 		ctx:      ctx,
 		cancel:   cancel,
 		running:  false,
 		logger:   logger,
 		metrics:  metrics,
 	}
+	r.cfg.Store(cfg)
+	r.gateway.Store(gateway)
+	logDeprecated(cfg, logger)
+	r.inhibitor = NewInhibitor(cfg.InhibitRules, r.firingAlerts, logger)
+	r.setSources(newRuleSources(cfg, r))
+
+	return r, nil
+}
+
+// ruleSources 当前使用的规则来源, cancel 用于停止文件监听
+type ruleSources struct {
+	list   []RuleSource
+	cancel context.CancelFunc
+}
+
+// setSources 替换规则来源, 停止旧来源的文件监听并启动新来源的文件监听
+func (r *Reloader) setSources(list []RuleSource) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	for _, src := range list {
+		if w, ok := src.(SourceWatcher); ok {
+			go w.Watch(ctx, r.notifyChange)
+		}
+	}
+	if old := r.sources.Swap(&ruleSources{list: list, cancel: cancel}); old != nil {
+		old.cancel()
+	}
+}
+
+// notifyChange 规则来源发生变化时通知主循环立即同步
+func (r *Reloader) notifyChange() {
+	select {
+	case r.changeCh <- struct{}{}:
+	default:
+	}
 }
 
 // Run 启动重载器
@@ -84,29 +139,175 @@ func (r *Reloader) Stop() {
 
 // Loop 主循环
 func (r *Reloader) Loop() {
-	ticker := time.NewTicker(time.Duration(r.config.ReloadInterval))
+	ticker := time.NewTicker(time.Duration(r.Config().ReloadInterval))
 	defer ticker.Stop()
 
-	// 立即执行一次
+	// 立即执行一次, 网关不可用时从快照恢复规则
 	if err := r.Update(); err != nil {
 		r.logger.Error("initial update failed", zap.Error(err))
+		r.restoreSnapshot()
 	}
 
-	for r.running {
+	for {
 		select {
 		case <-r.ctx.Done():
 			return
 		case <-ticker.C:
-			if err := r.Update(); err != nil {
-				r.logger.Error("update failed", zap.Error(err))
-				r.metrics.ReloadErrors.Inc()
-			} else {
-				r.metrics.ReloadSuccess.Inc()
-			}
+			r.update()
+		case errc := <-r.reloadCh:
+			errc <- r.update()
+		case <-r.changeCh:
+			r.update()
+		case <-r.configCh:
+			ticker.Reset(time.Duration(r.Config().ReloadInterval))
 		}
 	}
 }
 
+// Config 返回当前生效的配置
+func (r *Reloader) Config() *config.Config {
+	return r.cfg.Load()
+}
+
+// ApplyConfig 应用新的配置, 评估间隔或数据源认证发生变化的管理器会被重建并保留告警状态.
+// 监听地址, 存储, 静默和日志输出等配置仅在启动时生效, 变更时记录警告.
+func (r *Reloader) ApplyConfig(cfg *config.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.Config()
+	for _, name := range restartRequired(old, cfg) {
+		r.logger.Warn("config change requires restart to take effect", zap.String("field", name))
+	}
+	logDeprecated(cfg, r.logger)
+
+	// 先创建网关客户端和需要重建的管理器, 任一失败时保留旧配置, 避免各组件使用的配置不一致
+	var errs []error
+	gateway, err := newGatewayRoundTripper(cfg)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("gateway: %w", err))
+	}
+	restarted := make(map[int64]*Manager)
+	for id, manager := range r.managers {
+		if !manager.needsRestart(cfg) {
+			continue
+		}
+		newManager, err := NewManager(
+			r.ctx,
+			manager.prom,
+			cfg,
+			r.storage,
+			r.silencer,
+			r.inhibitor,
+			r.logger,
+			r.metrics,
+		)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prom %d: %w", id, err))
+			continue
+		}
+		newManager.inherit(manager)
+		restarted[id] = newManager
+	}
+	if len(errs) > 0 {
+		for _, m := range restarted {
+			m.Stop()
+		}
+		return fmt.Errorf("failed to apply config: %w", errors.Join(errs...))
+	}
+
+	for id, manager := range r.managers {
+		newManager, ok := restarted[id]
+		if !ok {
+			manager.applyConfig(cfg)
+			continue
+		}
+		manager.Stop()
+		if r.running {
+			newManager.Run()
+		}
+		r.managers[id] = newManager
+		r.logger.Info("manager restarted",
+			zap.Int64("prom_id", id),
+			zap.Duration("interval", newManager.Interval()),
+		)
+	}
+
+	r.cfg.Store(cfg)
+	r.gateway.Store(gateway)
+	r.inhibitor.ApplyConfig(cfg.InhibitRules)
+	if !reflect.DeepEqual(old.Sources(), cfg.Sources()) {
+		r.setSources(newRuleSources(cfg, r))
+		r.logger.Info("rule sources changed", zap.Int("sources", len(cfg.Sources())))
+	}
+
+	if old.ReloadInterval != cfg.ReloadInterval {
+		select {
+		case r.configCh <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// restartRequired 返回仅在启动时生效且发生了变更的配置项
+func restartRequired(old, cfg *config.Config) []string {
+	var fields []string
+	if !reflect.DeepEqual(old.Web, cfg.Web) || old.MetricsPort != cfg.MetricsPort {
+		fields = append(fields, "web")
+	}
+	if old.Storage != cfg.Storage {
+		fields = append(fields, "storage")
+	}
+	if old.Silence != cfg.Silence {
+		fields = append(fields, "silence")
+	}
+	if old.Log.Format != cfg.Log.Format || old.Log.OutputPath != cfg.Log.OutputPath {
+		fields = append(fields, "log")
+	}
+	return fields
+}
+
+// logDeprecated 提示配置中使用的已废弃配置项
+func logDeprecated(cfg *config.Config, logger *zap.Logger) {
+	if cfg.DatasourceAuth.LegacyAuthToken {
+		logger.Warn("datasource_auth.legacy_auth_token is deprecated and sends the gateway auth_token to every prometheus without other auth, use datasource_auth.default instead")
+	}
+}
+
+// update 执行一次规则更新并记录指标
+func (r *Reloader) update() error {
+	err := r.Update()
+	if err != nil {
+		r.logger.Error("update failed", zap.Error(err))
+		r.metrics.ReloadErrors.Inc()
+	} else {
+		r.metrics.ReloadSuccess.Inc()
+	}
+	return err
+}
+
+// Reload 请求主循环立即执行一次规则更新并等待结果
+func (r *Reloader) Reload(ctx context.Context) error {
+	errc := make(chan error, 1)
+
+	select {
+	case r.reloadCh <- errc:
+	case <-r.ctx.Done():
+		return errors.New("reloader stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Update 更新规则
 func (r *Reloader) Update() error {
 	r.logger.Info("starting rule update")
@@ -117,6 +318,55 @@ func (r *Reloader) Update() error {
 		return fmt.Errorf("failed to fetch rules: %w", err)
 	}
 
+	r.apply(promRules)
+
+	if err := r.storage.SaveSnapshot(promRules); err != nil {
+		r.logger.Error("failed to save rule snapshot", zap.Error(err))
+	}
+	if snap := r.snapshot.Swap(nil); snap != nil {
+		r.logger.Info("rules synced, leaving degraded mode",
+			zap.Time("snapshot_created_at", snap.CreatedAt),
+		)
+	}
+	r.metrics.Degraded.Set(0)
+	r.metrics.RuleSyncLastSuccessTime.SetToCurrentTime()
+
+	return nil
+}
+
+// restoreSnapshot 从最近一次保存的快照重建管理器, 并在网关同步成功前标记为降级状态
+func (r *Reloader) restoreSnapshot() {
+	snap, err := r.storage.LoadSnapshot()
+	if err != nil {
+		r.logger.Error("failed to load rule snapshot", zap.Error(err))
+		return
+	}
+	if snap == nil {
+		r.logger.Warn("no rule snapshot available, waiting for rule sources")
+		return
+	}
+
+	r.apply(snap.Proms)
+	r.snapshot.Store(snap)
+	r.metrics.Degraded.Set(1)
+
+	r.logger.Warn("rule sync failed, serving rules from snapshot",
+		zap.Time("snapshot_created_at", snap.CreatedAt),
+		zap.Int("prom_count", len(snap.Proms)),
+	)
+}
+
+// Degraded 返回是否正在使用快照中的规则以及快照的创建时间
+func (r *Reloader) Degraded() (time.Time, bool) {
+	snap := r.snapshot.Load()
+	if snap == nil {
+		return time.Time{}, false
+	}
+	return snap.CreatedAt, true
+}
+
+// apply 按数据源更新管理器: 删除不再存在或连接信息变化的管理器, 创建新的管理器并更新规则
+func (r *Reloader) apply(promRules []rule.PromRules) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -126,6 +376,8 @@ func (r *Reloader) Update() error {
 		for _, pr := range promRules {
 			if manager.prom.ID == pr.Prom.ID &&
 				manager.prom.URL == pr.Prom.URL &&
+				common.Equal(manager.prom.ExternalLabels, pr.Prom.ExternalLabels) &&
+				reflect.DeepEqual(manager.prom.Auth, pr.Prom.Auth) &&
 				pr.Prom.URL != "" {
 				shouldDelete = false
 				break
@@ -143,6 +395,7 @@ func (r *Reloader) Update() error {
 	}
 
 	// 更新或创建管理器
+	var added, removed, modified, unchanged int
 	for _, pr := range promRules {
 		if pr.Prom.URL == "" {
 			r.logger.Warn("skipping prom with empty URL", zap.Int64("prom_id", pr.Prom.ID))
@@ -156,8 +409,10 @@ func (r *Reloader) Update() error {
 			newManager, err := NewManager(
 				r.ctx,
 				pr.Prom,
-				r.config,
+				r.Config(),
 				r.storage,
+				r.silencer,
+				r.inhibitor,
 				r.logger,
 				r.metrics,
 			)
@@ -175,114 +430,89 @@ func (r *Reloader) Update() error {
 		}
 
 		// 更新规则
-		if err := manager.Update(pr.Rules); err != nil {
+		diff, err := manager.Update(pr.Rules)
+		if err != nil {
 			r.logger.Error("failed to update rules",
 				zap.Int64("prom_id", manager.prom.ID),
 				zap.Error(err),
 			)
-		} else {
-			r.logger.Info("rules updated",
-				zap.Int64("prom_id", manager.prom.ID),
-				zap.Int("count", len(pr.Rules)),
-			)
+			continue
+		}
+		if diff.Empty() {
+			unchanged++
 		}
+		added += len(diff.Added)
+		removed += len(diff.Removed)
+		modified += len(diff.Modified)
 	}
 
+	r.metrics.ActiveManagers.Set(float64(len(r.managers)))
+
 	r.logger.Info("rule update completed",
 		zap.Int("manager_count", len(r.managers)),
+		zap.Int("unchanged_managers", unchanged),
+		zap.Int("added", added),
+		zap.Int("removed", removed),
+		zap.Int("modified", modified),
 	)
-
-	return nil
 }
 
-// fetchPromRules 获取规则和数据源
+// fetchPromRules 从所有规则来源获取规则和数据源并合并
 func (r *Reloader) fetchPromRules() ([]rule.PromRules, error) {
-	client := &http.Client{
-		Timeout: r.config.Gateway.Timeout,
-	}
-
-	// 获取规则列表
-	rules, err := r.fetchRules(client)
-	if err != nil {
-		return nil, err
-	}
-
-	// 获取数据源列表
-	proms, err := r.fetchProms(client)
-	if err != nil {
-		return nil, err
+	sources := r.sources.Load().list
+	data := make([]*SourceData, len(sources))
+	for i, src := range sources {
+		d, err := src.Fetch(r.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", src.Name(), err)
+		}
+		data[i] = d
 	}
 
-	// 组合数据
-	promRules := rules.PromRules()
-	for i := range promRules {
-		for _, prom := range proms {
-			if promRules[i].Prom.ID == prom.ID {
-				promRules[i].Prom.URL = prom.URL
-				break
-			}
-		}
+	promRules, conflicts := combineSources(sources, data)
+	for _, c := range conflicts {
+		r.logger.Warn("rule source conflict", zap.String("conflict", c))
 	}
+	r.metrics.RuleSourceConflicts.Set(float64(len(conflicts)))
 
 	return promRules, nil
 }
 
-// fetchRules 获取规则列表
-func (r *Reloader) fetchRules(client *http.Client) (rule.Rules, error) {
-	url := fmt.Sprintf("%s%s", r.config.Gateway.URL, r.config.Gateway.RulePath)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Token", r.config.AuthToken)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	var rulesResp rule.RulesResp
-	if err := json.NewDecoder(resp.Body).Decode(&rulesResp); err != nil {
-		return nil, fmt.Errorf("decode failed: %w", err)
-	}
-
-	if rulesResp.Code != 0 {
-		return nil, fmt.Errorf("api error: %s", rulesResp.Msg)
-	}
-
-	r.logger.Info("rules fetched", zap.Int("count", len(rulesResp.Data)))
-	return rulesResp.Data, nil
+// FetchProms 从所有规则来源获取数据源列表, ID 重复时使用配置在前的来源
+func (r *Reloader) FetchProms() ([]rule.Prom, error) {
+	return fetchProms(r.ctx, r.sources.Load().list)
 }
 
-// fetchProms 获取数据源列表
-func (r *Reloader) fetchProms(client *http.Client) ([]rule.Prom, error) {
-	url := fmt.Sprintf("%s%s", r.config.Gateway.URL, r.config.Gateway.PromPath)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// gatewayClient 返回访问网关使用的客户端
+func (r *Reloader) gatewayClient() *http.Client {
+	return &http.Client{
+		Transport: r.gateway.Load(),
+		Timeout:   r.Config().Gateway.Timeout,
 	}
-	req.Header.Set("Token", r.config.AuthToken)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// Managers 返回所有管理器, 按数据源ID排序
+func (r *Reloader) Managers() []*Manager {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	var promsResp rule.PromsResp
-	if err := json.NewDecoder(resp.Body).Decode(&promsResp); err != nil {
-		return nil, fmt.Errorf("decode failed: %w", err)
+	managers := make([]*Manager, 0, len(r.managers))
+	for _, manager := range r.managers {
+		managers = append(managers, manager)
 	}
+	sort.Slice(managers, func(i, j int) bool {
+		return managers[i].prom.ID < managers[j].prom.ID
+	})
+	return managers
+}
 
-	if promsResp.Code != 0 {
-		return nil, fmt.Errorf("api error: %s", promsResp.Msg)
-	}
+// Manager 返回指定数据源的管理器
+func (r *Reloader) Manager(promID int64) (*Manager, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	r.logger.Info("proms fetched", zap.Int("count", len(promsResp.Data)))
-	return promsResp.Data, nil
+	manager, ok := r.managers[promID]
+	return manager, ok
 }
 
 // GetManagerCount 获取管理器数量
@@ -291,3 +521,15 @@ func (r *Reloader) GetManagerCount() int {
 	defer r.mu.RUnlock()
 	return len(r.managers)
 }
+
+// firingAlerts 汇总所有管理器中正在触发的告警, 用于跨数据源的告警抑制
+func (r *Reloader) firingAlerts() []common.Labels {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var alerts []common.Labels
+	for _, manager := range r.managers {
+		alerts = append(alerts, manager.firingAlerts()...)
+	}
+	return alerts
+}
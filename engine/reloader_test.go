@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"alertengine/config"
+	"alertengine/rule"
+
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+func TestReloaderApplyConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	r, err := NewReloader(cfg, nil, nil, zap.NewNop(), testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Stop)
+
+	m := newTestManager(t, cfg, rule.Prom{ID: 1})
+	m.evaluator.UpdateRules([]EvalRule{testEvalRule(1, "0")})
+	r.managers[1] = m
+
+	// 不影响评估的配置变更直接应用到原管理器
+	newCfg := config.DefaultConfig()
+	newCfg.NotifyRetries = 5
+	if err := r.ApplyConfig(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Manager(1); got != m || got.config() != newCfg || r.Config() != newCfg {
+		t.Error("manager was restarted or config not applied")
+	}
+
+	// 评估间隔变更时重建管理器, 保留规则和告警状态
+	newCfg = config.DefaultConfig()
+	newCfg.EvaluationInterval = model.Duration(time.Minute)
+	if err := r.ApplyConfig(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	restarted, _ := r.Manager(1)
+	if restarted == m || restarted.Interval() != time.Minute {
+		t.Fatalf("manager not restarted with the new interval, got interval %s", restarted.Interval())
+	}
+	if rules := restarted.Rules(); len(rules) != 1 || rules[0].ID != "1" {
+		t.Errorf("rules = %v, want the rules of the previous manager", rules)
+	}
+	if m.ctx.Err() == nil {
+		t.Error("previous manager was not stopped")
+	}
+
+	// 任一管理器创建失败时保留旧配置和管理器
+	invalid := config.DefaultConfig()
+	invalid.EvaluationInterval = model.Duration(time.Minute)
+	invalid.DatasourceAuth.Default = &config.DatasourceAuth{
+		BearerToken: "token",
+		BasicAuth:   &config.BasicAuth{Username: "user", Password: "password"},
+	}
+	if err := r.ApplyConfig(invalid); err == nil {
+		t.Fatal("invalid datasource auth was applied")
+	}
+	if got, _ := r.Manager(1); got != restarted || r.Config() != newCfg || got.config() != newCfg {
+		t.Error("manager or config replaced after a failed apply")
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(cfg *config.Config)
+		want   []string
+	}{
+		{"unchanged", func(cfg *config.Config) {}, nil},
+		{"reloadable", func(cfg *config.Config) {
+			cfg.EvaluationInterval = model.Duration(time.Minute)
+			cfg.AuthToken = "token"
+			cfg.Log.Level = "debug"
+		}, nil},
+		{"web", func(cfg *config.Config) { cfg.Web.ListenAddresses.API = ":8081" }, []string{"web"}},
+		{"metrics port", func(cfg *config.Config) { cfg.MetricsPort = 9091 }, []string{"web"}},
+		{"storage and log", func(cfg *config.Config) {
+			cfg.Storage.RuleDir = "/tmp/rules"
+			cfg.Log.Format = "console"
+		}, []string{"storage", "log"}},
+		{"silence", func(cfg *config.Config) { cfg.Silence.DataFile = "/tmp/silences.json" }, []string{"silence"}},
+	}
+
+	for _, tc := range tests {
+		cfg := config.DefaultConfig()
+		tc.update(cfg)
+		if got := restartRequired(config.DefaultConfig(), cfg); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// newSnapshotTestReloader 创建使用本地规则文件的重载器, 启动主循环并在测试结束时停止
+func newSnapshotTestReloader(t *testing.T, dir, ruleFile string) *Reloader {
+	t.Helper()
+	storage, err := rule.NewStorage(dir, 7, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch := false
+	cfg := config.DefaultConfig()
+	cfg.RuleSources = []config.RuleSourceConfig{{Type: config.SourceFile, Files: []string{ruleFile}, Watch: &watch}}
+	r, err := NewReloader(cfg, storage, nil, zap.NewNop(), testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Run()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Loop()
+	}()
+	t.Cleanup(func() {
+		r.Stop()
+		<-done
+	})
+	return r
+}
+
+// promIDs 返回重载器中所有管理器的数据源 ID
+func promIDs(r *Reloader) []int64 {
+	var ids []int64
+	for _, m := range r.Managers() {
+		ids = append(ids, m.Prom().ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestReloaderRestoreSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ruleFile := filepath.Join(dir, "rules.yml")
+	writeTestFile(t, ruleFile, "rules: [")
+
+	storage, err := rule.NewStorage(dir, 7, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if err := storage.SaveSnapshot([]rule.PromRules{{
+		Prom:  rule.Prom{ID: 1, URL: "http://127.0.0.1:1"},
+		Rules: rule.Rules{{ID: 1, PromID: 1, Expr: "up", Op: "==", Value: "0"}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 首次同步失败时使用快照中的规则, 并标记为降级状态
+	r := newSnapshotTestReloader(t, dir, ruleFile)
+	deadline := time.Now().Add(5 * time.Second)
+	for r.GetManagerCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the snapshot to be restored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	createdAt, degraded := r.Degraded()
+	if !degraded || createdAt.Before(before.Add(-time.Second)) || createdAt.After(time.Now()) {
+		t.Errorf("Degraded() = %s, %v, want the snapshot creation time", createdAt, degraded)
+	}
+	m, ok := r.Manager(1)
+	if !ok {
+		t.Fatal("manager for prom 1 not restored")
+	}
+	if rules := m.Rules(); len(rules) != 1 || rules[0].ID != "1" {
+		t.Errorf("restored rules = %v", rules)
+	}
+
+	// 同步失败时保持降级状态
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Reload(ctx); err == nil {
+		t.Fatal("reload succeeded with an invalid rule file")
+	}
+	if _, degraded := r.Degraded(); !degraded {
+		t.Error("degraded state cleared after a failed sync")
+	}
+
+	// 同步成功后使用规则来源中的规则, 并解除降级状态
+	writeTestFile(t, ruleFile, `
+proms:
+  - id: 2
+    url: http://127.0.0.1:1
+rules:
+  - id: 2
+    prom_id: 2
+    expr: node_load1
+`)
+	if err := r.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, degraded := r.Degraded(); degraded {
+		t.Error("still degraded after a successful sync")
+	}
+	if got := promIDs(r); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("managers = %v, want [2]", got)
+	}
+
+	// 成功同步的规则保存为新的快照
+	snap, err := storage.LoadSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Proms) != 1 || snap.Proms[0].Prom.ID != 2 || len(snap.Proms[0].Rules) != 1 {
+		t.Errorf("snapshot = %+v, want the synced rules", snap.Proms)
+	}
+}
+
+func TestReloaderRestoreSnapshotErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string
+	}{
+		{"no snapshot", ""},
+		{"corrupt snapshot", "{"},
+	}
+
+	for _, tc := range tests {
+		dir := t.TempDir()
+		ruleFile := filepath.Join(dir, "rules.yml")
+		writeTestFile(t, ruleFile, "rules: [")
+		if tc.snapshot != "" {
+			writeTestFile(t, filepath.Join(dir, "snapshot.json"), tc.snapshot)
+		}
+
+		// 没有可用的快照时不加载任何规则, 等待规则来源恢复
+		r := newSnapshotTestReloader(t, dir, ruleFile)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := r.Reload(ctx); err == nil {
+			t.Errorf("%s: reload succeeded with an invalid rule file", tc.name)
+		}
+		cancel()
+		if _, degraded := r.Degraded(); degraded || r.GetManagerCount() != 0 {
+			t.Errorf("%s: degraded = %v with %d managers, want no rules", tc.name, degraded, r.GetManagerCount())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); !os.IsNotExist(err) {
+			t.Errorf("%s: snapshot file still exists, got %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"alertengine/common"
+	"alertengine/rule"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v2"
+)
+
+// CheckRuleFile 离线校验规则文件, 返回规则数量和发现的所有问题.
+// 除文件格式外还会检查 PromQL 表达式、持续时间、标签名、模板语法以及重复的规则ID.
+func CheckRuleFile(path string) (int, []error) {
+	rules, errs := parseRuleFile(path)
+	if rules == nil && errs != nil {
+		return 0, errs
+	}
+
+	seen := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if seen[r.ID] {
+			errs = append(errs, fmt.Errorf("rule %s: duplicate rule id", r.ID))
+		}
+		seen[r.ID] = true
+
+		for _, err := range checkEvalRule(r) {
+			errs = append(errs, fmt.Errorf("rule %s: %w", r.ID, err))
+		}
+	}
+	return len(rules), errs
+}
+
+// checkEvalRule 校验单条规则的表达式、标签和模板
+func checkEvalRule(r EvalRule) []error {
+	var errs []error
+
+	expr, err := parser.ParseExpr(r.Expr)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid expr %q: %w", r.Expr, err))
+	} else if t := expr.Type(); t != parser.ValueTypeVector && t != parser.ValueTypeScalar {
+		errs = append(errs, fmt.Errorf("invalid expr %q: expression must evaluate to vector or scalar, got %s", r.Expr, t))
+	}
+
+	for _, l := range r.RuleLabels {
+		if !model.LabelName(l.Name).IsValid() {
+			errs = append(errs, fmt.Errorf("invalid label name %q", l.Name))
+		}
+		if err := checkTemplate("__alert_"+r.ID, l.Value); err != nil {
+			errs = append(errs, fmt.Errorf("label %q: %w", l.Name, err))
+		}
+	}
+	names := make([]string, 0, len(r.Annotations))
+	for name := range r.Annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkTemplate("__alert_"+r.ID, r.Annotations[name]); err != nil {
+			errs = append(errs, fmt.Errorf("annotation %q: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+// loadRuleFile 加载规则文件, .json 文件为网关返回的规则格式, 其他文件为生成的 Prometheus 规则 YAML
+func loadRuleFile(path string) ([]EvalRule, error) {
+	rules, errs := parseRuleFile(path)
+	if errs != nil {
+		return nil, errors.Join(errs...)
+	}
+	return rules, nil
+}
+
+// parseRuleFile 解析规则文件, 文件格式错误时返回的规则为 nil, 规则级别的错误全部返回
+func parseRuleFile(path string) ([]EvalRule, []error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseGatewayRules(data)
+	}
+	return parsePromRules(data)
+}
+
+// parseGatewayRules 解析网关格式的规则, 支持规则数组和完整的接口响应
+func parseGatewayRules(data []byte) ([]EvalRule, []error) {
+	var rules rule.Rules
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var resp rule.RulesResp
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, []error{err}
+		}
+		if resp.Code != 0 {
+			return nil, []error{fmt.Errorf("api error: %s", resp.Msg)}
+		}
+		rules = resp.Data
+	} else if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, []error{err}
+	}
+
+	evalRules := make([]EvalRule, 0, len(rules))
+	var errs []error
+	for _, r := range rules {
+		if err := validateRule(r); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", r.ID, err))
+			continue
+		}
+		evalRules = append(evalRules, newEvalRule(r))
+	}
+	return evalRules, errs
+}
+
+// promRuleGroups 生成的 Prometheus 规则文件
+type promRuleGroups struct {
+	Groups []struct {
+		Name  string `yaml:"name"`
+		Rules []struct {
+			Alert       string            `yaml:"alert"`
+			Expr        string            `yaml:"expr"`
+			For         string            `yaml:"for,omitempty"`
+			Labels      map[string]string `yaml:"labels,omitempty"`
+			Annotations map[string]string `yaml:"annotations,omitempty"`
+		} `yaml:"rules"`
+	} `yaml:"groups"`
+}
+
+// parsePromRules 解析生成的 Prometheus 规则文件
+func parsePromRules(data []byte) ([]EvalRule, []error) {
+	var groups promRuleGroups
+	if err := yaml.UnmarshalStrict(data, &groups); err != nil {
+		return nil, []error{err}
+	}
+
+	evalRules := []EvalRule{}
+	var errs []error
+	for _, g := range groups.Groups {
+		for i, r := range g.Rules {
+			if r.Alert == "" {
+				errs = append(errs, fmt.Errorf("group %q, rule %d: alert name is required", g.Name, i+1))
+				continue
+			}
+			if strings.TrimSpace(r.Expr) == "" {
+				errs = append(errs, fmt.Errorf("group %q, rule %s: expr is required", g.Name, r.Alert))
+				continue
+			}
+
+			var forDuration model.Duration
+			if r.For != "" {
+				d, err := model.ParseDuration(r.For)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("group %q, rule %s: invalid for duration %q: %w", g.Name, r.Alert, r.For, err))
+					continue
+				}
+				forDuration = d
+			}
+
+			lset := common.FromMap(r.Labels)
+			evalRules = append(evalRules, EvalRule{
+				ID:          r.Alert,
+				Expr:        strings.TrimSpace(r.Expr),
+				For:         time.Duration(forDuration),
+				RuleLabels:  lset,
+				Labels:      lset,
+				Annotations: r.Annotations,
+				State:       StateInactive,
+				Health:      HealthUnknown,
+			})
+		}
+	}
+	return evalRules, errs
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"alertengine/config"
+	"alertengine/rule"
+
+	"go.uber.org/zap"
+)
+
+// RuleSource 规则来源, 返回来源中定义的规则和数据源
+type RuleSource interface {
+	// Name 返回来源名称
+	Name() string
+
+	// Fetch 获取全部规则和数据源, 任一来源获取失败时本次同步失败, 已加载的规则保持不变
+	Fetch(ctx context.Context) (*SourceData, error)
+}
+
+// SourceWatcher 可以监听变化的规则来源, 变化时调用 notify 立即触发同步
+type SourceWatcher interface {
+	Watch(ctx context.Context, notify func())
+}
+
+// SourceData 规则来源返回的规则和数据源
+type SourceData struct {
+	Proms []rule.Prom
+	Rules rule.Rules
+}
+
+// promFetcher 可以只获取数据源列表的规则来源
+type promFetcher interface {
+	FetchProms(ctx context.Context) ([]rule.Prom, error)
+}
+
+// FetchProms 根据配置创建规则来源并获取数据源列表, 不创建重载器, 不监听规则文件. 用于只需要数据源地址的子命令
+func FetchProms(ctx context.Context, cfg *config.Config, logger *zap.Logger) ([]rule.Prom, error) {
+	gateway, err := newGatewayRoundTripper(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gateway client: %w", err)
+	}
+	r := &Reloader{ctx: ctx, logger: logger}
+	r.cfg.Store(cfg)
+	r.gateway.Store(gateway)
+	return fetchProms(ctx, newRuleSources(cfg, r))
+}
+
+// fetchProms 从规则来源获取数据源列表, ID 重复时使用配置在前的来源. 支持的来源只获取数据源列表, 不获取规则
+func fetchProms(ctx context.Context, sources []RuleSource) ([]rule.Prom, error) {
+	var proms []rule.Prom
+	seen := map[int64]bool{}
+	for _, src := range sources {
+		var list []rule.Prom
+		if f, ok := src.(promFetcher); ok {
+			ps, err := f.FetchProms(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("source %s: %w", src.Name(), err)
+			}
+			list = ps
+		} else {
+			d, err := src.Fetch(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("source %s: %w", src.Name(), err)
+			}
+			list = d.Proms
+		}
+		for _, p := range list {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				proms = append(proms, p)
+			}
+		}
+	}
+	return proms, nil
+}
+
+// newRuleSources 根据配置创建规则来源
+func newRuleSources(cfg *config.Config, r *Reloader) []RuleSource {
+	var sources []RuleSource
+	for _, c := range cfg.Sources() {
+		switch c.Type {
+		case config.SourceGateway:
+			sources = append(sources, &gatewaySource{name: c.Name, reloader: r})
+		case config.SourceFile:
+			sources = append(sources, newFileSource(c, r.logger))
+		case config.SourcePrometheus:
+			sources = append(sources, newPromFileSource(c, r.logger))
+		}
+	}
+	return sources
+}
+
+// combineSources 合并多个来源的规则和数据源并按数据源分组. 规则或数据源ID重复且内容不同时视为冲突,
+// 使用配置在前的来源, 返回所有冲突的说明.
+func combineSources(sources []RuleSource, data []*SourceData) ([]rule.PromRules, []string) {
+	var conflicts []string
+
+	proms := map[int64]rule.Prom{}
+	promSources := map[int64]string{}
+	for i, d := range data {
+		for _, p := range d.Proms {
+			name, ok := promSources[p.ID]
+			if !ok {
+				proms[p.ID] = p
+				promSources[p.ID] = sources[i].Name()
+				continue
+			}
+			if !reflect.DeepEqual(proms[p.ID], p) {
+				conflicts = append(conflicts, fmt.Sprintf("prom %d is defined by both %s and %s, using %s", p.ID, name, sources[i].Name(), name))
+			}
+		}
+	}
+
+	var rules rule.Rules
+	ruleSources := map[int64]string{}
+	ruleHashes := map[int64]string{}
+	for i, d := range data {
+		for _, r := range d.Rules {
+			name, ok := ruleSources[r.ID]
+			if !ok {
+				rules = append(rules, r)
+				ruleSources[r.ID] = sources[i].Name()
+				ruleHashes[r.ID] = r.Hash()
+				continue
+			}
+			if ruleHashes[r.ID] != r.Hash() {
+				conflicts = append(conflicts, fmt.Sprintf("rule %d is defined by both %s and %s, using %s", r.ID, name, sources[i].Name(), name))
+			}
+		}
+	}
+
+	promRules := rules.PromRules()
+	for i := range promRules {
+		if p, ok := proms[promRules[i].Prom.ID]; ok {
+			promRules[i].Prom = p
+		}
+	}
+	sort.Slice(promRules, func(i, j int) bool { return promRules[i].Prom.ID < promRules[j].Prom.ID })
+
+	return promRules, conflicts
+}
@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// watchDebounce 文件变化后等待的时间, 合并编辑器保存文件时产生的多个事件
+var watchDebounce = time.Second
+
+// fileSource 本地网关格式的规则文件, 文件中可以同时定义规则和数据源:
+//
+//	proms:
+//	  - id: 1
+//	    url: http://prometheus:9090
+//	rules:
+//	  - id: 100
+//	    prom_id: 1
+//	    expr: up
+//	    op: "=="
+//	    value: "0"
+//
+// 也可以直接使用网关接口的响应或规则数组. 与网关来源一致, 未知字段被忽略.
+type fileSource struct {
+	name   string
+	files  []string
+	watch  bool
+	logger *zap.Logger
+}
+
+func newFileSource(cfg config.RuleSourceConfig, logger *zap.Logger) *fileSource {
+	return &fileSource{name: cfg.Name, files: cfg.Files, watch: cfg.WatchEnabled(), logger: logger}
+}
+
+func (s *fileSource) Name() string {
+	return s.name
+}
+
+// gatewayRuleFile 网关格式的规则文件
+type gatewayRuleFile struct {
+	Proms []rule.Prom `yaml:"proms"`
+	Rules rule.Rules  `yaml:"rules"`
+
+	// 网关规则接口的响应格式
+	Code int        `yaml:"code"`
+	Msg  string     `yaml:"msg"`
+	Data rule.Rules `yaml:"data"`
+}
+
+// parseGatewayRuleFile 解析网关格式的规则文件. JSON 是 YAML 的子集, 两种格式使用相同的解析方式
+func parseGatewayRuleFile(content []byte) (*gatewayRuleFile, error) {
+	var top interface{}
+	if err := yaml.Unmarshal(content, &top); err != nil {
+		return nil, err
+	}
+	if _, ok := top.([]interface{}); ok {
+		var rules rule.Rules
+		if err := yaml.Unmarshal(content, &rules); err != nil {
+			return nil, err
+		}
+		return &gatewayRuleFile{Rules: rules}, nil
+	}
+
+	var f gatewayRuleFile
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, err
+	}
+	if f.Code != 0 {
+		return nil, fmt.Errorf("api error: %s", f.Msg)
+	}
+	f.Rules = append(f.Rules, f.Data...)
+	return &f, nil
+}
+
+func (s *fileSource) Fetch(ctx context.Context) (*SourceData, error) {
+	files, err := expandFiles(s.files)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &SourceData{}
+	seen := map[int64]string{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parseGatewayRuleFile(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		for _, r := range f.Rules {
+			if prev, ok := seen[r.ID]; ok {
+				return nil, fmt.Errorf("%s: rule %d: duplicate rule id, already defined in %s", file, r.ID, prev)
+			}
+			seen[r.ID] = file
+			if r.PromID <= 0 {
+				return nil, fmt.Errorf("%s: rule %d: prom_id is required", file, r.ID)
+			}
+			if err := validateRule(r); err != nil {
+				return nil, fmt.Errorf("%s: rule %d: %w", file, r.ID, err)
+			}
+		}
+		data.Proms = append(data.Proms, f.Proms...)
+		data.Rules = append(data.Rules, f.Rules...)
+	}
+
+	s.logger.Info("rules loaded from files",
+		zap.String("source", s.name),
+		zap.Int("files", len(files)),
+		zap.Int("proms", len(data.Proms)),
+		zap.Int("rules", len(data.Rules)),
+	)
+	return data, nil
+}
+
+func (s *fileSource) Watch(ctx context.Context, notify func()) {
+	if s.watch {
+		watchFiles(ctx, s.name, s.files, notify, s.logger)
+	}
+}
+
+// promFileSource 原生 Prometheus 规则文件, 按规则组名称映射到数据源. 记录规则会被忽略.
+// 规则ID由来源名称、规则组名称和告警名称生成, 文件中规则的顺序变化不影响告警状态.
+type promFileSource struct {
+	name    string
+	files   []string
+	watch   bool
+	mapping *config.DatasourceMapping
+	logger  *zap.Logger
+}
+
+func newPromFileSource(cfg config.RuleSourceConfig, logger *zap.Logger) *promFileSource {
+	return &promFileSource{
+		name:    cfg.Name,
+		files:   cfg.Files,
+		watch:   cfg.WatchEnabled(),
+		mapping: cfg.DatasourceMapping,
+		logger:  logger,
+	}
+}
+
+func (s *promFileSource) Name() string {
+	return s.name
+}
+
+func (s *promFileSource) Fetch(ctx context.Context) (*SourceData, error) {
+	files, err := expandFiles(s.files)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &SourceData{}
+	ids := map[int64]string{}
+	skipped := 0
+	for _, file := range files {
+		groups, errs := rulefmt.ParseFile(file)
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+
+		for _, g := range groups.Groups {
+			promID, ok := s.mapping.Datasource(g.Name)
+			if !ok {
+				return nil, fmt.Errorf("%s: group %q: no datasource mapped", file, g.Name)
+			}
+
+			// 同一规则组内的同名告警按出现顺序区分
+			occurrences := map[string]int{}
+			for _, r := range g.Rules {
+				if r.Alert.Value == "" {
+					skipped++
+					continue
+				}
+				alert := r.Alert.Value
+				occurrences[alert]++
+
+				id := promRuleID(s.name, g.Name, alert, occurrences[alert])
+				key := fmt.Sprintf("%s: group %q, alert %q", file, g.Name, alert)
+				if prev, ok := ids[id]; ok {
+					return nil, fmt.Errorf("%s: rule id collides with %s, rename the group or alert", key, prev)
+				}
+				ids[id] = key
+
+				data.Rules = append(data.Rules, newPromFileRule(id, promID, alert, r))
+			}
+		}
+	}
+
+	s.logger.Info("rules loaded from prometheus rule files",
+		zap.String("source", s.name),
+		zap.Int("files", len(files)),
+		zap.Int("rules", len(data.Rules)),
+		zap.Int("skipped_recording_rules", skipped),
+	)
+	return data, nil
+}
+
+func (s *promFileSource) Watch(ctx context.Context, notify func()) {
+	if s.watch {
+		watchFiles(ctx, s.name, s.files, notify, s.logger)
+	}
+}
+
+// promRuleID 为原生规则生成稳定的规则ID, 限制在 2^53 以内以便在 JSON 中精确表示
+func promRuleID(source, group, alert string, n int) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\xff%s\xff%s\xff%d", source, group, alert, n)
+	return int64(h.Sum64()&(1<<53-1)) + 1
+}
+
+// newPromFileRule 将原生告警规则转换为规则, 告警名称作为 alertname 标签, 仅保留 summary 和 description 注解
+func newPromFileRule(id, promID int64, alert string, r rulefmt.RuleNode) rule.Rule {
+	labels := common.NewBuilder(common.FromMap(r.Labels))
+	labels.Set(common.AlertName, alert)
+
+	var forDuration string
+	if r.For > 0 {
+		forDuration = time.Duration(r.For).String()
+	}
+
+	return rule.Rule{
+		ID:          id,
+		PromID:      promID,
+		Expr:        strings.TrimSpace(r.Expr.Value),
+		For:         forDuration,
+		Labels:      labels.Labels(),
+		Summary:     r.Annotations["summary"],
+		Description: r.Annotations["description"],
+	}
+}
+
+// expandFiles 展开文件通配符, 返回排序去重后的文件列表
+func expandFiles(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// watchFiles 监听规则文件所在目录, 目录中的文件变化后调用 notify. 监听目录而不是文件,
+// 以便发现新增的文件以及通过重命名或替换符号链接(如 Kubernetes ConfigMap)更新的文件.
+func watchFiles(ctx context.Context, source string, patterns []string, notify func(), logger *zap.Logger) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error("failed to create file watcher, falling back to reload interval",
+			zap.String("source", source),
+			zap.Error(err),
+		)
+		return
+	}
+	defer watcher.Close()
+
+	dirs := map[string]bool{}
+	for _, pattern := range patterns {
+		dir := filepath.Dir(pattern)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			logger.Warn("failed to watch rule directory, falling back to reload interval",
+				zap.String("source", source),
+				zap.String("dir", dir),
+				zap.Error(err),
+			)
+			continue
+		}
+		logger.Info("watching rule directory", zap.String("source", source), zap.String("dir", dir))
+	}
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || strings.HasPrefix(filepath.Base(event.Name), ".tmp-") {
+				continue
+			}
+			logger.Debug("rule file changed", zap.String("source", source), zap.String("file", event.Name), zap.String("op", event.Op.String()))
+			timer.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("file watcher error", zap.String("source", source), zap.Error(err))
+		case <-timer.C:
+			logger.Info("rule files changed, triggering sync", zap.String("source", source))
+			notify()
+		}
+	}
+}
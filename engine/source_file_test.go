@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alertengine/config"
+
+	"go.uber.org/zap"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestFileSource(files ...string) *fileSource {
+	return newFileSource(config.RuleSourceConfig{Name: "local", Files: files}, zap.NewNop())
+}
+
+func TestFileSourceFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		rules   string
+		proms   int
+	}{
+		{
+			name: "yaml",
+			file: "rules.yml",
+			content: `
+proms:
+  - id: 1
+    url: http://prometheus:9090
+rules:
+  - id: 1
+    prom_id: 1
+    expr: up
+    op: "=="
+    value: "0"
+    for: 1m
+  - id: 2
+    prom_id: 1
+    expr: node_load1
+`,
+			rules: "1,2",
+			proms: 1,
+		},
+		{
+			name:    "gateway response with extra fields",
+			file:    "rules.json",
+			content: `{"code": 0, "msg": "ok", "total": 2, "data": [{"id": 3, "prom_id": 1, "expr": "up", "enabled": true, "created_at": "2026-01-01"}, {"id": 4, "prom_id": 1, "expr": "up"}]}`,
+			rules:   "3,4",
+		},
+		{
+			name:    "rule array",
+			file:    "rules.json",
+			content: `[{"id": 5, "prom_id": 1, "expr": "up", "owner": "infra"}]`,
+			rules:   "5",
+		},
+		{
+			name:    "unknown fields",
+			file:    "rules.yml",
+			content: "version: 2\nrules:\n  - id: 6\n    prom_id: 1\n    expr: up\n    severity: critical\n",
+			rules:   "6",
+		},
+	}
+
+	for _, tc := range tests {
+		path := filepath.Join(t.TempDir(), tc.file)
+		writeTestFile(t, path, tc.content)
+
+		data, err := newTestFileSource(path).Fetch(context.Background())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := ruleIDs(data.Rules); got != tc.rules {
+			t.Errorf("%s: got rules %s, want %s", tc.name, got, tc.rules)
+		}
+		if len(data.Proms) != tc.proms {
+			t.Errorf("%s: got %d proms, want %d", tc.name, len(data.Proms), tc.proms)
+		}
+	}
+}
+
+func TestFileSourceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"api error", `{"code": 1, "msg": "unauthorized", "data": []}`, "api error: unauthorized"},
+		{"missing prom_id", `[{"id": 1, "expr": "up"}]`, "rule 1: prom_id is required"},
+		{"missing expr", `[{"id": 1, "prom_id": 1}]`, "rule 1: invalid rule: expr is required"},
+		{"invalid for", `[{"id": 1, "prom_id": 1, "expr": "up", "for": "soon"}]`, `rule 1: invalid rule: invalid for duration "soon"`},
+		{"invalid syntax", "rules: [", "rules.yml: yaml"},
+		{"wrong type", "rules: {id: 1}", "rules.yml: yaml"},
+	}
+
+	for _, tc := range tests {
+		path := filepath.Join(t.TempDir(), "rules.yml")
+		writeTestFile(t, path, tc.content)
+
+		_, err := newTestFileSource(path).Fetch(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestFileSourceDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.json"), `[{"id": 1, "prom_id": 1, "expr": "up"}]`)
+	writeTestFile(t, filepath.Join(dir, "b.json"), `[{"id": 2, "prom_id": 1, "expr": "up"}, {"id": 1, "prom_id": 2, "expr": "up"}]`)
+
+	_, err := newTestFileSource(filepath.Join(dir, "*.json")).Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rule 1: duplicate rule id, already defined in "+filepath.Join(dir, "a.json")) {
+		t.Errorf("got error %v, want duplicate rule id", err)
+	}
+}
+
+func TestWatchFilesDebounce(t *testing.T) {
+	debounce := watchDebounce
+	watchDebounce = 200 * time.Millisecond
+	t.Cleanup(func() { watchDebounce = debounce })
+
+	dir := t.TempDir()
+	var notified atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchFiles(ctx, "local", []string{filepath.Join(dir, "*.yml")}, func() { notified.Add(1) }, zap.NewNop())
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	// 等待监听生效
+	time.Sleep(100 * time.Millisecond)
+
+	// 连续的变化合并为一次同步
+	for i := 0; i < 3; i++ {
+		writeTestFile(t, filepath.Join(dir, "rules.yml"), strings.Repeat("#", i+1))
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := notified.Load(); n != 0 {
+		t.Fatalf("notified %d times before the debounce elapsed", n)
+	}
+	time.Sleep(3 * watchDebounce)
+	if n := notified.Load(); n != 1 {
+		t.Fatalf("notified %d times, want 1", n)
+	}
+
+	// 原子写入的临时文件不触发同步
+	writeTestFile(t, filepath.Join(dir, ".tmp-rules.yml"), "#")
+	time.Sleep(3 * watchDebounce)
+	if n := notified.Load(); n != 1 {
+		t.Errorf("notified %d times after temp file change, want 1", n)
+	}
+
+	// 新增文件触发同步
+	writeTestFile(t, filepath.Join(dir, "more.yml"), "#")
+	time.Sleep(3 * watchDebounce)
+	if n := notified.Load(); n != 2 {
+		t.Errorf("notified %d times after new file, want 2", n)
+	}
+}
@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"alertengine/rule"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxErrorBody 错误信息中包含的响应内容的最大长度
+	maxErrorBody = 512
+
+	// maxPages 分页获取规则时的最大页数, 防止网关返回错误的分页信息时无限请求
+	maxPages = 10000
+)
+
+// gatewaySource 从网关接口获取规则和数据源. 网关返回 ETag 或 Last-Modified 时, 下次同步发送条件请求,
+// 网关返回 304 时使用上次获取的列表.
+type gatewaySource struct {
+	name     string
+	reloader *Reloader
+
+	mu         sync.Mutex
+	rulesCache validators
+	rules      rule.Rules
+	promsCache validators
+	proms      []rule.Prom
+}
+
+// validators 上次响应的校验信息, 只用于相同地址的请求
+type validators struct {
+	url          string
+	etag         string
+	lastModified string
+}
+
+// empty 判断响应是否没有可用于条件请求的校验信息
+func (v validators) empty() bool {
+	return v.etag == "" && v.lastModified == ""
+}
+
+func (s *gatewaySource) Name() string {
+	return s.name
+}
+
+func (s *gatewaySource) Fetch(ctx context.Context) (*SourceData, error) {
+	client := s.reloader.gatewayClient()
+
+	// 获取规则列表
+	rules, err := s.fetchRules(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取数据源列表
+	proms, err := s.fetchProms(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SourceData{Proms: proms, Rules: rules}, nil
+}
+
+// FetchProms 只获取数据源列表
+func (s *gatewaySource) FetchProms(ctx context.Context) ([]rule.Prom, error) {
+	return s.fetchProms(ctx, s.reloader.gatewayClient())
+}
+
+// fetchRules 获取规则列表. 配置 gateway.page_size 时分页获取, 只有第一页发送条件请求,
+// 因此网关返回的 ETag 和 Last-Modified 需要对应完整的规则列表.
+func (s *gatewaySource) fetchRules(ctx context.Context, client *http.Client) (rule.Rules, error) {
+	cfg := s.reloader.Config()
+	base := cfg.Gateway.URL + cfg.Gateway.RulePath
+	pageSize := cfg.Gateway.PageSize
+
+	s.mu.Lock()
+	cached, cachedRules := s.rulesCache, s.rules
+	s.mu.Unlock()
+
+	var (
+		rules  rule.Rules
+		first  validators
+		cursor string
+		seen   = map[int64]bool{}
+	)
+	for page := 1; ; page++ {
+		if page > maxPages {
+			return nil, fmt.Errorf("more than %d pages, check the pagination of the gateway", maxPages)
+		}
+
+		reqURL, err := pageURL(base, pageSize, page, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		var prev *validators
+		if page == 1 {
+			prev = &cached
+		}
+		var rulesResp rule.RulesResp
+		v, notModified, err := s.get(ctx, client, reqURL, prev, &rulesResp)
+		if err != nil {
+			if pageSize > 0 {
+				return nil, fmt.Errorf("page %d: %w", page, err)
+			}
+			return nil, err
+		}
+		if notModified {
+			s.reloader.logger.Info("rules not modified", zap.String("source", s.name), zap.Int("count", len(cachedRules)))
+			return append(rule.Rules(nil), cachedRules...), nil
+		}
+		if rulesResp.Code != 0 {
+			return nil, fmt.Errorf("api error: %s", rulesResp.Msg)
+		}
+		if page == 1 {
+			first = v
+		}
+		if pageSize <= 0 {
+			rules = rulesResp.Data
+			break
+		}
+
+		// 网关忽略分页参数时每页返回相同的规则, 超过每页数量或没有新规则时停止, 避免重复请求到 maxPages
+		if len(rulesResp.Data) > pageSize {
+			return nil, fmt.Errorf("page %d: got %d rules with page_size %d, check that the gateway supports pagination", page, len(rulesResp.Data), pageSize)
+		}
+		added := 0
+		for _, r := range rulesResp.Data {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				rules = append(rules, r)
+				added++
+			}
+		}
+		if len(rulesResp.Data) > 0 && added == 0 {
+			return nil, fmt.Errorf("page %d: no new rules, check that the gateway supports pagination", page)
+		}
+		if rulesResp.NextCursor != "" {
+			// 游标分页, 下一页游标为空时结束
+			if rulesResp.NextCursor == cursor {
+				return nil, fmt.Errorf("page %d: gateway returned the same cursor %q", page, cursor)
+			}
+			cursor = rulesResp.NextCursor
+			continue
+		}
+		if cursor != "" || len(rulesResp.Data) < pageSize || (rulesResp.Total > 0 && len(rules) >= rulesResp.Total) {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.rulesCache, s.rules = first, rules
+	s.mu.Unlock()
+
+	s.reloader.logger.Info("rules fetched", zap.String("source", s.name), zap.Int("count", len(rules)))
+	return append(rule.Rules(nil), rules...), nil
+}
+
+// fetchProms 获取数据源列表
+func (s *gatewaySource) fetchProms(ctx context.Context, client *http.Client) ([]rule.Prom, error) {
+	cfg := s.reloader.Config()
+	reqURL := cfg.Gateway.URL + cfg.Gateway.PromPath
+
+	s.mu.Lock()
+	cached, cachedProms := s.promsCache, s.proms
+	s.mu.Unlock()
+
+	var promsResp rule.PromsResp
+	v, notModified, err := s.get(ctx, client, reqURL, &cached, &promsResp)
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		s.reloader.logger.Info("proms not modified", zap.String("source", s.name), zap.Int("count", len(cachedProms)))
+		return append([]rule.Prom(nil), cachedProms...), nil
+	}
+	if promsResp.Code != 0 {
+		return nil, fmt.Errorf("api error: %s", promsResp.Msg)
+	}
+
+	s.mu.Lock()
+	s.promsCache, s.proms = v, promsResp.Data
+	s.mu.Unlock()
+
+	s.reloader.logger.Info("proms fetched", zap.String("source", s.name), zap.Int("count", len(promsResp.Data)))
+	return append([]rule.Prom(nil), promsResp.Data...), nil
+}
+
+// get 请求网关并将响应解码到 out, 返回响应的校验信息. prev 与本次请求地址相同时发送条件请求,
+// 网关返回 304 时 notModified 为 true, out 保持不变.
+func (s *gatewaySource) get(ctx context.Context, client *http.Client, reqURL string, prev *validators, out interface{}) (validators, bool, error) {
+	v := validators{url: reqURL}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return v, false, err
+	}
+	// 显式声明 gzip, 配置网关认证时使用的 Transport 不会自动解压
+	req.Header.Set("Accept-Encoding", "gzip")
+	conditional := prev != nil && prev.url == reqURL && !prev.empty()
+	if conditional {
+		if prev.etag != "" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return v, false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return *prev, true, nil
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return v, false, fmt.Errorf("decode failed: %w", err)
+	}
+	defer body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return v, false, fmt.Errorf("unexpected status %s: %s", resp.Status, bodySnippet(body))
+	}
+
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return v, false, fmt.Errorf("decode failed: %w", err)
+	}
+
+	v.etag = resp.Header.Get("ETag")
+	v.lastModified = resp.Header.Get("Last-Modified")
+	return v, false, nil
+}
+
+// pageURL 生成分页请求的地址. 第一页使用 page=1, 网关返回下一页游标后使用 cursor 参数
+func pageURL(base string, pageSize, page int, cursor string) (string, error) {
+	if pageSize <= 0 {
+		return base, nil
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("page_size", strconv.Itoa(pageSize))
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	} else {
+		q.Set("page", strconv.Itoa(page))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// decodeBody 按 Content-Encoding 返回解压后的响应内容
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	return gzip.NewReader(resp.Body)
+}
+
+// bodySnippet 读取响应内容的开头部分, 用于错误信息
+func bodySnippet(r io.Reader) string {
+	b, _ := io.ReadAll(io.LimitReader(r, maxErrorBody+1))
+	b = bytes.TrimSpace(b)
+	if len(b) > maxErrorBody {
+		return string(b[:maxErrorBody]) + "..."
+	}
+	return string(b)
+}
@@ -0,0 +1,361 @@
+package engine
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"alertengine/config"
+	"alertengine/rule"
+
+	"go.uber.org/zap"
+)
+
+// testGateway 模拟网关的规则和数据源接口, 记录收到的请求
+type testGateway struct {
+	mu       sync.Mutex
+	rules    rule.Rules
+	requests []*http.Request
+	handler  func(w http.ResponseWriter, r *http.Request, rules rule.Rules)
+}
+
+func (g *testGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	g.requests = append(g.requests, r)
+	rules := g.rules
+	g.mu.Unlock()
+
+	if r.URL.Path == "/api/v1/proms" {
+		writeJSON(w, rule.PromsResp{Data: []rule.Prom{{ID: 1, URL: "http://prometheus:9090"}}})
+		return
+	}
+	g.handler(w, r, rules)
+}
+
+func (g *testGateway) requestCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.requests)
+}
+
+func (g *testGateway) ruleRequests() []*http.Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var res []*http.Request
+	for _, r := range g.requests {
+		if r.URL.Path == "/api/v1/rules" {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func testRules(n int) rule.Rules {
+	rules := make(rule.Rules, n)
+	for i := range rules {
+		rules[i] = rule.Rule{ID: int64(i + 1), PromID: 1, Expr: "up", Op: ">", Value: strconv.Itoa(i)}
+	}
+	return rules
+}
+
+func newTestGatewaySource(t *testing.T, g *testGateway, pageSize int) *gatewaySource {
+	t.Helper()
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Gateway.URL = srv.URL
+	cfg.Gateway.PageSize = pageSize
+
+	r := &Reloader{logger: zap.NewNop()}
+	r.cfg.Store(cfg)
+	r.gateway.Store(&gatewayRoundTripper{rt: http.DefaultTransport})
+	return &gatewaySource{name: "gateway", reloader: r}
+}
+
+func ruleIDs(rules rule.Rules) string {
+	ids := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = strconv.FormatInt(r.ID, 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestGatewaySourceNoPagination(t *testing.T) {
+	g := &testGateway{rules: testRules(3), handler: func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		writeJSON(w, rule.RulesResp{Data: rules})
+	}}
+	s := newTestGatewaySource(t, g, 0)
+
+	data, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleIDs(data.Rules); got != "1,2,3" {
+		t.Errorf("rules = %s", got)
+	}
+	if len(data.Proms) != 1 {
+		t.Errorf("proms = %v", data.Proms)
+	}
+	if q := g.ruleRequests()[0].URL.RawQuery; q != "" {
+		t.Errorf("unexpected query %q without page_size", q)
+	}
+}
+
+func TestGatewaySourceNotModified(t *testing.T) {
+	etag := `"v1"`
+	g := &testGateway{rules: testRules(2)}
+	g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		writeJSON(w, rule.RulesResp{Data: rules})
+	}
+	s := newTestGatewaySource(t, g, 0)
+
+	first, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := g.ruleRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d rule requests, want 2", len(reqs))
+	}
+	if reqs[0].Header.Get("If-None-Match") != "" {
+		t.Error("first request should not be conditional")
+	}
+	if got := reqs[1].Header.Get("If-None-Match"); got != etag {
+		t.Errorf("If-None-Match = %q, want %q", got, etag)
+	}
+	if ruleIDs(second.Rules) != ruleIDs(first.Rules) {
+		t.Errorf("rules after 304 = %s, want %s", ruleIDs(second.Rules), ruleIDs(first.Rules))
+	}
+
+	// 内容变化后使用新的列表
+	etag = `"v2"`
+	g.mu.Lock()
+	g.rules = testRules(3)
+	g.mu.Unlock()
+	third, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleIDs(third.Rules); got != "1,2,3" {
+		t.Errorf("rules after change = %s", got)
+	}
+}
+
+func TestGatewaySourceLastModified(t *testing.T) {
+	const lastModified = "Mon, 01 Jan 2024 00:00:00 GMT"
+	g := &testGateway{rules: testRules(1)}
+	g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		writeJSON(w, rule.RulesResp{Data: rules})
+	}
+	s := newTestGatewaySource(t, g, 0)
+
+	for i := 0; i < 2; i++ {
+		data, err := s.Fetch(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ruleIDs(data.Rules); got != "1" {
+			t.Errorf("fetch %d: rules = %s", i, got)
+		}
+	}
+	if got := g.ruleRequests()[1].Header.Get("If-Modified-Since"); got != lastModified {
+		t.Errorf("If-Modified-Since = %q", got)
+	}
+}
+
+func TestGatewaySourceUnexpectedNotModified(t *testing.T) {
+	// 没有发送条件请求时 304 视为错误
+	g := &testGateway{handler: func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		w.WriteHeader(http.StatusNotModified)
+	}}
+	s := newTestGatewaySource(t, g, 0)
+
+	if _, err := s.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "304") {
+		t.Errorf("expected unexpected status error, got %v", err)
+	}
+}
+
+func TestGatewaySourceCursorPagination(t *testing.T) {
+	g := &testGateway{rules: testRules(5)}
+	g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		end := min(start+size, len(rules))
+		resp := rule.RulesResp{Data: rules[start:end]}
+		if end < len(rules) {
+			resp.NextCursor = strconv.Itoa(end)
+		}
+		writeJSON(w, resp)
+	}
+	s := newTestGatewaySource(t, g, 2)
+
+	data, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleIDs(data.Rules); got != "1,2,3,4,5" {
+		t.Errorf("rules = %s", got)
+	}
+
+	var queries []string
+	for _, r := range g.ruleRequests() {
+		queries = append(queries, r.URL.RawQuery)
+	}
+	want := []string{"page=1&page_size=2", "cursor=2&page_size=2", "cursor=4&page_size=2"}
+	if strings.Join(queries, " ") != strings.Join(want, " ") {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+}
+
+func TestGatewaySourcePagePagination(t *testing.T) {
+	for _, tc := range []struct {
+		rules     int
+		withTotal bool
+		requests  int
+	}{
+		// 最后一页不满时结束
+		{rules: 5, withTotal: false, requests: 3},
+		// 没有 total 时需要一个空页确认结束
+		{rules: 4, withTotal: false, requests: 3},
+		// 累计数量达到 total 时结束
+		{rules: 4, withTotal: true, requests: 2},
+		{rules: 0, withTotal: true, requests: 1},
+	} {
+		g := &testGateway{rules: testRules(tc.rules)}
+		g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+			size, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			start := min((page-1)*size, len(rules))
+			end := min(start+size, len(rules))
+			resp := rule.RulesResp{Data: rules[start:end]}
+			if tc.withTotal {
+				resp.Total = len(rules)
+			}
+			writeJSON(w, resp)
+		}
+		s := newTestGatewaySource(t, g, 2)
+
+		data, err := s.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%+v: %v", tc, err)
+		}
+		if got, want := ruleIDs(data.Rules), ruleIDs(testRules(tc.rules)); got != want {
+			t.Errorf("%+v: rules = %s, want %s", tc, got, want)
+		}
+		if got := len(g.ruleRequests()); got != tc.requests {
+			t.Errorf("%+v: got %d requests, want %d", tc, got, tc.requests)
+		}
+	}
+}
+
+func TestGatewaySourcePaginationIgnored(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules rule.Rules
+	}{
+		// 网关忽略分页参数, 每次返回全部规则
+		{name: "more than page_size", rules: testRules(3)},
+		// 网关忽略页码, 每次返回同一页
+		{name: "same page", rules: testRules(2)},
+	} {
+		g := &testGateway{rules: tc.rules, handler: func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+			writeJSON(w, rule.RulesResp{Data: rules})
+		}}
+		s := newTestGatewaySource(t, g, 2)
+
+		_, err := s.Fetch(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "pagination") {
+			t.Errorf("%s: expected pagination error, got %v", tc.name, err)
+		}
+		if n := g.requestCount(); n > 2 {
+			t.Errorf("%s: got %d requests, want at most 2", tc.name, n)
+		}
+	}
+}
+
+func TestGatewaySourceRepeatedCursor(t *testing.T) {
+	g := &testGateway{rules: testRules(4)}
+	g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		writeJSON(w, rule.RulesResp{Data: rules[start : start+2], NextCursor: "2"})
+	}
+	s := newTestGatewaySource(t, g, 2)
+
+	if _, err := s.Fetch(context.Background()); err == nil {
+		t.Error("expected error for repeated cursor")
+	}
+	if n := len(g.ruleRequests()); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
+
+func TestGatewaySourceGzip(t *testing.T) {
+	g := &testGateway{rules: testRules(3)}
+	g.handler = func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			writeJSON(w, rule.RulesResp{Code: 1, Msg: "gzip not requested"})
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		json.NewEncoder(zw).Encode(rule.RulesResp{Data: rules})
+		zw.Close()
+	}
+	s := newTestGatewaySource(t, g, 0)
+
+	data, err := s.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleIDs(data.Rules); got != "1,2,3" {
+		t.Errorf("rules = %s", got)
+	}
+}
+
+func TestGatewaySourceStatusError(t *testing.T) {
+	g := &testGateway{handler: func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "overloaded "+strings.Repeat("x", 2*maxErrorBody))
+	}}
+	s := newTestGatewaySource(t, g, 0)
+
+	_, err := s.Fetch(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "503") || !strings.Contains(msg, "overloaded") || !strings.HasSuffix(msg, "...") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(msg) > 2*maxErrorBody {
+		t.Errorf("error message not truncated: %d bytes", len(msg))
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	"go.uber.org/zap"
+)
+
+func TestCombineSources(t *testing.T) {
+	sources := []RuleSource{&fileSource{name: "gateway"}, &fileSource{name: "local"}, &fileSource{name: "extra"}}
+
+	changed := rule.Rule{ID: 2, PromID: 1, Expr: "up", Op: ">", Value: "10"}
+	moved := rule.Rule{ID: 3, PromID: 2, Expr: "up", Op: ">", Value: "2"}
+	data := []*SourceData{
+		{
+			Proms: []rule.Prom{{ID: 1, URL: "http://prometheus-1:9090"}},
+			Rules: testRules(3),
+		},
+		{
+			Proms: []rule.Prom{
+				{ID: 1, URL: "http://prometheus-1:9090"},
+				{ID: 2, URL: "http://prometheus-2:9090"},
+			},
+			// 与第一个来源相同的规则不算冲突
+			Rules: rule.Rules{testRules(1)[0], changed, {ID: 10, PromID: 2, Expr: "up"}},
+		},
+		{
+			Proms: []rule.Prom{
+				{ID: 2, URL: "http://other:9090", ExternalLabels: common.FromMap(map[string]string{"env": "test"})},
+				{ID: 3, URL: "http://prometheus-3:9090"},
+			},
+			Rules: rule.Rules{moved, {ID: 5, PromID: 4, Expr: "up"}},
+		},
+	}
+
+	promRules, conflicts := combineSources(sources, data)
+
+	wantConflicts := []string{
+		"prom 2 is defined by both local and extra, using local",
+		"rule 2 is defined by both gateway and local, using gateway",
+		"rule 3 is defined by both gateway and extra, using gateway",
+	}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("conflicts = %q, want %q", conflicts, wantConflicts)
+	}
+
+	// 按数据源ID排序, 没有规则的数据源不出现, 没有定义的数据源只有ID
+	want := []struct {
+		prom  rule.Prom
+		rules string
+	}{
+		{rule.Prom{ID: 1, URL: "http://prometheus-1:9090"}, "1,2,3"},
+		{rule.Prom{ID: 2, URL: "http://prometheus-2:9090"}, "10"},
+		{rule.Prom{ID: 4}, "5"},
+	}
+	if len(promRules) != len(want) {
+		t.Fatalf("got %d prom rules, want %d", len(promRules), len(want))
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(promRules[i].Prom, w.prom) {
+			t.Errorf("prom %d = %+v, want %+v", i, promRules[i].Prom, w.prom)
+		}
+		if got := ruleIDs(promRules[i].Rules); got != w.rules {
+			t.Errorf("prom %d: got rules %s, want %s", w.prom.ID, got, w.rules)
+		}
+	}
+	// 冲突时使用配置在前的来源
+	if r := promRules[0].Rules[1]; r.Value != "1" {
+		t.Errorf("rule 2 value = %q, want the gateway definition", r.Value)
+	}
+}
+
+func TestCombineSourcesNoConflicts(t *testing.T) {
+	sources := []RuleSource{&fileSource{name: "a"}, &fileSource{name: "b"}}
+	data := []*SourceData{{Rules: testRules(2)}, {Rules: testRules(2)}}
+
+	promRules, conflicts := combineSources(sources, data)
+	if len(conflicts) != 0 {
+		t.Errorf("unexpected conflicts: %q", conflicts)
+	}
+	if len(promRules) != 1 || ruleIDs(promRules[0].Rules) != "1,2" {
+		t.Errorf("unexpected prom rules: %+v", promRules)
+	}
+}
+
+func TestFetchProms(t *testing.T) {
+	g := &testGateway{rules: testRules(3), handler: func(w http.ResponseWriter, r *http.Request, rules rule.Rules) {
+		writeJSON(w, rule.RulesResp{Data: rules})
+	}}
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "rules.yml")
+	writeTestFile(t, path, `
+proms:
+  - id: 1
+    url: http://other:9090
+  - id: 2
+    url: http://prometheus-2:9090
+`)
+
+	cfg := config.DefaultConfig()
+	cfg.Gateway.URL = srv.URL
+	cfg.RuleSources = []config.RuleSourceConfig{
+		{Type: config.SourceGateway},
+		{Type: config.SourceFile, Files: []string{path}},
+	}
+
+	proms, err := FetchProms(context.Background(), cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ID 重复时使用配置在前的来源
+	want := []rule.Prom{{ID: 1, URL: "http://prometheus:9090"}, {ID: 2, URL: "http://prometheus-2:9090"}}
+	if !reflect.DeepEqual(proms, want) {
+		t.Errorf("proms = %+v, want %+v", proms, want)
+	}
+	// 只请求网关的数据源接口, 不获取规则
+	if n := len(g.ruleRequests()); n != 0 || g.requestCount() != 1 {
+		t.Errorf("got %d requests with %d rule requests, want only the prom request", g.requestCount(), n)
+	}
+
+	writeTestFile(t, path, "proms: [")
+	if _, err := FetchProms(context.Background(), cfg, zap.NewNop()); err == nil || !strings.Contains(err.Error(), "source file: ") {
+		t.Errorf("got error %v, want file source error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"text/template"
+)
+
+// templateDefs 模板公共变量定义, 与 Prometheus 告警模板保持一致
+const templateDefs = "{{$labels := .Labels}}{{$externalLabels := .ExternalLabels}}{{$value := .Value}}"
+
+// templateData 模板渲染数据
+type templateData struct {
+	Labels         map[string]string
+	ExternalLabels map[string]string
+	Value          float64
+}
+
+var templateFuncs = template.FuncMap{
+	"toUpper": strings.ToUpper,
+	"toLower": strings.ToLower,
+	"humanize": func(v float64) string {
+		if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprintf("%.4g", v)
+		}
+		if math.Abs(v) >= 1 {
+			prefix := ""
+			for _, p := range []string{"k", "M", "G", "T", "P", "E", "Z", "Y"} {
+				if math.Abs(v) < 1000 {
+					break
+				}
+				prefix = p
+				v /= 1000
+			}
+			return fmt.Sprintf("%.4g%s", v, prefix)
+		}
+		prefix := ""
+		for _, p := range []string{"m", "u", "n", "p", "f", "a", "z", "y"} {
+			if math.Abs(v) >= 1 {
+				break
+			}
+			prefix = p
+			v *= 1000
+		}
+		return fmt.Sprintf("%.4g%s", v, prefix)
+	},
+	"humanizePercentage": func(v float64) string {
+		return fmt.Sprintf("%.4g%%", v*100)
+	},
+}
+
+// checkTemplate 校验模板语法
+func checkTemplate(name, text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+
+	_, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(templateFuncs).
+		Parse(templateDefs + text)
+	if err != nil {
+		return fmt.Errorf("error parsing template %s: %w", name, err)
+	}
+	return nil
+}
+
+// expandTemplate 渲染告警模板, 不含模板语法的文本原样返回
+func expandTemplate(name, text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).
+		Option("missingkey=zero").
+		Funcs(templateFuncs).
+		Parse(templateDefs + text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error executing template %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	data := templateData{
+		Labels:         map[string]string{"instance": "node-1:9100", "job": "node"},
+		ExternalLabels: map[string]string{"cluster": "prod"},
+		Value:          0.8512,
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"{{ $labels.instance }} in {{ $externalLabels.cluster }}", "node-1:9100 in prod"},
+		{"{{ .Labels.job }}/{{ .ExternalLabels.cluster }}", "node/prod"},
+		{"{{ $labels.missing }}", ""},
+		{"{{ $externalLabels.missing }}", ""},
+		{"{{ $value }}", "0.8512"},
+		{"{{ humanizePercentage $value }}", "85.12%"},
+		{"{{ $labels.job | toUpper }}", "NODE"},
+		{"{{ toLower \"ABC\" }}", "abc"},
+		// 不含模板语法的文本不解析
+		{"{ $labels.job }", "{ $labels.job }"},
+	}
+
+	for _, tc := range tests {
+		got, err := expandTemplate("test", tc.text, data)
+		if err != nil {
+			t.Errorf("%q: %v", tc.text, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{999, "999"},
+		{1234, "1.234k"},
+		{1234567, "1.235M"},
+		{2.5e9, "2.5G"},
+		{-1500, "-1.5k"},
+		{0.5, "500m"},
+		{0.000123, "123u"},
+		{1.5e-9, "1.5n"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+	}
+
+	for _, tc := range tests {
+		got, err := expandTemplate("test", "{{ humanize $value }}", templateData{Value: tc.value})
+		if err != nil {
+			t.Errorf("%v: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("humanize(%v) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestExpandTemplateErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"{{ $labels.job", "error parsing template test"},
+		{"{{ unknownFunc }}", `function "unknownFunc" not defined`},
+		{"{{ .Missing }}", "error executing template test"},
+		{"{{ humanize $labels.job }}", "error executing template test"},
+	}
+
+	for _, tc := range tests {
+		_, err := expandTemplate("test", tc.text, templateData{Labels: map[string]string{"job": "node"}})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: got error %v, want it to contain %q", tc.text, err, tc.err)
+		}
+		if checkErr := checkTemplate("test", tc.text); strings.Contains(tc.err, "parsing") || strings.Contains(tc.err, "not defined") {
+			if checkErr == nil {
+				t.Errorf("%q: checkTemplate returned no error", tc.text)
+			}
+		} else if checkErr != nil {
+			t.Errorf("%q: checkTemplate: %v", tc.text, checkErr)
+		}
+	}
+}
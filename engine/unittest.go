@@ -0,0 +1,437 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"alertengine/common"
+	"alertengine/config"
+	"alertengine/rule"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// unitTestFile 规则单元测试文件, 格式与 promtool test rules 一致
+type unitTestFile struct {
+	RuleFiles          []string       `yaml:"rule_files"`
+	EvaluationInterval model.Duration `yaml:"evaluation_interval,omitempty"`
+	Tests              []testGroup    `yaml:"tests"`
+}
+
+// testGroup 一组共享输入序列的测试用例
+type testGroup struct {
+	Interval       model.Duration    `yaml:"interval"`
+	InputSeries    []series          `yaml:"input_series"`
+	AlertRuleTests []alertTestCase   `yaml:"alert_rule_test,omitempty"`
+	ExternalLabels map[string]string `yaml:"external_labels,omitempty"`
+	TestGroupName  string            `yaml:"name,omitempty"`
+}
+
+// series 输入序列, values 使用 promtool 的展开写法, 如 "0+10x100"、"1 _ stale"
+type series struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+// alertTestCase 在 eval_time 时刻期望处于 firing 状态的告警, alertname 为规则ID
+type alertTestCase struct {
+	EvalTime  model.Duration `yaml:"eval_time"`
+	Alertname string         `yaml:"alertname"`
+	ExpAlerts []alert        `yaml:"exp_alerts"`
+}
+
+type alert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// RunRuleUnitTests 执行规则单元测试文件, 测试结果写入 w, 全部通过时返回 true.
+// 规则使用引擎自身的评估器、模板渲染和重标记流程评估, 查询由内存中的 PromQL 引擎代替 Prometheus 执行.
+func RunRuleUnitTests(w io.Writer, cfg *config.Config, files ...string) bool {
+	success := true
+	for _, f := range files {
+		fmt.Fprintln(w, "Unit Testing: ", f)
+
+		if errs := ruleUnitTest(cfg, f); errs != nil {
+			fmt.Fprintln(w, "  FAILED:")
+			for _, err := range errs {
+				fmt.Fprintln(w, err.Error())
+				fmt.Fprintln(w)
+			}
+			success = false
+		} else {
+			fmt.Fprintln(w, "  SUCCESS")
+		}
+		fmt.Fprintln(w)
+	}
+	return success
+}
+
+func ruleUnitTest(cfg *config.Config, filename string) []error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return []error{err}
+	}
+
+	var unitTest unitTestFile
+	if err := yaml.UnmarshalStrict(data, &unitTest); err != nil {
+		return []error{err}
+	}
+	if unitTest.EvaluationInterval == 0 {
+		unitTest.EvaluationInterval = model.Duration(time.Minute)
+	}
+	evalInterval := time.Duration(unitTest.EvaluationInterval)
+
+	// 规则文件路径相对于测试文件所在目录
+	var rules []EvalRule
+	for _, pattern := range unitTest.RuleFiles {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filename), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return []error{err}
+		}
+		if len(matches) == 0 {
+			return []error{fmt.Errorf("no rule files match %q", pattern)}
+		}
+		for _, path := range matches {
+			rs, err := loadRuleFile(path)
+			if err != nil {
+				return []error{fmt.Errorf("%s: %w", path, err)}
+			}
+			rules = append(rules, rs...)
+		}
+	}
+
+	var errs []error
+	for _, tg := range unitTest.Tests {
+		if tg.Interval == 0 {
+			tg.Interval = unitTest.EvaluationInterval
+		}
+		errs = append(errs, tg.test(cfg, evalInterval, rules)...)
+	}
+	return errs
+}
+
+// test 按评估间隔逐步评估规则, 在每个 eval_time 对应的评估之后比较 firing 状态的告警
+func (tg *testGroup) test(cfg *config.Config, evalInterval time.Duration, rules []EvalRule) (errs []error) {
+	suite, lt, err := newLazyLoader(tg.seriesLoadingString())
+	if err != nil {
+		return []error{err}
+	}
+	defer func() {
+		if err := lt.close(suite); err != nil {
+			errs = append(errs, err)
+		}
+	}()
+	suite.SubqueryInterval = evalInterval
+
+	m := &Manager{
+		prom:   rule.Prom{ExternalLabels: common.FromMap(tg.ExternalLabels)},
+		logger: zap.NewNop(),
+	}
+	m.cfg.Store(cfg)
+	evalRules := make([]EvalRule, len(rules))
+	copy(evalRules, rules)
+	m.evaluator = &RuleEvaluator{
+		rules:     evalRules,
+		interval:  evalInterval,
+		queryFunc: engineQueryFunc(suite),
+	}
+
+	alertTests := make(map[model.Duration][]alertTestCase)
+	for _, tc := range tg.AlertRuleTests {
+		if tc.Alertname == "" {
+			return []error{fmt.Errorf("an item under alert_rule_test misses required attribute alertname at eval_time %v%s", tc.EvalTime, tg.nameLog())}
+		}
+		alertTests[tc.EvalTime] = append(alertTests[tc.EvalTime], tc)
+	}
+	evalTimes := make([]model.Duration, 0, len(alertTests))
+	for t := range alertTests {
+		evalTimes = append(evalTimes, t)
+	}
+	sort.Slice(evalTimes, func(i, j int) bool { return evalTimes[i] < evalTimes[j] })
+
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(tg.maxEvalTime())
+	curr := 0
+
+	for ts := mint; !ts.After(maxt); ts = ts.Add(evalInterval) {
+		var loadErr error
+		suite.WithSamplesTill(ts, func(err error) { loadErr = err })
+		if loadErr != nil {
+			return append(errs, loadErr)
+		}
+
+		m.evaluator.evaluate(suite.Context(), ts)
+
+		var evalErrs []error
+		for _, r := range m.evaluator.Rules() {
+			if r.Health == HealthBad {
+				evalErrs = append(evalErrs, fmt.Errorf("    rule: %s, time: %s, err: %s", r.ID, ts.Sub(mint), r.LastError))
+			}
+		}
+		if len(evalErrs) > 0 {
+			return append(errs, evalErrs...)
+		}
+
+		// eval_time 落在 [ts, ts+evalInterval) 内的用例使用本次评估的结果比较
+		for curr < len(evalTimes) &&
+			ts.Sub(mint) <= time.Duration(evalTimes[curr]) &&
+			time.Duration(evalTimes[curr]) < ts.Add(evalInterval).Sub(mint) {
+			got := m.unitTestAlerts()
+			for _, tc := range alertTests[evalTimes[curr]] {
+				var exp labelsAndAnnotations
+				for _, a := range tc.ExpAlerts {
+					lb := common.NewBuilder(common.FromMap(a.ExpLabels))
+					if _, ok := a.ExpLabels[common.AlertName]; !ok {
+						lb.Set(common.AlertName, tc.Alertname)
+					}
+					exp = append(exp, labelAndAnnotation{
+						Labels:      lb.Labels(),
+						Annotations: common.FromMap(nonEmpty(a.ExpAnnotations)),
+					})
+				}
+				gotAlerts := got[tc.Alertname]
+
+				sort.Sort(exp)
+				sort.Sort(gotAlerts)
+				if !exp.Equal(gotAlerts) {
+					var testName string
+					if tg.TestGroupName != "" {
+						testName = fmt.Sprintf("    name: %s,\n", tg.TestGroupName)
+					}
+					errs = append(errs, fmt.Errorf("%s    alertname: %s, time: %s, \n        exp:%v, \n        got:%v",
+						testName, tc.Alertname, tc.EvalTime.String(),
+						indentLines(exp.String(), "            "), indentLines(gotAlerts.String(), "            ")))
+				}
+			}
+			curr++
+		}
+	}
+
+	return errs
+}
+
+// loaderT 实现 promql 测试工具需要的 testutil.T, 内存存储的断言失败转换为错误返回.
+// FailNow 通过 panic 中止当前调用, 由 recover 恢复.
+type loaderT struct {
+	err error
+}
+
+func (t *loaderT) Errorf(format string, args ...interface{}) {
+	t.err = errors.Join(t.err, fmt.Errorf(format, args...))
+}
+
+func (t *loaderT) FailNow() {
+	panic(t)
+}
+
+// recover 恢复 FailNow 产生的 panic, 并将记录的错误写入 err, 需要在 defer 中直接调用
+func (t *loaderT) recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if r != t {
+		panic(r)
+	}
+	*err = t.err
+	if *err == nil {
+		*err = errors.New("test storage failed")
+	}
+}
+
+// newLazyLoader 创建加载输入序列的内存存储. 输入序列解析失败时存储已经创建, 关闭后返回错误, 避免临时目录泄漏
+func newLazyLoader(input string) (ll *promql.LazyLoader, lt *loaderT, err error) {
+	lt = &loaderT{}
+	defer lt.recover(&err)
+
+	ll, err = promql.NewLazyLoader(lt, input, promql.LazyLoaderOpts{
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+	})
+	if err != nil {
+		if ll != nil {
+			ll.Close()
+		}
+		return nil, nil, err
+	}
+	return ll, lt, lt.err
+}
+
+// close 关闭内存存储并删除临时目录
+func (t *loaderT) close(ll *promql.LazyLoader) (err error) {
+	defer t.recover(&err)
+	ll.Close()
+	return t.err
+}
+
+// unitTestAlerts 按规则ID汇总当前 firing 的告警, 标签和注解经过与通知相同的处理
+func (m *Manager) unitTestAlerts() map[string]labelsAndAnnotations {
+	got := make(map[string]labelsAndAnnotations)
+	for _, r := range m.evaluator.FiringRules() {
+		lset, keep := m.processLabels(r)
+		if !keep {
+			continue
+		}
+		annotations := m.expandAnnotations(r)
+		r.Labels = lset
+
+		got[r.ID] = append(got[r.ID], labelAndAnnotation{
+			Labels:      r.muteLabels(),
+			Annotations: common.FromMap(nonEmpty(annotations)),
+		})
+	}
+	return got
+}
+
+func (tg *testGroup) seriesLoadingString() string {
+	result := fmt.Sprintf("load %v\n", shortDuration(tg.Interval))
+	for _, is := range tg.InputSeries {
+		result += fmt.Sprintf("  %v %v\n", is.Series, is.Values)
+	}
+	return result
+}
+
+func (tg *testGroup) maxEvalTime() time.Duration {
+	var maxd model.Duration
+	for _, tc := range tg.AlertRuleTests {
+		if tc.EvalTime > maxd {
+			maxd = tc.EvalTime
+		}
+	}
+	return time.Duration(maxd)
+}
+
+func (tg *testGroup) nameLog() string {
+	if tg.TestGroupName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (in TestGroup %s)", tg.TestGroupName)
+}
+
+// engineQueryFunc 使用内存中的 PromQL 引擎执行即时查询, 结果处理与 queryPrometheus 一致
+func engineQueryFunc(suite *promql.LazyLoader) QueryFunc {
+	return func(ctx context.Context, expr string, ts time.Time) (bool, float64, map[string]string, error) {
+		q, err := suite.QueryEngine().NewInstantQuery(ctx, suite.Queryable(), nil, expr, ts)
+		if err != nil {
+			return false, 0, nil, err
+		}
+		defer q.Close()
+
+		res := q.Exec(ctx)
+		if res.Err != nil {
+			return false, 0, nil, res.Err
+		}
+
+		switch v := res.Value.(type) {
+		case promql.Vector:
+			if len(v) == 0 {
+				return false, 0, nil, nil
+			}
+			sort.Slice(v, func(i, j int) bool {
+				return labels.Compare(v[i].Metric, v[j].Metric) < 0
+			})
+			return true, v[0].F, v[0].Metric.Map(), nil
+		case promql.Scalar:
+			return true, v.V, nil, nil
+		default:
+			return false, 0, nil, fmt.Errorf("unsupported result type %s", res.Value.Type())
+		}
+	}
+}
+
+type labelAndAnnotation struct {
+	Labels      common.Labels
+	Annotations common.Labels
+}
+
+func (la labelAndAnnotation) String() string {
+	return "Labels:" + la.Labels.String() + "\nAnnotations:" + la.Annotations.String()
+}
+
+type labelsAndAnnotations []labelAndAnnotation
+
+func (la labelsAndAnnotations) Len() int      { return len(la) }
+func (la labelsAndAnnotations) Swap(i, j int) { la[i], la[j] = la[j], la[i] }
+func (la labelsAndAnnotations) Less(i, j int) bool {
+	diff := common.Compare(la[i].Labels, la[j].Labels)
+	if diff != 0 {
+		return diff < 0
+	}
+	return common.Compare(la[i].Annotations, la[j].Annotations) < 0
+}
+
+func (la labelsAndAnnotations) Equal(o labelsAndAnnotations) bool {
+	if len(la) != len(o) {
+		return false
+	}
+	for i := range la {
+		if !common.Equal(la[i].Labels, o[i].Labels) || !common.Equal(la[i].Annotations, o[i].Annotations) {
+			return false
+		}
+	}
+	return true
+}
+
+func (la labelsAndAnnotations) String() string {
+	if len(la) == 0 {
+		return "[]"
+	}
+	s := "[\n0:" + indentLines("\n"+la[0].String(), "  ")
+	for i, l := range la[1:] {
+		s += ",\n" + fmt.Sprintf("%d", i+1) + ":" + indentLines("\n"+l.String(), "  ")
+	}
+	s += "\n]"
+	return s
+}
+
+// nonEmpty 返回去掉空值后的注解, 网关规则未填写的 summary、description 不参与比较
+func nonEmpty(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		if v != "" {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func indentLines(lines, indent string) string {
+	sb := strings.Builder{}
+	n := strings.Split(lines, "\n")
+	for i, l := range n {
+		if i > 0 {
+			sb.WriteString(indent)
+		}
+		sb.WriteString(l)
+		if i != len(n)-1 {
+			sb.WriteRune('\n')
+		}
+	}
+	return sb.String()
+}
+
+func shortDuration(d model.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
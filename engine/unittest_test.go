@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alertengine/config"
+)
+
+const unitTestRules = `[{"id": 1, "prom_id": 1, "expr": "node_load1", "op": ">", "value": "4", "for": "2m",
+  "labels": {"severity": "critical"}, "summary": "{{ $labels.instance }} 负载过高"}]`
+
+const unitTestPass = `
+rule_files:
+  - rules.json
+evaluation_interval: 1m
+
+tests:
+  - interval: 1m
+    input_series:
+      - series: 'node_load1{instance="172.16.27.76:9100"}'
+        values: '1 1 5 5 5 5 5'
+    alert_rule_test:
+      - eval_time: 2m
+        alertname: "1"
+        exp_alerts: []
+      - eval_time: 4m
+        alertname: "1"
+        exp_alerts:
+          - exp_labels:
+              __name__: node_load1
+              instance: "172.16.27.76:9100"
+              severity: critical
+            exp_annotations:
+              rule_id: "1"
+              prom_id: "1"
+              summary: "172.16.27.76:9100 负载过高"
+`
+
+func runUnitTest(t *testing.T, content string) (bool, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "rules.json"), unitTestRules)
+	writeTestFile(t, filepath.Join(dir, "rules_test.yml"), content)
+
+	var out bytes.Buffer
+	ok := RunRuleUnitTests(&out, config.DefaultConfig(), filepath.Join(dir, "rules_test.yml"))
+	return ok, out.String()
+}
+
+func TestRunRuleUnitTests(t *testing.T) {
+	ok, out := runUnitTest(t, unitTestPass)
+	if !ok || !strings.Contains(out, "SUCCESS") {
+		t.Fatalf("test failed:\n%s", out)
+	}
+
+	// 规则持续 2m 后才 firing, 3m 时仍然是 pending
+	ok, out = runUnitTest(t, strings.Replace(unitTestPass, "eval_time: 4m", "eval_time: 3m", 1))
+	if ok || !strings.Contains(out, "FAILED") || !strings.Contains(out, "alertname: 1, time: 3m") {
+		t.Errorf("want the test to fail, got:\n%s", out)
+	}
+}
+
+func TestRunRuleUnitTestsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"missing rule file", "rule_files: [missing.json]\ntests: []\n", "no rule files match"},
+		{"unknown field", "rule_files: [rules.json]\nunknown: 1\n", "field unknown not found"},
+		{"invalid series", strings.Replace(unitTestPass, "node_load1{instance", "node_load1{{instance", 1), "parse error"},
+		{"missing alertname", strings.Replace(unitTestPass, `alertname: "1"`, `alertname: ""`, 1), "misses required attribute alertname"},
+	}
+
+	// 输入序列解析失败时内存存储的临时目录同样需要删除
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	for _, tc := range tests {
+		ok, out := runUnitTest(t, tc.content)
+		if ok || !strings.Contains(out, tc.err) {
+			t.Errorf("%s: got output\n%s\nwant error containing %q", tc.name, out, tc.err)
+		}
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "test_storage") {
+			t.Errorf("test storage %s was not removed", e.Name())
+		}
+	}
+}
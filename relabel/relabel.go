@@ -0,0 +1,253 @@
+package relabel
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"alertengine/common"
+
+	"github.com/prometheus/common/model"
+)
+
+// Action 重标记动作
+type Action string
+
+const (
+	// Replace 用正则匹配拼接后的源标签值, 并将替换结果写入目标标签
+	Replace Action = "replace"
+	// Keep 丢弃源标签值不匹配正则的告警
+	Keep Action = "keep"
+	// Drop 丢弃源标签值匹配正则的告警
+	Drop Action = "drop"
+	// HashMod 将源标签值哈希取模后写入目标标签
+	HashMod Action = "hashmod"
+	// LabelMap 将匹配正则的标签名替换后复制为新标签
+	LabelMap Action = "labelmap"
+	// LabelDrop 删除名称匹配正则的标签
+	LabelDrop Action = "labeldrop"
+	// LabelKeep 删除名称不匹配正则的标签
+	LabelKeep Action = "labelkeep"
+)
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (a *Action) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	switch act := Action(strings.ToLower(s)); act {
+	case Replace, Keep, Drop, HashMod, LabelMap, LabelDrop, LabelKeep:
+		*a = act
+		return nil
+	}
+	return fmt.Errorf("unknown relabel action %q", s)
+}
+
+// DefaultRelabelConfig 重标记配置的默认值
+var DefaultRelabelConfig = Config{
+	Action:      Replace,
+	Separator:   ";",
+	Regex:       MustNewRegexp("(.*)"),
+	Replacement: "$1",
+}
+
+// Config 重标记配置, 与 Prometheus 的 relabel_config 保持一致
+type Config struct {
+	// 源标签, 取值以 separator 拼接后参与正则匹配
+	SourceLabels []string `yaml:"source_labels,flow,omitempty" json:"source_labels,omitempty"`
+
+	// 拼接源标签值的分隔符
+	Separator string `yaml:"separator,omitempty" json:"separator,omitempty"`
+
+	// 匹配拼接值的正则表达式
+	Regex Regexp `yaml:"regex,omitempty" json:"regex,omitempty"`
+
+	// hashmod 动作的取模基数
+	Modulus uint64 `yaml:"modulus,omitempty" json:"modulus,omitempty"`
+
+	// replace/hashmod 动作写入的目标标签
+	TargetLabel string `yaml:"target_label,omitempty" json:"target_label,omitempty"`
+
+	// 替换内容, 支持正则分组引用
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+
+	// 重标记动作
+	Action Action `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = DefaultRelabelConfig
+	type plain Config
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// Validate 校验重标记配置
+func (c *Config) Validate() error {
+	if c.Action == "" {
+		return fmt.Errorf("relabel action cannot be empty")
+	}
+	if c.Regex.Regexp == nil {
+		c.Regex = MustNewRegexp("")
+	}
+	if c.Modulus == 0 && c.Action == HashMod {
+		return fmt.Errorf("relabel configuration for hashmod requires non-zero modulus")
+	}
+	if (c.Action == Replace || c.Action == HashMod) && c.TargetLabel == "" {
+		return fmt.Errorf("relabel configuration for %s action requires 'target_label' value", c.Action)
+	}
+	if c.Action == Replace && !strings.Contains(c.TargetLabel, "$") && !model.LabelName(c.TargetLabel).IsValid() {
+		return fmt.Errorf("%q is invalid 'target_label' for %s action", c.TargetLabel, c.Action)
+	}
+	if c.Action == HashMod && !model.LabelName(c.TargetLabel).IsValid() {
+		return fmt.Errorf("%q is invalid 'target_label' for %s action", c.TargetLabel, c.Action)
+	}
+	if c.Action == LabelMap && c.Replacement == "" {
+		return fmt.Errorf("relabel configuration for %s action requires 'replacement' value", c.Action)
+	}
+	if c.Action == LabelDrop || c.Action == LabelKeep {
+		if c.SourceLabels != nil ||
+			c.TargetLabel != DefaultRelabelConfig.TargetLabel ||
+			c.Modulus != DefaultRelabelConfig.Modulus ||
+			c.Separator != DefaultRelabelConfig.Separator ||
+			c.Replacement != DefaultRelabelConfig.Replacement {
+			return fmt.Errorf("%s action requires only 'regex', and no other fields", c.Action)
+		}
+	}
+	return nil
+}
+
+// Regexp 全匹配的正则表达式, 支持 YAML/JSON 序列化
+type Regexp struct {
+	*regexp.Regexp
+	original string
+}
+
+// NewRegexp 创建全匹配的正则表达式
+func NewRegexp(s string) (Regexp, error) {
+	re, err := regexp.Compile("^(?:" + s + ")$")
+	return Regexp{Regexp: re, original: s}, err
+}
+
+// MustNewRegexp 创建正则表达式, 出错时 panic
+func MustNewRegexp(s string) Regexp {
+	re, err := NewRegexp(s)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (re *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	r, err := NewRegexp(s)
+	if err != nil {
+		return err
+	}
+	*re = r
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (re Regexp) MarshalYAML() (interface{}, error) {
+	if re.Regexp != nil {
+		return re.original, nil
+	}
+	return nil, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (re Regexp) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", re.original)), nil
+}
+
+// String 返回原始正则表达式
+func (re Regexp) String() string {
+	return re.original
+}
+
+// Process 按顺序对标签应用重标记配置, 告警被丢弃时返回 false
+func Process(lset common.Labels, cfgs ...*Config) (common.Labels, bool) {
+	lb := common.NewBuilder(lset)
+	for _, cfg := range cfgs {
+		if !relabel(cfg, lb) {
+			return nil, false
+		}
+	}
+	return lb.Labels(), true
+}
+
+func relabel(cfg *Config, lb *common.Builder) bool {
+	lset := lb.Labels()
+
+	values := make([]string, 0, len(cfg.SourceLabels))
+	for _, ln := range cfg.SourceLabels {
+		values = append(values, lset.Get(ln))
+	}
+	val := strings.Join(values, cfg.Separator)
+
+	switch cfg.Action {
+	case Drop:
+		if cfg.Regex.MatchString(val) {
+			return false
+		}
+	case Keep:
+		if !cfg.Regex.MatchString(val) {
+			return false
+		}
+	case Replace:
+		indexes := cfg.Regex.FindStringSubmatchIndex(val)
+		// 正则不匹配时不做任何修改
+		if indexes == nil {
+			break
+		}
+		target := model.LabelName(cfg.Regex.ExpandString([]byte{}, cfg.TargetLabel, val, indexes))
+		if !target.IsValid() {
+			break
+		}
+		res := cfg.Regex.ExpandString([]byte{}, cfg.Replacement, val, indexes)
+		if len(res) == 0 {
+			lb.Del(string(target))
+			break
+		}
+		lb.Set(string(target), string(res))
+	case HashMod:
+		hash := md5.Sum([]byte(val))
+		// 取哈希值的低 8 字节, 与 Prometheus 的实现保持一致
+		mod := binary.BigEndian.Uint64(hash[8:]) % cfg.Modulus
+		lb.Set(cfg.TargetLabel, fmt.Sprintf("%d", mod))
+	case LabelMap:
+		for _, l := range lset {
+			if cfg.Regex.MatchString(l.Name) {
+				res := cfg.Regex.ReplaceAllString(l.Name, cfg.Replacement)
+				lb.Set(res, l.Value)
+			}
+		}
+	case LabelDrop:
+		for _, l := range lset {
+			if cfg.Regex.MatchString(l.Name) {
+				lb.Del(l.Name)
+			}
+		}
+	case LabelKeep:
+		for _, l := range lset {
+			if !cfg.Regex.MatchString(l.Name) {
+				lb.Del(l.Name)
+			}
+		}
+	default:
+		panic(fmt.Errorf("relabel: unknown relabel action type %q", cfg.Action))
+	}
+
+	return true
+}
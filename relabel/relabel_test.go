@@ -0,0 +1,257 @@
+package relabel
+
+import (
+	"strings"
+	"testing"
+
+	"alertengine/common"
+
+	"gopkg.in/yaml.v2"
+)
+
+func mustParseConfig(t *testing.T, s string) *Config {
+	t.Helper()
+	var cfg Config
+	if err := yaml.UnmarshalStrict([]byte(s), &cfg); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return &cfg
+}
+
+func TestProcess(t *testing.T) {
+	input := map[string]string{
+		"alertname": "HighCPU",
+		"instance":  "node-1:9100",
+		"job":       "node",
+		"meta_team": "infra",
+		"meta_env":  "prod",
+		"tmp_id":    "42",
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		output map[string]string // nil 表示告警被丢弃
+	}{
+		{
+			name:   "replace with defaults",
+			config: "source_labels: [job]\ntarget_label: service",
+			output: map[string]string{"service": "node"},
+		},
+		{
+			name:   "replace with groups and separator",
+			config: "source_labels: [job, instance]\nregex: '(.*);(.*):.*'\ntarget_label: host\nreplacement: ${2}-${1}",
+			output: map[string]string{"host": "node-1-node"},
+		},
+		{
+			name:   "replace with custom separator",
+			config: "source_labels: [job, meta_env]\nseparator: /\ntarget_label: key",
+			output: map[string]string{"key": "node/prod"},
+		},
+		{
+			name:   "replace no match",
+			config: "source_labels: [instance]\nregex: db-.*\ntarget_label: role\nreplacement: database",
+			output: map[string]string{},
+		},
+		{
+			name:   "replace regex is anchored",
+			config: "source_labels: [instance]\nregex: node\ntarget_label: role\nreplacement: node",
+			output: map[string]string{},
+		},
+		{
+			name:   "replace empty result deletes target",
+			config: "source_labels: [missing]\ntarget_label: job",
+			output: map[string]string{"job": ""},
+		},
+		{
+			name:   "replace dynamic target",
+			config: "source_labels: [meta_team]\nregex: (.+)\ntarget_label: team_${1}\nreplacement: 'true'",
+			output: map[string]string{"team_infra": "true"},
+		},
+		{
+			name:   "replace invalid target is ignored",
+			config: "source_labels: [instance]\nregex: (.+)\ntarget_label: ${1}\nreplacement: x",
+			output: map[string]string{},
+		},
+		{
+			name:   "keep match",
+			config: "source_labels: [job]\nregex: node|mysql\naction: keep",
+			output: map[string]string{},
+		},
+		{
+			name:   "keep no match",
+			config: "source_labels: [job]\nregex: mysql\naction: keep",
+		},
+		{
+			name:   "keep regex is anchored",
+			config: "source_labels: [job]\nregex: no\naction: keep",
+		},
+		{
+			name:   "keep empty source label",
+			config: "source_labels: [missing]\nregex: ''\naction: keep",
+			output: map[string]string{},
+		},
+		{
+			name:   "drop match",
+			config: "source_labels: [job, meta_env]\nregex: node;prod\naction: drop",
+		},
+		{
+			name:   "drop no match",
+			config: "source_labels: [job]\nregex: mysql\naction: drop",
+			output: map[string]string{},
+		},
+		{
+			name:   "drop regex is anchored",
+			config: "source_labels: [instance]\nregex: node-1\naction: drop",
+			output: map[string]string{},
+		},
+		{
+			name:   "drop empty source label",
+			config: "source_labels: [missing]\nregex: ''\naction: drop",
+		},
+		{
+			name:   "drop without source labels",
+			config: "action: drop",
+		},
+		{
+			name:   "hashmod",
+			config: "source_labels: [instance]\ntarget_label: shard\nmodulus: 1000\naction: hashmod",
+			output: map[string]string{"shard": "805"},
+		},
+		{
+			name:   "hashmod empty source label",
+			config: "source_labels: [missing]\ntarget_label: shard\nmodulus: 10\naction: hashmod",
+			output: map[string]string{"shard": "8"},
+		},
+		{
+			name:   "labelmap",
+			config: "regex: meta_(.+)\naction: labelmap",
+			output: map[string]string{"team": "infra", "env": "prod"},
+		},
+		{
+			name:   "labelmap with replacement",
+			config: "regex: meta_(.+)\nreplacement: label_${1}\naction: labelmap",
+			output: map[string]string{"label_team": "infra", "label_env": "prod"},
+		},
+		{
+			name:   "labeldrop",
+			config: "regex: tmp_.*|meta_env\naction: labeldrop",
+			output: map[string]string{"tmp_id": "", "meta_env": ""},
+		},
+		{
+			name:   "labeldrop regex is anchored",
+			config: "regex: tmp\naction: labeldrop",
+			output: map[string]string{},
+		},
+		{
+			name:   "labelkeep",
+			config: "regex: alertname|job|meta_.*\naction: labelkeep",
+			output: map[string]string{"instance": "", "tmp_id": ""},
+		},
+	}
+
+	for _, tc := range tests {
+		cfg := mustParseConfig(t, tc.config)
+		got, keep := Process(common.FromMap(input), cfg)
+
+		if tc.output == nil {
+			if keep {
+				t.Errorf("%s: got %s, want the alert to be dropped", tc.name, got)
+			}
+			continue
+		}
+		if !keep {
+			t.Errorf("%s: alert dropped", tc.name)
+			continue
+		}
+
+		// output 中为空值的标签表示被删除, 其余标签与输入相同
+		want := map[string]string{}
+		for k, v := range input {
+			want[k] = v
+		}
+		for k, v := range tc.output {
+			if v == "" {
+				delete(want, k)
+			} else {
+				want[k] = v
+			}
+		}
+		if !common.Equal(got, common.FromMap(want)) {
+			t.Errorf("%s: got %s, want %s", tc.name, got, common.FromMap(want))
+		}
+	}
+}
+
+func TestProcessChain(t *testing.T) {
+	lset := common.FromMap(map[string]string{"alertname": "HighCPU", "instance": "node-1:9100"})
+	cfgs := []*Config{
+		mustParseConfig(t, "source_labels: [instance]\nregex: '([^:]+):.*'\ntarget_label: host"),
+		mustParseConfig(t, "regex: instance\naction: labeldrop"),
+		mustParseConfig(t, "source_labels: [host]\nregex: node-.*\naction: keep"),
+	}
+
+	got, keep := Process(lset, cfgs...)
+	want := common.FromMap(map[string]string{"alertname": "HighCPU", "host": "node-1"})
+	if !keep || !common.Equal(got, want) {
+		t.Errorf("got %s, %v, want %s", got, keep, want)
+	}
+
+	// 后面的配置看到前面配置的结果, 丢弃后不再处理
+	cfgs = append(cfgs, mustParseConfig(t, "source_labels: [host]\nregex: node-1\naction: drop"))
+	if got, keep := Process(lset, cfgs...); keep {
+		t.Errorf("got %s, want the alert to be dropped", got)
+	}
+	if got, keep := Process(lset); !keep || !common.Equal(got, lset) {
+		t.Errorf("got %s, %v without configs, want input unchanged", got, keep)
+	}
+}
+
+func TestHashModCompatibility(t *testing.T) {
+	// 与 Prometheus relabel 测试中的结果一致
+	cfg := mustParseConfig(t, "source_labels: [c]\ntarget_label: d\nmodulus: 1000\naction: hashmod")
+	got, _ := Process(common.FromMap(map[string]string{"c": "baz"}), cfg)
+	if d := got.Get("d"); d != "976" {
+		t.Errorf("d = %q, want 976", d)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{"action: unknown", `unknown relabel action "unknown"`},
+		{"regex: '(unclosed'", "missing closing )"},
+		{"source_labels: [job]\naction: replace", "requires 'target_label' value"},
+		{"source_labels: [job]\ntarget_label: 1invalid", `"1invalid" is invalid 'target_label'`},
+		{"source_labels: [job]\ntarget_label: shard\naction: hashmod", "requires non-zero modulus"},
+		{"source_labels: [job]\ntarget_label: ${1}\nmodulus: 2\naction: hashmod", `"${1}" is invalid 'target_label'`},
+		{"regex: meta_(.+)\nreplacement: ''\naction: labelmap", "requires 'replacement' value"},
+		{"source_labels: [job]\nregex: tmp_.*\naction: labeldrop", "labeldrop action requires only 'regex'"},
+		{"regex: tmp_.*\ntarget_label: job\naction: labelkeep", "labelkeep action requires only 'regex'"},
+	}
+
+	for _, tc := range tests {
+		var cfg Config
+		err := yaml.UnmarshalStrict([]byte(tc.config), &cfg)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%q: got error %v, want it to contain %q", tc.config, err, tc.err)
+		}
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := mustParseConfig(t, "target_label: service\naction: REPLACE")
+	if cfg.Action != Replace || cfg.Separator != ";" || cfg.Replacement != "$1" || cfg.Regex.String() != "(.*)" {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := mustParseConfig(t, string(out)); again.Regex.String() != cfg.Regex.String() {
+		t.Errorf("regex after round trip = %q, want %q", again.Regex, cfg.Regex)
+	}
+}
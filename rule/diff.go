@@ -0,0 +1,201 @@
+package rule
+
+import (
+	"alertengine/common"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffOp 差异类型
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffLine 行级差异
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffEdits Myers 算法的最大编辑距离, 超过后退化为整体替换以限制内存占用
+const maxDiffEdits = 2000
+
+// DiffText 按行比较两段文本
+func DiffText(a, b string) []DiffLine {
+	return DiffLines(splitLines(a), splitLines(b))
+}
+
+// DiffLines 使用 Myers 算法计算两组行之间的最短编辑序列
+func DiffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return []DiffLine{}
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(a, b)
+		}
+
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+
+	return replaceAll(a, b)
+}
+
+func backtrack(trace [][]int, a, b []string, offset int) []DiffLine {
+	var result []DiffLine
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			result = append(result, DiffLine{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			result = append(result, DiffLine{Op: DiffInsert, Text: b[y-1]})
+		} else {
+			result = append(result, DiffLine{Op: DiffDelete, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		result = append(result, DiffLine{Op: DiffEqual, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func replaceAll(a, b []string) []DiffLine {
+	result := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a {
+		result = append(result, DiffLine{Op: DiffDelete, Text: l})
+	}
+	for _, l := range b {
+		result = append(result, DiffLine{Op: DiffInsert, Text: l})
+	}
+	return result
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// RuleChange 单条规则的变化, 新增时 Old 为空, 删除时 New 为空
+type RuleChange struct {
+	ID     int64    `json:"id"`
+	Type   string   `json:"type"`
+	Old    *Rule    `json:"old,omitempty"`
+	New    *Rule    `json:"new,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// DiffContent 按规则比较两个版本的规则文件, 内容变化的规则列出变化的字段, 结果按规则ID排序
+func DiffContent(old, new []byte) ([]RuleChange, error) {
+	oldRules, err := ParseContent(old)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old version: %w", err)
+	}
+	newRules, err := ParseContent(new)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new version: %w", err)
+	}
+
+	oldByID := make(map[int64]*Rule, len(oldRules))
+	for i := range oldRules {
+		oldByID[oldRules[i].ID] = &oldRules[i]
+	}
+	newByID := make(map[int64]*Rule, len(newRules))
+	for i := range newRules {
+		newByID[newRules[i].ID] = &newRules[i]
+	}
+
+	d := DiffRules(oldRules, newRules)
+	changes := make([]RuleChange, 0, len(d.Added)+len(d.Removed)+len(d.Modified))
+	for _, id := range d.Added {
+		changes = append(changes, RuleChange{ID: id, Type: "added", New: newByID[id]})
+	}
+	for _, id := range d.Removed {
+		changes = append(changes, RuleChange{ID: id, Type: "removed", Old: oldByID[id]})
+	}
+	for _, id := range d.Modified {
+		o, n := oldByID[id], newByID[id]
+		changes = append(changes, RuleChange{ID: id, Type: "modified", Old: o, New: n, Fields: changedFields(o, n)})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes, nil
+}
+
+// changedFields 返回两条规则中取值不同的字段
+func changedFields(a, b *Rule) []string {
+	var fields []string
+	if a.Expr != b.Expr {
+		fields = append(fields, "expr")
+	}
+	if a.For != b.For {
+		fields = append(fields, "for")
+	}
+	if !common.Equal(a.Labels, b.Labels) {
+		fields = append(fields, "labels")
+	}
+	if a.Summary != b.Summary {
+		fields = append(fields, "summary")
+	}
+	if a.Description != b.Description {
+		fields = append(fields, "description")
+	}
+	return fields
+}
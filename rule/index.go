@@ -0,0 +1,183 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// indexFile 版本索引文件名, 位于每个数据源的历史目录下
+const indexFile = "index.json"
+
+var (
+	ErrVersionNotFound = errors.New("version not found")
+	ErrHistoryDisabled = errors.New("history is disabled")
+)
+
+// versionIndex 数据源的版本索引, 按版本号升序记录所有历史版本
+type versionIndex struct {
+	Versions []RuleVersion `json:"versions"`
+	Pin      *Pin          `json:"pin,omitempty"`
+}
+
+// latest 返回最新的版本, 没有版本时返回 nil
+func (idx *versionIndex) latest() *RuleVersion {
+	if len(idx.Versions) == 0 {
+		return nil
+	}
+	return &idx.Versions[len(idx.Versions)-1]
+}
+
+// nextVersion 返回下一个版本号, 版本号只增不减, 旧版本被清理后也不会复用
+func (idx *versionIndex) nextVersion() int64 {
+	if v := idx.latest(); v != nil {
+		return v.Version + 1
+	}
+	return 1
+}
+
+// loadIndex 加载数据源的版本索引, 调用方需持有 s.mu.
+// 索引不存在时根据历史目录中已有的版本文件重建, 索引损坏时移动到隔离目录后重建.
+func (s *Storage) loadIndex(promID int64) (*versionIndex, error) {
+	if idx, ok := s.indexes[promID]; ok {
+		return idx, nil
+	}
+
+	historyDir := s.getPromHistoryDir(promID)
+	path := filepath.Join(historyDir, indexFile)
+
+	idx := &versionIndex{}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, idx); err != nil {
+			s.logger.Warn("corrupt version index, rebuilding",
+				zap.Int64("prom_id", promID),
+				zap.String("path", path),
+				zap.Error(err),
+			)
+			if err := s.quarantine(path); err != nil {
+				return nil, fmt.Errorf("failed to quarantine version index: %w", err)
+			}
+			if idx, err = s.rebuildIndex(promID); err != nil {
+				return nil, err
+			}
+			break
+		}
+		// 索引中的文件可能已被隔离或手动删除
+		versions := idx.Versions[:0]
+		for _, v := range idx.Versions {
+			v.FilePath = filepath.Join(historyDir, v.Name)
+			if _, err := os.Stat(v.FilePath); err != nil {
+				s.logger.Warn("rule version file missing, removed from index",
+					zap.Int64("prom_id", promID),
+					zap.Int64("version", v.Version),
+					zap.String("path", v.FilePath),
+				)
+				continue
+			}
+			versions = append(versions, v)
+		}
+		idx.Versions = versions
+	case os.IsNotExist(err):
+		if idx, err = s.rebuildIndex(promID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("failed to read version index: %w", err)
+	}
+
+	s.indexes[promID] = idx
+	return idx, nil
+}
+
+// rebuildIndex 按文件名顺序为历史目录中已有的版本文件分配版本号, 并持久化索引
+func (s *Storage) rebuildIndex(promID int64) (*versionIndex, error) {
+	historyDir := s.getPromHistoryDir(promID)
+	files, err := os.ReadDir(historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &versionIndex{}, nil
+		}
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	// 版本文件名包含时间戳, 按文件名排序即为时间顺序
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	idx := &versionIndex{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yml") || strings.HasPrefix(file.Name(), tempFilePrefix) {
+			continue
+		}
+		path := filepath.Join(historyDir, file.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule version: %w", err)
+		}
+
+		createdAt, err := time.ParseInLocation("20060102_150405", strings.TrimSuffix(strings.TrimPrefix(file.Name(), "rule_"), ".yml"), time.Local)
+		if err != nil {
+			if info, err := file.Info(); err == nil {
+				createdAt = info.ModTime()
+			}
+		}
+
+		idx.Versions = append(idx.Versions, RuleVersion{
+			Version:   idx.nextVersion(),
+			PromID:    promID,
+			Name:      file.Name(),
+			RuleCount: countRules(content),
+			CreatedAt: createdAt,
+			FilePath:  path,
+			Hash:      s.calculateHash(content),
+			Source:    SourceGateway,
+		})
+	}
+
+	if len(idx.Versions) == 0 {
+		return idx, nil
+	}
+	if err := s.saveIndex(promID, idx); err != nil {
+		return nil, err
+	}
+	s.logger.Info("rebuilt rule version index",
+		zap.Int64("prom_id", promID),
+		zap.Int("versions", len(idx.Versions)),
+	)
+	return idx, nil
+}
+
+// saveIndex 原子地写入版本索引
+func (s *Storage) saveIndex(promID int64, idx *versionIndex) error {
+	content, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal version index: %w", err)
+	}
+	return writeFile(filepath.Join(s.getPromHistoryDir(promID), indexFile), content)
+}
+
+// countRules 统计规则文件中的规则数量, 无法解析时返回 0
+func countRules(content []byte) int {
+	var file struct {
+		Groups []struct {
+			Rules []yaml.MapSlice `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return 0
+	}
+	n := 0
+	for _, g := range file.Groups {
+		n += len(g.Rules)
+	}
+	return n
+}
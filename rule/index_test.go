@@ -0,0 +1,348 @@
+package rule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestStorage(t *testing.T, dir string, retentionDays int) *Storage {
+	t.Helper()
+	s, err := NewStorage(dir, retentionDays, true, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testContent(t *testing.T, exprs ...string) []byte {
+	t.Helper()
+	var rules Rules
+	for i, expr := range exprs {
+		rules = append(rules, testRule(int64(i+1), expr))
+	}
+	content, err := rules.Content(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func versionNumbers(versions []RuleVersion) []int64 {
+	var res []int64
+	for _, v := range versions {
+		res = append(res, v.Version)
+	}
+	return res
+}
+
+func TestSaveRuleVersions(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+	v1Content := testContent(t, "cpu_usage")
+	v2Content := testContent(t, "cpu_usage", "mem_usage")
+
+	v, err := s.SaveRule(1, v1Content, SourceGateway, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 1 || v.Source != SourceGateway || v.RuleCount != 1 {
+		t.Errorf("unexpected first version: %+v", v)
+	}
+
+	// 内容未变化时不生成新版本
+	v, err = s.SaveRule(1, v1Content, SourceGateway, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 1 {
+		t.Errorf("got version %d for unchanged content, want 1", v.Version)
+	}
+
+	v, err = s.SaveRule(1, v2Content, SourceAPI, "revert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 2 || v.Source != SourceAPI || v.Reason != "revert" || v.RuleCount != 2 {
+		t.Errorf("unexpected second version: %+v", v)
+	}
+
+	versions, err := s.ListVersions(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionNumbers(versions); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Errorf("versions = %v, want [2 1]", got)
+	}
+	if versions, _ := s.ListVersions(1, 1); len(versions) != 1 || versions[0].Version != 2 {
+		t.Errorf("limited versions = %v, want [2]", versionNumbers(versions))
+	}
+
+	content, err := s.ReadVersion(1, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(v1Content) {
+		t.Errorf("version 1 content = %q, want %q", content, v1Content)
+	}
+	if content, _ := s.ReadVersion(1, ""); string(content) != string(v2Content) {
+		t.Errorf("current content = %q, want %q", content, v2Content)
+	}
+	if _, err := s.Version(1, 3); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("got error %v, want ErrVersionNotFound", err)
+	}
+
+	// 重启后从索引加载, 版本号继续递增
+	s = newTestStorage(t, dir, 7)
+	v, err = s.SaveRule(1, v1Content, SourceGateway, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 3 {
+		t.Errorf("got version %d after restart, want 3", v.Version)
+	}
+	if v, err := s.Version(1, 2); err != nil || v.Source != SourceAPI || v.Reason != "revert" {
+		t.Errorf("version 2 after restart = %+v, %v", v, err)
+	}
+}
+
+func TestSaveRuleUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+	content := testContent(t, "cpu_usage")
+	if _, err := s.SaveRule(1, content, SourceGateway, ""); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(s.getPromHistoryDir(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// current.yml 被修改或删除后, 保存相同内容时仍然写入 current.yml, 但不生成新的历史版本
+	for _, change := range []func(path string) error{
+		func(path string) error { return os.WriteFile(path, []byte("proms: ["), 0644) },
+		os.Remove,
+	} {
+		if err := change(s.GetCurrentRule(1)); err != nil {
+			t.Fatal(err)
+		}
+		v, err := s.SaveRule(1, content, SourceAPI, "retry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Version != 1 || v.Source != SourceGateway {
+			t.Errorf("got version %+v, want the first version", v)
+		}
+		if got, err := os.ReadFile(s.GetCurrentRule(1)); err != nil || string(got) != string(content) {
+			t.Errorf("current content = %q, %v, want %q", got, err, content)
+		}
+	}
+
+	after, err := os.ReadDir(s.getPromHistoryDir(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(files) {
+		t.Errorf("got %d files in the history dir, want %d", len(after), len(files))
+	}
+	if versions, _ := s.ListVersions(1, 0); len(versions) != 1 {
+		t.Errorf("versions = %v, want [1]", versionNumbers(versions))
+	}
+}
+
+func TestPinLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+
+	if pin, err := s.Pinned(1); err != nil || pin != nil {
+		t.Fatalf("Pinned = %+v, %v, want nil", pin, err)
+	}
+	for _, expr := range []string{"cpu_usage", "mem_usage"} {
+		if _, err := s.SaveRule(1, testContent(t, expr), SourceGateway, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Pin(1, 1, "gateway-hash", "bad deploy"); err != nil {
+		t.Fatal(err)
+	}
+	pin, err := s.Pinned(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin == nil || pin.Version != 1 || pin.GatewayHash != "gateway-hash" || pin.Reason != "bad deploy" || pin.PinnedAt.IsZero() {
+		t.Fatalf("unexpected pin: %+v", pin)
+	}
+
+	// 固定的版本在重启后保留
+	s = newTestStorage(t, dir, 7)
+	if pin, err := s.Pinned(1); err != nil || pin == nil || pin.Version != 1 {
+		t.Fatalf("Pinned after restart = %+v, %v", pin, err)
+	}
+
+	if err := s.Unpin(1); err != nil {
+		t.Fatal(err)
+	}
+	if pin, err := s.Pinned(1); err != nil || pin != nil {
+		t.Errorf("Pinned after Unpin = %+v, %v, want nil", pin, err)
+	}
+	s = newTestStorage(t, dir, 7)
+	if pin, err := s.Pinned(1); err != nil || pin != nil {
+		t.Errorf("Pinned after Unpin and restart = %+v, %v, want nil", pin, err)
+	}
+	if err := s.Unpin(1); err != nil {
+		t.Errorf("Unpin without pin: %v", err)
+	}
+}
+
+func TestCleanupOldVersions(t *testing.T) {
+	dir := t.TempDir()
+	// 保留 0 天, 除最新版本和固定的版本外全部删除
+	s := newTestStorage(t, dir, 0)
+	for _, expr := range []string{"cpu_usage", "mem_usage", "disk_usage", "load"} {
+		if _, err := s.SaveRule(1, testContent(t, expr), SourceGateway, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Pin(1, 2, "gateway-hash", ""); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.Version(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CleanupOldVersions(); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := s.ListVersions(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionNumbers(versions); len(got) != 2 || got[0] != 4 || got[1] != 2 {
+		t.Errorf("versions after cleanup = %v, want [4 2]", got)
+	}
+	if _, err := os.Stat(removed.FilePath); !os.IsNotExist(err) {
+		t.Errorf("version file %s not removed: %v", removed.FilePath, err)
+	}
+
+	// 清理后的索引写入磁盘, 版本号不复用
+	s = newTestStorage(t, dir, 0)
+	if versions, _ := s.ListVersions(1, 0); len(versions) != 2 {
+		t.Errorf("got %d versions after restart, want 2", len(versions))
+	}
+	v, err := s.SaveRule(1, testContent(t, "up"), SourceGateway, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 5 {
+		t.Errorf("got version %d after cleanup, want 5", v.Version)
+	}
+}
+
+func TestRebuildIndex(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+	for _, expr := range []string{"cpu_usage", "mem_usage", "disk_usage"} {
+		if _, err := s.SaveRule(1, testContent(t, expr), SourceAPI, "manual"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexPath := filepath.Join(s.getPromHistoryDir(1), indexFile)
+
+	check := func(name string) {
+		t.Helper()
+		s := newTestStorage(t, dir, 7)
+		versions, err := s.ListVersions(1, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := versionNumbers(versions); len(got) != 3 || got[0] != 3 || got[2] != 1 {
+			t.Fatalf("%s: versions = %v, want [3 2 1]", name, got)
+		}
+		// 重建的索引无法还原来源, 统一记录为网关同步
+		for _, v := range versions {
+			if v.Source != SourceGateway || v.RuleCount != 1 {
+				t.Errorf("%s: unexpected rebuilt version: %+v", name, v)
+			}
+		}
+		content, err := s.ReadVersion(1, "3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(testContent(t, "disk_usage")) {
+			t.Errorf("%s: version 3 content = %q", name, content)
+		}
+		if _, err := os.Stat(indexPath); err != nil {
+			t.Errorf("%s: rebuilt index not saved: %v", name, err)
+		}
+	}
+
+	// 索引不存在时根据历史文件重建
+	if err := os.Remove(indexPath); err != nil {
+		t.Fatal(err)
+	}
+	check("missing index")
+
+	// 索引损坏时隔离后重建
+	if err := os.WriteFile(indexPath, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check("corrupt index")
+	quarantined, err := filepath.Glob(filepath.Join(dir, quarantineDir, "prom_1", "history", indexFile+".*"))
+	if err != nil || len(quarantined) != 1 {
+		t.Errorf("quarantined index files = %v, %v, want 1", quarantined, err)
+	}
+}
+
+func TestIndexSkipsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+	for _, expr := range []string{"cpu_usage", "mem_usage"} {
+		if _, err := s.SaveRule(1, testContent(t, expr), SourceGateway, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v, err := s.Version(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(v.FilePath); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestStorage(t, dir, 7)
+	versions, err := s.ListVersions(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionNumbers(versions); len(got) != 1 || got[0] != 2 {
+		t.Errorf("versions = %v, want [2]", got)
+	}
+	if _, err := s.ReadVersion(1, "1"); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("got error %v, want ErrVersionNotFound", err)
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	s, err := NewStorage(t.TempDir(), 7, false, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := testContent(t, "cpu_usage")
+	if v, err := s.SaveRule(1, content, SourceGateway, ""); err != nil || v != nil {
+		t.Errorf("SaveRule = %+v, %v, want nil", v, err)
+	}
+	if got, err := s.ReadVersion(1, ""); err != nil || string(got) != string(content) {
+		t.Errorf("current content = %q, %v", got, err)
+	}
+	if _, err := s.ListVersions(1, 0); !errors.Is(err, ErrHistoryDisabled) {
+		t.Errorf("got error %v, want ErrHistoryDisabled", err)
+	}
+	if err := s.Pin(1, 1, "", ""); !errors.Is(err, ErrHistoryDisabled) {
+		t.Errorf("got error %v, want ErrHistoryDisabled", err)
+	}
+}
@@ -0,0 +1,149 @@
+package rule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// tempFilePrefix 原子写入时使用的临时文件前缀
+	tempFilePrefix = ".tmp-"
+
+	// quarantineDir 损坏的规则文件被移动到规则目录下的该子目录中
+	quarantineDir = "quarantine"
+)
+
+// checkFiles 启动时检查所有规则文件, 将无法解析的文件移动到隔离目录, 并清理崩溃遗留的临时文件
+func (s *Storage) checkFiles() error {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return fmt.Errorf("failed to read base directory: %w", err)
+	}
+
+	quarantined := 0
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			// 写入快照时遗留的临时文件
+			path := filepath.Join(s.baseDir, entry.Name())
+			if err := os.Remove(path); err != nil {
+				s.logger.Error("failed to remove temp file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			s.logger.Warn("removed leftover temp file", zap.String("path", path))
+			continue
+		}
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "prom_") {
+			continue
+		}
+		promDir := filepath.Join(s.baseDir, entry.Name())
+		quarantined += s.checkDir(promDir)
+		quarantined += s.checkDir(filepath.Join(promDir, "history"))
+	}
+
+	if quarantined > 0 {
+		s.logger.Warn("corrupt rule files quarantined",
+			zap.Int("count", quarantined),
+			zap.String("dir", filepath.Join(s.baseDir, quarantineDir)),
+		)
+	}
+	return nil
+}
+
+// checkDir 检查目录中的规则文件, 返回被隔离的文件数量
+func (s *Storage) checkDir(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Error("failed to read rule directory", zap.String("dir", dir), zap.Error(err))
+		}
+		return 0
+	}
+
+	quarantined := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasPrefix(entry.Name(), tempFilePrefix):
+			if err := os.RemoveAll(path); err != nil {
+				s.logger.Error("failed to remove temp file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			s.logger.Warn("removed leftover temp file", zap.String("path", path))
+
+		case entry.IsDir() && strings.HasSuffix(entry.Name(), ".ym"):
+			// 旧版本计算目录时只去掉了路径的最后一个字符, 会留下 current.ym 这样的空目录
+			if err := os.Remove(path); err == nil {
+				s.logger.Info("removed stray directory", zap.String("path", path))
+			}
+
+		case !entry.IsDir() && strings.HasSuffix(entry.Name(), ".yml"):
+			content, err := os.ReadFile(path)
+			if err == nil {
+				err = validateRuleFile(content)
+			}
+			if err == nil {
+				continue
+			}
+			if qerr := s.quarantine(path); qerr != nil {
+				s.logger.Error("failed to quarantine corrupt rule file",
+					zap.String("path", path),
+					zap.NamedError("reason", err),
+					zap.Error(qerr),
+				)
+				continue
+			}
+			s.logger.Warn("quarantined corrupt rule file", zap.String("path", path), zap.NamedError("reason", err))
+			quarantined++
+		}
+	}
+	return quarantined
+}
+
+// quarantine 将文件移动到隔离目录, 保留相对于规则目录的路径并追加时间戳
+func (s *Storage) quarantine(path string) error {
+	rel, err := filepath.Rel(s.baseDir, path)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(s.baseDir, quarantineDir, rel) + "." + time.Now().Format("20060102_150405")
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, dest)
+}
+
+// validateRuleFile 校验规则文件能否按生成的格式解析
+func validateRuleFile(content []byte) error {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return errors.New("empty file")
+	}
+
+	var file struct {
+		Groups []struct {
+			Name  string                   `yaml:"name"`
+			Rules []map[string]interface{} `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return err
+	}
+	if len(file.Groups) == 0 {
+		return errors.New("no rule groups")
+	}
+	for _, g := range file.Groups {
+		for i, r := range g.Rules {
+			if r["alert"] == nil || r["expr"] == nil {
+				return fmt.Errorf("group %q rule %d: alert and expr are required", g.Name, i)
+			}
+		}
+	}
+	return nil
+}
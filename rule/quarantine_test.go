@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir, 7)
+	for _, expr := range []string{"cpu_usage", "mem_usage"} {
+		if _, err := s.SaveRule(1, testContent(t, expr), SourceGateway, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.SaveRule(2, testContent(t, "disk_usage"), SourceGateway, ""); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := s.Version(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 崩溃时写了一半的 current.yml 和历史版本
+	write(s.GetCurrentRule(1), "groups:\n- name: ruleengine\n  rules:\n  - alert: \"1\"\n    expr: \"cpu_us")
+	write(v1.FilePath, "")
+	// 缺少 expr 的规则同样视为损坏
+	write(filepath.Join(dir, "prom_3", "current.yml"), "groups:\n- name: ruleengine\n  rules:\n  - alert: \"1\"\n")
+	write(filepath.Join(dir, tempFilePrefix+"snapshot"), "partial")
+	write(filepath.Join(dir, "prom_2", tempFilePrefix+"current.yml"), "partial")
+	if err := os.MkdirAll(filepath.Join(dir, "prom_2", "current.ym"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestStorage(t, dir, 7)
+
+	quarantined := map[string]bool{
+		filepath.Join("prom_1", "current.yml"):                         true,
+		filepath.Join("prom_1", "history", filepath.Base(v1.FilePath)): true,
+		filepath.Join("prom_3", "current.yml"):                         true,
+	}
+	for rel := range quarantined {
+		if _, err := os.Stat(filepath.Join(dir, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s: corrupt file not moved, got %v", rel, err)
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, quarantineDir, rel) + ".*")
+		if err != nil || len(matches) != 1 {
+			t.Errorf("%s: quarantined files = %v, %v, want 1", rel, matches, err)
+		}
+	}
+
+	// 完好的文件保留, 遗留的临时文件和目录被清理
+	if content, err := os.ReadFile(s.GetCurrentRule(2)); err != nil || string(content) != string(testContent(t, "disk_usage")) {
+		t.Errorf("valid current.yml = %q, %v", content, err)
+	}
+	for _, rel := range []string{tempFilePrefix + "snapshot", filepath.Join("prom_2", tempFilePrefix+"current.yml"), filepath.Join("prom_2", "current.ym")} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s: not removed, got %v", rel, err)
+		}
+	}
+
+	// 被隔离的历史版本从索引中移除, 之后的版本号继续递增
+	versions, err := s.ListVersions(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionNumbers(versions); len(got) != 1 || got[0] != 2 {
+		t.Errorf("versions = %v, want [2]", got)
+	}
+	v, err := s.SaveRule(1, testContent(t, "cpu_usage"), SourceGateway, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 3 {
+		t.Errorf("got version %d after quarantine, want 3", v.Version)
+	}
+
+	// 再次启动时不重复隔离
+	newTestStorage(t, dir, 7)
+	matches, _ := filepath.Glob(filepath.Join(dir, quarantineDir, "*", "*.*"))
+	if len(matches) != 2 {
+		t.Errorf("quarantined current files = %v, want 2", matches)
+	}
+}
+
+func TestValidateRuleFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"valid", "groups:\n- name: ruleengine\n  rules:\n  - alert: \"1\"\n    expr: up == 0\n", true},
+		{"empty group", "groups:\n- name: ruleengine\n  rules: []\n", true},
+		{"empty", " \n", false},
+		{"truncated", "groups:\n- name: ruleengine\n  rules:\n  - alert: \"1\"\n    expr: [up", false},
+		{"no groups", "groups: []\n", false},
+		{"unknown field", "groups: []\nrules: []\n", false},
+		{"missing alert", "groups:\n- name: ruleengine\n  rules:\n  - expr: up == 0\n", false},
+	}
+	for _, tc := range tests {
+		if err := validateRuleFile([]byte(tc.content)); (err == nil) != tc.valid {
+			t.Errorf("%s: got error %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
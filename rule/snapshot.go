@@ -0,0 +1,180 @@
+package rule
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"alertengine/config"
+
+	config_util "github.com/prometheus/common/config"
+	"go.uber.org/zap"
+)
+
+// snapshotFile 最近一次成功同步的规则和数据源快照, 位于规则目录下
+const snapshotFile = "snapshot.json"
+
+// Snapshot 从网关获取的规则和数据源快照, 网关不可用时用于启动
+type Snapshot struct {
+	CreatedAt time.Time   `json:"created_at"`
+	Proms     []PromRules `json:"proms"`
+}
+
+// SaveSnapshot 保存规则和数据源快照, 内容与上次保存的快照相同时跳过.
+// 快照中包含数据源认证的明文凭据, 文件权限为 0600.
+func (s *Storage) SaveSnapshot(promRules []PromRules) error {
+	proms := make([]snapshotPromRules, 0, len(promRules))
+	for _, pr := range promRules {
+		proms = append(proms, newSnapshotPromRules(pr))
+	}
+	sort.Slice(proms, func(i, j int) bool { return proms[i].Prom.ID < proms[j].Prom.ID })
+
+	data, err := json.Marshal(proms)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hash == s.snapshotHash {
+		return nil
+	}
+
+	content, err := json.Marshal(snapshotJSON{CreatedAt: time.Now(), Proms: proms})
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := writeFileMode(filepath.Join(s.baseDir, snapshotFile), content, 0600); err != nil {
+		return err
+	}
+	s.snapshotHash = hash
+
+	s.logger.Debug("rule snapshot saved", zap.Int("proms", len(proms)))
+	return nil
+}
+
+// LoadSnapshot 加载最近一次保存的快照, 快照不存在时返回 nil. 无法解析的快照会被移动到隔离目录
+func (s *Storage) LoadSnapshot() (*Snapshot, error) {
+	path := filepath.Join(s.baseDir, snapshotFile)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshotJSON
+	if err := json.Unmarshal(content, &snap); err != nil {
+		if qerr := s.quarantine(path); qerr != nil {
+			s.logger.Error("failed to quarantine corrupt snapshot", zap.String("path", path), zap.Error(qerr))
+		}
+		return nil, fmt.Errorf("corrupt snapshot %s: %w", path, err)
+	}
+
+	res := &Snapshot{CreatedAt: snap.CreatedAt, Proms: make([]PromRules, 0, len(snap.Proms))}
+	for _, pr := range snap.Proms {
+		res.Proms = append(res.Proms, pr.promRules())
+	}
+	return res, nil
+}
+
+// snapshotJSON 快照文件格式. 配置中的 Secret 序列化时会被隐藏, 数据源认证按明文单独序列化
+type snapshotJSON struct {
+	CreatedAt time.Time           `json:"created_at"`
+	Proms     []snapshotPromRules `json:"proms"`
+}
+
+type snapshotPromRules struct {
+	Prom  snapshotProm `json:"prom"`
+	Rules Rules        `json:"rules"`
+}
+
+type snapshotProm struct {
+	Prom
+	Auth *snapshotAuth `json:"auth,omitempty"`
+}
+
+// snapshotAuth 与 config.DatasourceAuth 对应, DatasourceAuth 增加字段时需要同步修改
+type snapshotAuth struct {
+	BearerToken     string            `json:"bearer_token,omitempty"`
+	BearerTokenFile string            `json:"bearer_token_file,omitempty"`
+	BasicAuth       *snapshotBasic    `json:"basic_auth,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	HeaderFiles     map[string]string `json:"header_files,omitempty"`
+	TLSConfig       config.TLSConfig  `json:"tls_config,omitempty"`
+}
+
+type snapshotBasic struct {
+	Username     string `json:"username"`
+	Password     string `json:"password,omitempty"`
+	PasswordFile string `json:"password_file,omitempty"`
+}
+
+func newSnapshotPromRules(pr PromRules) snapshotPromRules {
+	res := snapshotPromRules{Prom: snapshotProm{Prom: pr.Prom}, Rules: pr.Rules}
+	res.Prom.Prom.Auth = nil
+
+	a := pr.Prom.Auth
+	if a == nil {
+		return res
+	}
+	auth := &snapshotAuth{
+		BearerToken:     string(a.BearerToken),
+		BearerTokenFile: a.BearerTokenFile,
+		HeaderFiles:     a.HeaderFiles,
+		TLSConfig:       a.TLSConfig,
+	}
+	if a.BasicAuth != nil {
+		auth.BasicAuth = &snapshotBasic{
+			Username:     a.BasicAuth.Username,
+			Password:     string(a.BasicAuth.Password),
+			PasswordFile: a.BasicAuth.PasswordFile,
+		}
+	}
+	if a.Headers != nil {
+		auth.Headers = make(map[string]string, len(a.Headers))
+		for k, v := range a.Headers {
+			auth.Headers[k] = string(v)
+		}
+	}
+	res.Prom.Auth = auth
+	return res
+}
+
+func (pr snapshotPromRules) promRules() PromRules {
+	res := PromRules{Prom: pr.Prom.Prom, Rules: pr.Rules}
+
+	a := pr.Prom.Auth
+	if a == nil {
+		return res
+	}
+	auth := &config.DatasourceAuth{
+		BearerToken:     config_util.Secret(a.BearerToken),
+		BearerTokenFile: a.BearerTokenFile,
+		HeaderFiles:     a.HeaderFiles,
+		TLSConfig:       a.TLSConfig,
+	}
+	if a.BasicAuth != nil {
+		auth.BasicAuth = &config.BasicAuth{
+			Username:     a.BasicAuth.Username,
+			Password:     config_util.Secret(a.BasicAuth.Password),
+			PasswordFile: a.BasicAuth.PasswordFile,
+		}
+	}
+	if a.Headers != nil {
+		auth.Headers = make(map[string]config_util.Secret, len(a.Headers))
+		for k, v := range a.Headers {
+			auth.Headers[k] = config_util.Secret(v)
+		}
+	}
+	res.Prom.Auth = auth
+	return res
+}
@@ -4,10 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -19,6 +20,13 @@ type Storage struct {
 	retentionDays int
 	enableHistory bool
 	logger        *zap.Logger
+
+	// 各数据源的版本索引缓存
+	mu      sync.Mutex
+	indexes map[int64]*versionIndex
+
+	// 最近一次保存的快照内容哈希
+	snapshotHash string
 }
 
 func NewStorage(baseDir string, retentionDays int, enableHistory bool, logger *zap.Logger) (*Storage, error) {
@@ -26,93 +34,296 @@ func NewStorage(baseDir string, retentionDays int, enableHistory bool, logger *z
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
 
-	return &Storage{
+	s := &Storage{
 		baseDir:       baseDir,
 		retentionDays: retentionDays,
 		enableHistory: enableHistory,
 		logger:        logger,
-	}, nil
+		indexes:       make(map[int64]*versionIndex),
+	}
+	if err := s.checkFiles(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func (s *Storage) SaveRule(promID int64, content []byte) (string, error) {
+// SaveRule 保存规则文件. current.yml 始终更新为最新内容; 启用历史记录时仅在内容与最新版本不同时写入新版本并记录到版本索引.
+// 返回内容对应的最新版本, 未启用历史记录时返回 nil.
+func (s *Storage) SaveRule(promID int64, content []byte, source, reason string) (*RuleVersion, error) {
 	hash := s.calculateHash(content)
 
-	var filepath string
-	if s.enableHistory {
-		timestamp := time.Now().Format("20060102_150405")
-		filepath = s.getHistoryPath(promID, timestamp)
-	} else {
-		filepath = s.getCurrentPath(promID)
+	currentPath := s.getCurrentPath(promID)
+	if err := writeFile(currentPath, content); err != nil {
+		return nil, err
 	}
 
-	// 确保目录存在
-	dir := filepath[:len(filepath)-len(filepath[len(filepath)-1:])]
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+	if !s.enableHistory {
+		s.logger.Info("rule saved",
+			zap.Int64("prom_id", promID),
+			zap.String("path", currentPath),
+			zap.String("hash", hash),
+		)
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return nil, err
+	}
+	if latest := idx.latest(); latest != nil && latest.Hash == hash {
+		s.logger.Debug("rule content unchanged, skipping history version",
+			zap.Int64("prom_id", promID),
+			zap.Int64("version", latest.Version),
+			zap.String("hash", hash),
+		)
+		v := *latest
+		return &v, nil
+	}
+
+	now := time.Now()
+	v := RuleVersion{
+		Version:   idx.nextVersion(),
+		PromID:    promID,
+		RuleCount: countRules(content),
+		CreatedAt: now,
+		Hash:      hash,
+		Source:    source,
+		Reason:    reason,
 	}
+	// 同一秒内产生多个版本时文件名追加版本号, 避免覆盖
+	v.Name = fmt.Sprintf("rule_%s.yml", now.Format("20060102_150405"))
+	if _, err := os.Stat(filepath.Join(s.getPromHistoryDir(promID), v.Name)); err == nil {
+		v.Name = fmt.Sprintf("rule_%s_%d.yml", now.Format("20060102_150405"), v.Version)
+	}
+	v.FilePath = filepath.Join(s.getPromHistoryDir(promID), v.Name)
 
-	if err := os.WriteFile(filepath, content, 0644); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+	if err := writeFile(v.FilePath, content); err != nil {
+		return nil, err
+	}
+	idx.Versions = append(idx.Versions, v)
+	if err := s.saveIndex(promID, idx); err != nil {
+		return nil, err
 	}
 
 	s.logger.Info("rule saved",
 		zap.Int64("prom_id", promID),
-		zap.String("path", filepath),
+		zap.String("path", currentPath),
+		zap.Int64("version", v.Version),
+		zap.String("source", source),
 		zap.String("hash", hash),
 	)
 
-	return filepath, nil
+	return &v, nil
+}
+
+// writeFile 原子地写入文件: 先写入同目录下的临时文件并同步到磁盘, 再重命名为目标文件, 目录不存在时自动创建.
+// 进程崩溃时目标文件要么是旧内容, 要么是完整的新内容.
+func writeFile(path string, content []byte) error {
+	return writeFileMode(path, content, 0644)
+}
+
+// writeFileMode 与 writeFile 相同, 使用指定的文件权限
+func writeFileMode(path string, content []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, tempFilePrefix+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir 同步目录, 确保重命名在崩溃后仍然生效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
 }
 
 func (s *Storage) GetCurrentRule(promID int64) string {
 	return s.getCurrentPath(promID)
 }
 
-func (s *Storage) ListVersions(promID int64, limit int) ([]RuleVersion, error) {
-	if !s.enableHistory {
-		return nil, fmt.Errorf("history is disabled")
+// ReadVersion 读取历史版本内容. version 为版本号或历史文件名, 为空时读取当前规则文件
+func (s *Storage) ReadVersion(promID int64, version string) ([]byte, error) {
+	path := s.getCurrentPath(promID)
+	if version != "" {
+		name, err := s.resolveVersion(promID, version)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(s.getPromHistoryDir(promID), name)
 	}
 
-	historyDir := s.getPromHistoryDir(promID)
-	files, err := ioutil.ReadDir(historyDir)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []RuleVersion{}, nil
+			return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, version)
 		}
-		return nil, fmt.Errorf("failed to read history directory: %w", err)
+		return nil, fmt.Errorf("failed to read rule version: %w", err)
 	}
+	return content, nil
+}
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].ModTime().After(files[j].ModTime())
-	})
-
-	versions := []RuleVersion{}
-	for i, file := range files {
-		if i >= limit && limit > 0 {
-			break
+// resolveVersion 将版本号转换为历史文件名, 文件名原样返回
+func (s *Storage) resolveVersion(promID int64, version string) (string, error) {
+	n, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		if version != filepath.Base(version) || !strings.HasSuffix(version, ".yml") {
+			return "", fmt.Errorf("invalid version %q", version)
 		}
+		return version, nil
+	}
 
-		if file.IsDir() {
-			continue
-		}
+	v, err := s.Version(promID, n)
+	if err != nil {
+		return "", err
+	}
+	return v.Name, nil
+}
 
-		content, err := os.ReadFile(filepath.Join(historyDir, file.Name()))
-		if err != nil {
-			continue
+// Version 返回指定版本号的版本信息
+func (s *Storage) Version(promID, version int64) (RuleVersion, error) {
+	if !s.enableHistory {
+		return RuleVersion{}, ErrHistoryDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	for _, v := range idx.Versions {
+		if v.Version == version {
+			return v, nil
 		}
+	}
+	return RuleVersion{}, fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+}
 
-		versions = append(versions, RuleVersion{
-			Version:   int64(i + 1),
-			PromID:    promID,
-			CreatedAt: file.ModTime(),
-			FilePath:  filepath.Join(historyDir, file.Name()),
-			Hash:      s.calculateHash(content),
-		})
+// ListVersions 按版本号倒序列出历史版本, limit 为 0 时不限制数量
+func (s *Storage) ListVersions(promID int64, limit int) ([]RuleVersion, error) {
+	if !s.enableHistory {
+		return nil, ErrHistoryDisabled
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]RuleVersion, 0, len(idx.Versions))
+	for i := len(idx.Versions) - 1; i >= 0; i-- {
+		if limit > 0 && len(versions) >= limit {
+			break
+		}
+		versions = append(versions, idx.Versions[i])
+	}
 	return versions, nil
 }
 
+// Pin 固定回滚后的版本, 网关规则的内容哈希与 gatewayHash 不同时由调用方解除固定
+func (s *Storage) Pin(promID, version int64, gatewayHash, reason string) error {
+	if !s.enableHistory {
+		return ErrHistoryDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return err
+	}
+	idx.Pin = &Pin{
+		Version:     version,
+		GatewayHash: gatewayHash,
+		PinnedAt:    time.Now(),
+		Reason:      reason,
+	}
+	return s.saveIndex(promID, idx)
+}
+
+// Unpin 解除固定的版本
+func (s *Storage) Unpin(promID int64) error {
+	if !s.enableHistory {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return err
+	}
+	if idx.Pin == nil {
+		return nil
+	}
+	idx.Pin = nil
+	return s.saveIndex(promID, idx)
+}
+
+// Pinned 返回固定的版本, 没有固定版本时返回 nil
+func (s *Storage) Pinned(promID int64) (*Pin, error) {
+	if !s.enableHistory {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.loadIndex(promID)
+	if err != nil {
+		return nil, err
+	}
+	if idx.Pin == nil {
+		return nil, nil
+	}
+	pin := *idx.Pin
+	return &pin, nil
+}
+
+// CleanupOldVersions 删除超过保留天数的历史版本, 最新版本和固定的版本始终保留
 func (s *Storage) CleanupOldVersions() error {
 	if !s.enableHistory {
 		return nil
@@ -124,41 +335,54 @@ func (s *Storage) CleanupOldVersions() error {
 		return fmt.Errorf("failed to read base directory: %w", err)
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	deletedCount := 0
 	for _, promDir := range promDirs {
+		var promID int64
 		if !promDir.IsDir() {
 			continue
 		}
+		if _, err := fmt.Sscanf(promDir.Name(), "prom_%d", &promID); err != nil {
+			continue
+		}
 
-		historyDir := filepath.Join(s.baseDir, promDir.Name(), "history")
-		files, err := ioutil.ReadDir(historyDir)
+		idx, err := s.loadIndex(promID)
 		if err != nil {
-			if os.IsNotExist(err) {
-				continue
-			}
-			s.logger.Error("failed to read history directory",
-				zap.String("dir", historyDir),
+			s.logger.Error("failed to load version index",
+				zap.Int64("prom_id", promID),
 				zap.Error(err),
 			)
 			continue
 		}
 
-		for _, file := range files {
-			if file.IsDir() {
+		kept := idx.Versions[:0]
+		for i, v := range idx.Versions {
+			pinned := idx.Pin != nil && idx.Pin.Version == v.Version
+			if i == len(idx.Versions)-1 || pinned || !v.CreatedAt.Before(cutoffTime) {
+				kept = append(kept, v)
 				continue
 			}
-
-			if file.ModTime().Before(cutoffTime) {
-				filePath := filepath.Join(historyDir, file.Name())
-				if err := os.Remove(filePath); err != nil {
-					s.logger.Error("failed to remove old version",
-						zap.String("path", filePath),
-						zap.Error(err),
-					)
-					continue
-				}
-				deletedCount++
+			if err := os.Remove(v.FilePath); err != nil && !os.IsNotExist(err) {
+				s.logger.Error("failed to remove old version",
+					zap.String("path", v.FilePath),
+					zap.Error(err),
+				)
+				kept = append(kept, v)
+				continue
 			}
+			deletedCount++
+		}
+		if len(kept) == len(idx.Versions) {
+			continue
+		}
+		idx.Versions = kept
+		if err := s.saveIndex(promID, idx); err != nil {
+			s.logger.Error("failed to save version index",
+				zap.Int64("prom_id", promID),
+				zap.Error(err),
+			)
 		}
 	}
 
@@ -181,15 +405,6 @@ func (s *Storage) getCurrentPath(promID int64) string {
 	return filepath.Join(s.baseDir, fmt.Sprintf("prom_%d", promID), "current.yml")
 }
 
-func (s *Storage) getHistoryPath(promID int64, timestamp string) string {
-	return filepath.Join(
-		s.baseDir,
-		fmt.Sprintf("prom_%d", promID),
-		"history",
-		fmt.Sprintf("rule_%s.yml", timestamp),
-	)
-}
-
 func (s *Storage) getPromHistoryDir(promID int64) string {
 	return filepath.Join(s.baseDir, fmt.Sprintf("prom_%d", promID), "history")
 }
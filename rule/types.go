@@ -2,6 +2,12 @@ package rule
 
 import (
 	"alertengine/common"
+	"alertengine/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -10,24 +16,76 @@ import (
 )
 
 type Prom struct {
-	ID  int64  `json:"id"`
-	URL string `json:"url"`
+	ID             int64                  `json:"id" yaml:"id"`
+	URL            string                 `json:"url" yaml:"url"`
+	ExternalLabels common.Labels          `json:"external_labels,omitempty" yaml:"external_labels,omitempty"`
+	Auth           *config.DatasourceAuth `json:"auth,omitempty" yaml:"auth,omitempty"`
 }
 
 type Rule struct {
-	ID          int64         `json:"id"`
-	PromID      int64         `json:"prom_id"`
-	Expr        string        `json:"expr"`
-	Op          string        `json:"op"`
-	Value       string        `json:"value"`
-	For         string        `json:"for"`
-	Labels      common.Labels `json:"labels"`
-	Summary     string        `json:"summary"`
-	Description string        `json:"description"`
+	ID          int64         `json:"id" yaml:"id"`
+	PromID      int64         `json:"prom_id" yaml:"prom_id"`
+	Expr        string        `json:"expr" yaml:"expr"`
+	Op          string        `json:"op" yaml:"op"`
+	Value       string        `json:"value" yaml:"value"`
+	For         string        `json:"for" yaml:"for"`
+	Labels      common.Labels `json:"labels" yaml:"labels"`
+	Summary     string        `json:"summary" yaml:"summary"`
+	Description string        `json:"description" yaml:"description"`
 }
 
 type Rules []Rule
 
+// Hash 返回规则内容的哈希, 用于判断规则是否变化
+func (r Rule) Hash() string {
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RulesDiff 按规则ID比较两组规则的差异
+type RulesDiff struct {
+	Added    []int64
+	Removed  []int64
+	Modified []int64
+}
+
+// Empty 判断是否没有任何规则变化
+func (d RulesDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Modified) == 0
+}
+
+// DiffRules 比较新旧两组规则, 返回新增、删除和内容变化的规则ID, 均按ID排序
+func DiffRules(old, new Rules) RulesDiff {
+	oldHashes := make(map[int64]string, len(old))
+	for _, r := range old {
+		oldHashes[r.ID] = r.Hash()
+	}
+
+	var d RulesDiff
+	seen := make(map[int64]bool, len(new))
+	for _, r := range new {
+		seen[r.ID] = true
+		hash, ok := oldHashes[r.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, r.ID)
+		case hash != r.Hash():
+			d.Modified = append(d.Modified, r.ID)
+		}
+	}
+	for _, r := range old {
+		if !seen[r.ID] {
+			d.Removed = append(d.Removed, r.ID)
+		}
+	}
+
+	for _, ids := range [][]int64{d.Added, d.Removed, d.Modified} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return d
+}
+
 type PromRules struct {
 	Prom  Prom  `json:"prom"`
 	Rules Rules `json:"rules"`
@@ -37,6 +95,12 @@ type RulesResp struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 	Data Rules  `json:"data"`
+
+	// 分页获取时的下一页游标, 为空表示没有更多数据或使用页码分页
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// 分页获取时的规则总数, 使用页码分页时用于判断是否还有下一页
+	Total int `json:"total,omitempty"`
 }
 
 type PromsResp struct {
@@ -49,14 +113,15 @@ type M map[string]interface{}
 
 type S []interface{}
 
-func (r Rules) Content() ([]byte, error) {
+// Content 生成 Prometheus 规则文件内容, 外部标签合并到每条规则的标签中, 规则自身的标签优先
+func (r Rules) Content(externalLabels common.Labels) ([]byte, error) {
 	rules := S{}
 	for _, i := range r {
 		rules = append(rules, M{
 			"alert":  strconv.FormatInt(i.ID, 10),
 			"expr":   strings.Join([]string{i.Expr, i.Op, i.Value}, " "),
 			"for":    i.For,
-			"labels": i.Labels,
+			"labels": i.Labels.Merge(externalLabels),
 			"annotations": M{
 				"rule_id":     strconv.FormatInt(i.ID, 10),
 				"prom_id":     strconv.FormatInt(i.PromID, 10),
@@ -78,6 +143,59 @@ func (r Rules) Content() ([]byte, error) {
 	return yaml.Marshal(result)
 }
 
+// Normalize 返回与规则文件内容一致的规则: 比较运算符和阈值合并到 Expr 中, 外部标签合并到标签中.
+// 用于比较网关规则和从历史版本解析的规则.
+func (r Rules) Normalize(externalLabels common.Labels) Rules {
+	res := make(Rules, len(r))
+	for i, rule := range r {
+		rule.Expr = strings.TrimSpace(strings.Join([]string{rule.Expr, rule.Op, rule.Value}, " "))
+		rule.Op, rule.Value = "", ""
+		rule.Labels = rule.Labels.Merge(externalLabels)
+		res[i] = rule
+	}
+	return res
+}
+
+// ParseContent 解析 Content 生成的规则文件, 用于回滚历史版本. 比较运算符和阈值保留在 Expr 中, 标签中已合并外部标签
+func ParseContent(content []byte) (Rules, error) {
+	var file struct {
+		Groups []struct {
+			Name  string `yaml:"name"`
+			Rules []struct {
+				Alert       string            `yaml:"alert"`
+				Expr        string            `yaml:"expr"`
+				For         string            `yaml:"for"`
+				Labels      common.Labels     `yaml:"labels"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, err
+	}
+
+	rules := Rules{}
+	for _, g := range file.Groups {
+		for _, r := range g.Rules {
+			id, err := strconv.ParseInt(r.Alert, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("group %q: invalid rule id %q", g.Name, r.Alert)
+			}
+			promID, _ := strconv.ParseInt(r.Annotations["prom_id"], 10, 64)
+			rules = append(rules, Rule{
+				ID:          id,
+				PromID:      promID,
+				Expr:        strings.TrimSpace(r.Expr),
+				For:         r.For,
+				Labels:      r.Labels,
+				Summary:     r.Annotations["summary"],
+				Description: r.Annotations["description"],
+			})
+		}
+	}
+	return rules, nil
+}
+
 func (r Rules) PromRules() []PromRules {
 	tmp := map[int64]Rules{}
 
@@ -100,11 +218,36 @@ func (r Rules) PromRules() []PromRules {
 	return data
 }
 
+// 规则版本的来源
+const (
+	// SourceGateway 从规则来源同步的规则
+	SourceGateway = "gateway"
+
+	// SourceAPI 通过 HTTP 接口或命令行手动回滚生成的版本
+	SourceAPI = "api"
+
+	// SourceRollback 管理器重建或重启后重新应用固定的版本
+	SourceRollback = "rollback"
+)
+
+// RuleVersion 规则历史版本, 版本号按数据源单调递增
 type RuleVersion struct {
 	Version   int64     `json:"version"`
 	PromID    int64     `json:"prom_id"`
+	Name      string    `json:"name"`
 	RuleCount int       `json:"rule_count"`
 	CreatedAt time.Time `json:"created_at"`
-	FilePath  string    `json:"file_path"`
+	FilePath  string    `json:"-"`
 	Hash      string    `json:"hash"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Pin 回滚后固定的版本, 网关规则发生变化前一直生效
+type Pin struct {
+	Version int64 `json:"version"`
+	// 固定时网关规则的内容哈希, 网关规则与之不同时解除固定
+	GatewayHash string    `json:"gateway_hash"`
+	PinnedAt    time.Time `json:"pinned_at"`
+	Reason      string    `json:"reason,omitempty"`
 }
@@ -0,0 +1,114 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+
+	"alertengine/common"
+)
+
+func testRule(id int64, expr string) Rule {
+	return Rule{
+		ID:          id,
+		PromID:      1,
+		Expr:        expr,
+		Op:          ">",
+		Value:       "90",
+		For:         "5m",
+		Labels:      common.FromMap(map[string]string{"severity": "critical"}),
+		Summary:     "high usage",
+		Description: "usage is {{ $value }}",
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	modified := testRule(2, "cpu_usage")
+	modified.Value = "80"
+
+	tests := []struct {
+		name     string
+		old, new Rules
+		want     RulesDiff
+	}{
+		{
+			name: "unchanged",
+			old:  Rules{testRule(1, "cpu_usage"), testRule(2, "cpu_usage")},
+			new:  Rules{testRule(2, "cpu_usage"), testRule(1, "cpu_usage")},
+			want: RulesDiff{},
+		},
+		{
+			name: "added, removed and modified",
+			old:  Rules{testRule(3, "disk_usage"), testRule(2, "cpu_usage"), testRule(1, "mem_usage")},
+			new:  Rules{testRule(5, "load"), modified, testRule(4, "load"), testRule(1, "mem_usage")},
+			want: RulesDiff{Added: []int64{4, 5}, Removed: []int64{3}, Modified: []int64{2}},
+		},
+		{
+			name: "from empty",
+			new:  Rules{testRule(2, "cpu_usage"), testRule(1, "mem_usage")},
+			want: RulesDiff{Added: []int64{1, 2}},
+		},
+		{
+			name: "to empty",
+			old:  Rules{testRule(2, "cpu_usage"), testRule(1, "mem_usage")},
+			want: RulesDiff{Removed: []int64{1, 2}},
+		},
+	}
+
+	for _, tc := range tests {
+		got := DiffRules(tc.old, tc.new)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+		if got.Empty() != (len(tc.want.Added)+len(tc.want.Removed)+len(tc.want.Modified) == 0) {
+			t.Errorf("%s: Empty = %v", tc.name, got.Empty())
+		}
+	}
+}
+
+func TestParseContentRoundTrip(t *testing.T) {
+	noLabels := testRule(3, `rate(http_requests_total{code=~"5.."}[5m])`)
+	noLabels.Labels = nil
+	noOp := testRule(4, "up == 0")
+	noOp.Op, noOp.Value = "", ""
+	override := testRule(5, "cpu_usage")
+	override.Labels = common.FromMap(map[string]string{"cluster": "rule", "team": "infra"})
+
+	rules := Rules{testRule(1, "cpu_usage"), testRule(2, `node_load1{instance="a:9100"}`), noLabels, noOp, override}
+
+	for _, ext := range []common.Labels{
+		nil,
+		common.FromMap(map[string]string{"cluster": "prod", "region": "eu"}),
+	} {
+		content, err := rules.Content(ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseContent(content)
+		if err != nil {
+			t.Fatalf("external labels %s: %v", ext, err)
+		}
+
+		want := rules.Normalize(ext)
+		if d := DiffRules(want, parsed); !d.Empty() {
+			t.Errorf("external labels %s: parsed rules differ from normalized rules: %+v\n%s", ext, d, content)
+		}
+	}
+
+	// 规则自身的标签优先于外部标签
+	merged := Rules{override}.Normalize(common.FromMap(map[string]string{"cluster": "prod"}))
+	if got := merged[0].Labels.Get("cluster"); got != "rule" {
+		t.Errorf("cluster = %q, want rule", got)
+	}
+}
+
+func TestParseContentErrors(t *testing.T) {
+	for _, content := range []string{
+		"groups:\n- name: ruleengine\n  rules:\n  - alert: HighCPU\n    expr: up == 0\n",
+		"groups:\n- name: ruleengine\n  unknown: true\n",
+		"groups: [",
+	} {
+		if _, err := ParseContent([]byte(content)); err == nil {
+			t.Errorf("expected error for content:\n%s", content)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"alertengine/common"
+
+	"go.uber.org/zap"
+)
+
+// State 静默状态
+type State string
+
+const (
+	StatePending State = "pending"
+	StateActive  State = "active"
+	StateExpired State = "expired"
+)
+
+var (
+	// ErrNotFound 静默不存在
+	ErrNotFound = errors.New("silence not found")
+	// ErrAlreadyExpired 静默已过期
+	ErrAlreadyExpired = errors.New("silence already expired")
+	// ErrInvalid 静默参数不合法, 具体原因包含在错误信息中
+	ErrInvalid = errors.New("invalid silence")
+)
+
+// Silence 告警静默
+type Silence struct {
+	ID        string          `json:"id"`
+	Matchers  common.Matchers `json:"matchers"`
+	StartsAt  time.Time       `json:"starts_at"`
+	EndsAt    time.Time       `json:"ends_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	CreatedBy string          `json:"created_by"`
+	Comment   string          `json:"comment"`
+	Status    State           `json:"status"`
+}
+
+// state 计算静默在给定时间点的状态
+func (s *Silence) state(now time.Time) State {
+	if now.Before(s.StartsAt) {
+		return StatePending
+	}
+	if now.Before(s.EndsAt) {
+		return StateActive
+	}
+	return StateExpired
+}
+
+// validate 校验静默参数, 返回的错误包装了 ErrInvalid
+func (s *Silence) validate() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("%w: at least one matcher is required", ErrInvalid)
+	}
+	for i, m := range s.Matchers {
+		if m == nil {
+			return fmt.Errorf("%w: matcher %d is empty", ErrInvalid, i)
+		}
+	}
+	if s.Matchers.Matches(nil) {
+		return fmt.Errorf("%w: at least one matcher must not match the empty string", ErrInvalid)
+	}
+	if s.StartsAt.IsZero() || s.EndsAt.IsZero() {
+		return fmt.Errorf("%w: starts_at and ends_at are required", ErrInvalid)
+	}
+	if s.EndsAt.Before(s.StartsAt) {
+		return fmt.Errorf("%w: ends_at must not be before starts_at", ErrInvalid)
+	}
+	if s.CreatedBy == "" {
+		return fmt.Errorf("%w: created_by cannot be empty", ErrInvalid)
+	}
+	if s.Comment == "" {
+		return fmt.Errorf("%w: comment cannot be empty", ErrInvalid)
+	}
+	return nil
+}
+
+// Store 静默存储, 变更时持久化到磁盘
+type Store struct {
+	path      string
+	retention time.Duration
+	silences  map[string]*Silence
+	mu        sync.RWMutex
+	logger    *zap.Logger
+}
+
+// NewStore 创建静默存储并加载已有数据
+func NewStore(path string, retention time.Duration, logger *zap.Logger) (*Store, error) {
+	s := &Store{
+		path:      path,
+		retention: retention,
+		silences:  make(map[string]*Silence),
+		logger:    logger,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create 创建静默, 返回静默ID
+func (s *Store) Create(sil Silence) (string, error) {
+	now := time.Now()
+	if sil.StartsAt.IsZero() {
+		sil.StartsAt = now
+	}
+	if err := sil.validate(); err != nil {
+		return "", err
+	}
+	if !sil.EndsAt.After(now) {
+		return "", fmt.Errorf("%w: ends_at must be in the future", ErrInvalid)
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	sil.ID = id
+	sil.UpdatedAt = now
+	sil.Status = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.silences[id] = &sil
+	if err := s.persist(); err != nil {
+		delete(s.silences, id)
+		return "", err
+	}
+
+	s.logger.Info("silence created",
+		zap.String("silence_id", id),
+		zap.String("created_by", sil.CreatedBy),
+		zap.Time("ends_at", sil.EndsAt),
+	)
+
+	return id, nil
+}
+
+// Get 获取静默
+func (s *Store) Get(id string) (Silence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sil, ok := s.silences[id]
+	if !ok {
+		return Silence{}, ErrNotFound
+	}
+	return s.view(sil, time.Now()), nil
+}
+
+// List 列出所有静默, 按开始时间倒序
+func (s *Store) List() []Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]Silence, 0, len(s.silences))
+	for _, sil := range s.silences {
+		result = append(result, s.view(sil, now))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartsAt.After(result[j].StartsAt)
+	})
+
+	return result
+}
+
+// Expire 使静默立即过期
+func (s *Store) Expire(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sil, ok := s.silences[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	old := *sil
+	switch sil.state(now) {
+	case StateExpired:
+		return ErrAlreadyExpired
+	case StatePending:
+		sil.StartsAt = now
+		sil.EndsAt = now
+	case StateActive:
+		sil.EndsAt = now
+	}
+	sil.UpdatedAt = now
+
+	if err := s.persist(); err != nil {
+		*sil = old
+		return err
+	}
+
+	s.logger.Info("silence expired", zap.String("silence_id", id))
+	return nil
+}
+
+// Mutes 判断标签集合是否被某个生效中的静默匹配
+func (s *Store) Mutes(lset common.Labels) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, sil := range s.silences {
+		if sil.state(now) != StateActive {
+			continue
+		}
+		if sil.Matchers.Matches(lset) {
+			return true
+		}
+	}
+	return false
+}
+
+// GC 删除过期时间超过保留期的静默
+func (s *Store) GC() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.retention)
+	removed := 0
+	for id, sil := range s.silences {
+		if sil.EndsAt.Before(cutoff) {
+			delete(s.silences, id)
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.persist()
+}
+
+// view 返回带状态的静默副本
+func (s *Store) view(sil *Silence, now time.Time) Silence {
+	v := *sil
+	v.Status = sil.state(now)
+	return v
+}
+
+// load 从磁盘加载静默
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read silence file: %w", err)
+	}
+
+	var silences []*Silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return fmt.Errorf("failed to parse silence file: %w", err)
+	}
+
+	for _, sil := range silences {
+		if err := sil.validate(); err != nil {
+			s.logger.Warn("skipping invalid silence",
+				zap.String("silence_id", sil.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		sil.Status = ""
+		s.silences[sil.ID] = sil
+	}
+
+	s.logger.Info("silences loaded",
+		zap.String("path", s.path),
+		zap.Int("count", len(s.silences)),
+	)
+
+	return nil
+}
+
+// persist 将静默写入磁盘, 调用方需持有写锁
+func (s *Store) persist() error {
+	silences := make([]*Silence, 0, len(s.silences))
+	for _, sil := range s.silences {
+		silences = append(silences, sil)
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].ID < silences[j].ID
+	})
+
+	data, err := json.MarshalIndent(silences, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal silences: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write silence file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to rename silence file: %w", err)
+	}
+
+	return nil
+}
+
+// newID 生成随机静默ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate silence id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
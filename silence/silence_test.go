@@ -0,0 +1,316 @@
+package silence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alertengine/common"
+
+	"go.uber.org/zap"
+)
+
+func newTestStore(t *testing.T, retention time.Duration) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "silences.json")
+	s, err := NewStore(path, retention, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func testSilence(matchers ...*common.Matcher) Silence {
+	now := time.Now()
+	return Silence{
+		Matchers:  matchers,
+		StartsAt:  now.Add(-time.Minute),
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "alice",
+		Comment:   "maintenance",
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	s, _ := newTestStore(t, time.Hour)
+	now := time.Now()
+	matcher := common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU")
+
+	tests := []struct {
+		name   string
+		modify func(*Silence)
+	}{
+		{"no matchers", func(sil *Silence) { sil.Matchers = nil }},
+		{"nil matcher", func(sil *Silence) { sil.Matchers = common.Matchers{matcher, nil} }},
+		{"matches empty labels", func(sil *Silence) {
+			sil.Matchers = common.Matchers{common.MustNewMatcher(common.MatchRegexp, "alertname", ".*")}
+		}},
+		{"missing ends_at", func(sil *Silence) { sil.EndsAt = time.Time{} }},
+		{"ends before starts", func(sil *Silence) { sil.StartsAt, sil.EndsAt = now.Add(2*time.Hour), now.Add(time.Hour) }},
+		{"ends in the past", func(sil *Silence) { sil.StartsAt, sil.EndsAt = now.Add(-2*time.Hour), now.Add(-time.Hour) }},
+		{"missing created_by", func(sil *Silence) { sil.CreatedBy = "" }},
+		{"missing comment", func(sil *Silence) { sil.Comment = "" }},
+	}
+
+	for _, tc := range tests {
+		sil := testSilence(matcher)
+		tc.modify(&sil)
+		if _, err := s.Create(sil); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: got error %v, want ErrInvalid", tc.name, err)
+		}
+	}
+	if n := len(s.List()); n != 0 {
+		t.Errorf("got %d silences after invalid creates, want 0", n)
+	}
+
+	// 未指定开始时间时从当前时间开始
+	sil := testSilence(matcher)
+	sil.StartsAt = time.Time{}
+	id, err := s.Create(sil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StartsAt.IsZero() || got.Status != StateActive {
+		t.Errorf("starts_at = %v, status = %s", got.StartsAt, got.Status)
+	}
+}
+
+func TestCreatePersistError(t *testing.T) {
+	s, path := newTestStore(t, time.Hour)
+	// 静默文件路径被非空目录占用, 重命名失败
+	if err := os.MkdirAll(filepath.Join(path, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.Create(testSilence(common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU")))
+	if err == nil || errors.Is(err, ErrInvalid) {
+		t.Errorf("got error %v, want a non-validation error", err)
+	}
+	if n := len(s.List()); n != 0 {
+		t.Errorf("got %d silences after failed persist, want 0", n)
+	}
+}
+
+func TestMutes(t *testing.T) {
+	lset := common.FromMap(map[string]string{"alertname": "HighCPU", "instance": "node-1:9100", "severity": "critical"})
+
+	tests := []struct {
+		matcher *common.Matcher
+		mutes   bool
+	}{
+		{common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU"), true},
+		{common.MustNewMatcher(common.MatchEqual, "alertname", "HighMemory"), false},
+		{common.MustNewMatcher(common.MatchNotEqual, "severity", "warning"), true},
+		{common.MustNewMatcher(common.MatchNotEqual, "severity", "critical"), false},
+		{common.MustNewMatcher(common.MatchRegexp, "instance", "node-.*"), true},
+		{common.MustNewMatcher(common.MatchRegexp, "instance", "node"), false},
+		{common.MustNewMatcher(common.MatchNotRegexp, "instance", "db-.*"), true},
+		{common.MustNewMatcher(common.MatchNotRegexp, "instance", "node-.*"), false},
+	}
+
+	for _, tc := range tests {
+		s, _ := newTestStore(t, time.Hour)
+		// 第二个匹配器保证静默不会匹配空标签集合
+		if _, err := s.Create(testSilence(tc.matcher, common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU"))); err != nil {
+			t.Fatalf("%s: %v", tc.matcher, err)
+		}
+		if got := s.Mutes(lset); got != tc.mutes {
+			t.Errorf("%s: Mutes = %v, want %v", tc.matcher, got, tc.mutes)
+		}
+	}
+}
+
+func TestMutesOnlyActive(t *testing.T) {
+	s, _ := newTestStore(t, time.Hour)
+	lset := common.FromMap(map[string]string{"alertname": "HighCPU"})
+
+	pending := testSilence(common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU"))
+	pending.StartsAt = time.Now().Add(time.Hour)
+	pending.EndsAt = time.Now().Add(2 * time.Hour)
+	id, err := s.Create(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sil, _ := s.Get(id); sil.Status != StatePending {
+		t.Errorf("status = %s, want pending", sil.Status)
+	}
+	if s.Mutes(lset) {
+		t.Error("pending silence should not mute")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s, _ := newTestStore(t, time.Hour)
+	lset := common.FromMap(map[string]string{"alertname": "HighCPU"})
+	matcher := common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU")
+
+	active, err := s.Create(testSilence(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pendingSil := testSilence(matcher)
+	pendingSil.StartsAt = time.Now().Add(time.Hour)
+	pendingSil.EndsAt = time.Now().Add(2 * time.Hour)
+	pending, err := s.Create(pendingSil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Mutes(lset) {
+		t.Fatal("active silence should mute")
+	}
+	for _, id := range []string{active, pending} {
+		if err := s.Expire(id); err != nil {
+			t.Fatal(err)
+		}
+		sil, _ := s.Get(id)
+		if sil.Status != StateExpired {
+			t.Errorf("%s: status = %s, want expired", id, sil.Status)
+		}
+		if sil.StartsAt.After(sil.EndsAt) {
+			t.Errorf("%s: starts_at %v after ends_at %v", id, sil.StartsAt, sil.EndsAt)
+		}
+	}
+	if s.Mutes(lset) {
+		t.Error("expired silence should not mute")
+	}
+
+	if err := s.Expire(active); !errors.Is(err, ErrAlreadyExpired) {
+		t.Errorf("got error %v, want ErrAlreadyExpired", err)
+	}
+	if err := s.Expire("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want ErrNotFound", err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	matcher := common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU")
+
+	// 保留期内的过期静默不删除
+	s, _ := newTestStore(t, time.Hour)
+	id, err := s.Create(testSilence(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire(id); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.GC(); err != nil || n != 0 {
+		t.Errorf("GC = %d, %v, want 0", n, err)
+	}
+
+	// 超过保留期的过期静默被删除, 生效中的静默保留
+	s, path := newTestStore(t, 0)
+	expired, err := s.Create(testSilence(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := s.Create(testSilence(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire(expired); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if n, err := s.GC(); err != nil || n != 1 {
+		t.Fatalf("GC = %d, %v, want 1", n, err)
+	}
+	if _, err := s.Get(expired); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired silence still present: %v", err)
+	}
+	if _, err := s.Get(active); err != nil {
+		t.Errorf("active silence removed: %v", err)
+	}
+
+	// GC 的结果写入磁盘
+	reloaded, err := NewStore(path, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.List()); n != 1 {
+		t.Errorf("got %d silences after reload, want 1", n)
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, path := newTestStore(t, time.Hour)
+	lset := common.FromMap(map[string]string{"alertname": "HighCPU", "instance": "node-1"})
+
+	active, err := s.Create(testSilence(
+		common.MustNewMatcher(common.MatchEqual, "alertname", "HighCPU"),
+		common.MustNewMatcher(common.MatchRegexp, "instance", "node-.*"),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.Create(testSilence(common.MustNewMatcher(common.MatchEqual, "alertname", "Other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStore(path, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.List()); n != 2 {
+		t.Fatalf("got %d silences after reload, want 2", n)
+	}
+	sil, err := reloaded.Get(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sil.CreatedBy != "alice" || sil.Comment != "maintenance" || len(sil.Matchers) != 2 {
+		t.Errorf("unexpected silence after reload: %+v", sil)
+	}
+	if !reloaded.Mutes(lset) {
+		t.Error("reloaded silence should mute")
+	}
+	if sil, _ := reloaded.Get(expired); sil.Status != StateExpired {
+		t.Errorf("status = %s, want expired", sil.Status)
+	}
+}
+
+func TestReloadSkipsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences.json")
+	content := `[
+  {"id": "a", "matchers": [{"type": "=", "name": "alertname", "value": "HighCPU"}],
+   "starts_at": "2026-01-01T00:00:00Z", "ends_at": "2026-01-02T00:00:00Z", "created_by": "alice", "comment": "ok"},
+  {"id": "b", "matchers": [],
+   "starts_at": "2026-01-01T00:00:00Z", "ends_at": "2026-01-02T00:00:00Z", "created_by": "alice", "comment": "no matchers"},
+  {"id": "c", "matchers": [null],
+   "starts_at": "2026-01-01T00:00:00Z", "ends_at": "2026-01-02T00:00:00Z", "created_by": "alice", "comment": "null matcher"}
+]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(path, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("a"); err != nil {
+		t.Errorf("valid silence not loaded: %v", err)
+	}
+	for _, id := range []string{"b", "c"} {
+		if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("invalid silence %s loaded: %v", id, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(path, time.Hour, zap.NewNop()); err == nil {
+		t.Error("expected error for corrupt silence file")
+	}
+}
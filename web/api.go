@@ -0,0 +1,634 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alertengine/common"
+	"alertengine/engine"
+	"alertengine/rule"
+	"alertengine/silence"
+
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+// API 告警引擎本地HTTP接口
+type API struct {
+	reloader *engine.Reloader
+	storage  *rule.Storage
+	silences *silence.Store
+	logger   *zap.Logger
+}
+
+// NewAPI 创建本地HTTP接口
+func NewAPI(reloader *engine.Reloader, storage *rule.Storage, silences *silence.Store, logger *zap.Logger) *API {
+	return &API{
+		reloader: reloader,
+		storage:  storage,
+		silences: silences,
+		logger:   logger,
+	}
+}
+
+// Register 注册路由
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/rules", a.rules)
+	mux.HandleFunc("POST /api/v1/rules/test", a.testRule)
+	mux.HandleFunc("POST /api/v1/rules/backtest", a.backtestRule)
+	mux.HandleFunc("GET /api/v1/alerts", a.alerts)
+
+	mux.HandleFunc("GET /api/v1/history/{prom_id}", a.listVersions)
+	mux.HandleFunc("GET /api/v1/history/{prom_id}/content", a.versionContent)
+	mux.HandleFunc("GET /api/v1/history/{prom_id}/diff", a.versionDiff)
+	mux.HandleFunc("GET /api/v1/history/{prom_id}/rules/diff", a.ruleDiff)
+	mux.HandleFunc("POST /api/v1/history/{prom_id}/rollback", a.rollback)
+
+	mux.HandleFunc("GET /api/v1/status/config", a.serveConfig)
+
+	mux.HandleFunc("GET /api/v1/silences", a.listSilences)
+	mux.HandleFunc("POST /api/v1/silences", a.createSilence)
+	mux.HandleFunc("GET /api/v1/silences/{id}", a.getSilence)
+	mux.HandleFunc("DELETE /api/v1/silences/{id}", a.expireSilence)
+}
+
+// RuleDiscovery 规则列表, 与 Prometheus /api/v1/rules 的格式一致
+type RuleDiscovery struct {
+	RuleGroups []*RuleGroup `json:"groups"`
+}
+
+// RuleGroup 规则组, 每个数据源对应一个规则组
+type RuleGroup struct {
+	Name           string          `json:"name"`
+	File           string          `json:"file"`
+	PromID         int64           `json:"promId"`
+	PromURL        string          `json:"promUrl"`
+	Rules          []*AlertingRule `json:"rules"`
+	Interval       float64         `json:"interval"`
+	EvaluationTime float64         `json:"evaluationTime"`
+	LastEvaluation time.Time       `json:"lastEvaluation"`
+}
+
+// AlertingRule 告警规则状态
+type AlertingRule struct {
+	State          string            `json:"state"`
+	Name           string            `json:"name"`
+	Query          string            `json:"query"`
+	Duration       float64           `json:"duration"`
+	Labels         common.Labels     `json:"labels"`
+	Annotations    map[string]string `json:"annotations"`
+	Alerts         []*Alert          `json:"alerts"`
+	Health         string            `json:"health"`
+	LastError      string            `json:"lastError,omitempty"`
+	EvaluationTime float64           `json:"evaluationTime"`
+	LastEvaluation time.Time         `json:"lastEvaluation"`
+	Type           string            `json:"type"`
+}
+
+// AlertDiscovery 活跃告警列表, 与 Prometheus /api/v1/alerts 的格式一致
+type AlertDiscovery struct {
+	Alerts []*Alert `json:"alerts"`
+}
+
+// Alert 活跃告警实例
+type Alert struct {
+	Labels      common.Labels     `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+	ActiveAt    *time.Time        `json:"activeAt,omitempty"`
+	FiredAt     *time.Time        `json:"firedAt,omitempty"`
+	Value       string            `json:"value"`
+}
+
+// ConfigStatus 当前生效的配置, 与 Prometheus /api/v1/status/config 的格式一致
+type ConfigStatus struct {
+	YAML string `json:"yaml"`
+}
+
+// serveConfig 返回当前生效的配置, 密钥和密码显示为 <secret>
+func (a *API) serveConfig(w http.ResponseWriter, r *http.Request) {
+	respond(w, &ConfigStatus{YAML: a.reloader.Config().String()})
+}
+
+func (a *API) rules(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondError(w, errorBadData, fmt.Errorf("error parsing form values: %w", err))
+		return
+	}
+	groupNames := stringSet(r.Form["rule_group[]"])
+	ruleNames := stringSet(r.Form["rule_name[]"])
+
+	res := &RuleDiscovery{RuleGroups: []*RuleGroup{}}
+	for _, m := range a.reloader.Managers() {
+		name := fmt.Sprintf("prom_%d", m.Prom().ID)
+		if len(groupNames) > 0 && !groupNames[name] {
+			continue
+		}
+
+		lastEval, evalTime := m.LastEvaluation()
+		group := &RuleGroup{
+			Name:           name,
+			File:           m.RuleFile(),
+			PromID:         m.Prom().ID,
+			PromURL:        m.Prom().URL,
+			Rules:          []*AlertingRule{},
+			Interval:       m.Interval().Seconds(),
+			EvaluationTime: evalTime.Seconds(),
+			LastEvaluation: lastEval,
+		}
+
+		for _, rule := range m.Rules() {
+			if len(ruleNames) > 0 && !ruleNames[rule.ID] {
+				continue
+			}
+
+			alerts := []*Alert{}
+			if alert, ok := m.ActiveAlert(rule); ok {
+				alerts = append(alerts, newAlert(alert))
+			}
+
+			group.Rules = append(group.Rules, &AlertingRule{
+				State:          rule.State.String(),
+				Name:           rule.ID,
+				Query:          rule.Expr,
+				Duration:       rule.For.Seconds(),
+				Labels:         rule.RuleLabels,
+				Annotations:    rule.Annotations,
+				Alerts:         alerts,
+				Health:         string(rule.Health),
+				LastError:      rule.LastError,
+				EvaluationTime: rule.EvaluationDuration.Seconds(),
+				LastEvaluation: rule.LastEvaluation,
+				Type:           "alerting",
+			})
+		}
+
+		if len(ruleNames) > 0 && len(group.Rules) == 0 {
+			continue
+		}
+		res.RuleGroups = append(res.RuleGroups, group)
+	}
+
+	respond(w, res)
+}
+
+func (a *API) alerts(w http.ResponseWriter, r *http.Request) {
+	res := &AlertDiscovery{Alerts: []*Alert{}}
+	for _, m := range a.reloader.Managers() {
+		for _, alert := range m.ActiveAlerts() {
+			res.Alerts = append(res.Alerts, newAlert(alert))
+		}
+	}
+
+	respond(w, res)
+}
+
+// RuleTestResult 规则试运行结果
+type RuleTestResult struct {
+	Query          string        `json:"query"`
+	Timestamp      time.Time     `json:"timestamp"`
+	EvaluationTime float64       `json:"evaluationTime"`
+	Samples        []*TestSample `json:"samples"`
+}
+
+// TestSample 试运行匹配到的序列
+type TestSample struct {
+	Metric      common.Labels     `json:"metric"`
+	Value       string            `json:"value"`
+	Labels      common.Labels     `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations"`
+	Dropped     bool              `json:"dropped"`
+	Inhibited   bool              `json:"inhibited"`
+	Silenced    bool              `json:"silenced"`
+}
+
+func (a *API) testRule(w http.ResponseWriter, r *http.Request) {
+	var rl rule.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rl); err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid rule: %w", err))
+		return
+	}
+
+	m, ok := a.reloader.Manager(rl.PromID)
+	if !ok {
+		respondError(w, errorNotFound, fmt.Errorf("prom %d not found", rl.PromID))
+		return
+	}
+
+	result, err := m.TestRule(r.Context(), rl)
+	switch {
+	case errors.Is(err, engine.ErrInvalidRule):
+		respondError(w, errorBadData, err)
+		return
+	case err != nil:
+		respondError(w, errorExec, err)
+		return
+	}
+
+	res := &RuleTestResult{
+		Query:          result.Expr,
+		Timestamp:      result.EvaluatedAt,
+		EvaluationTime: result.Duration.Seconds(),
+		Samples:        make([]*TestSample, 0, len(result.Samples)),
+	}
+	for _, s := range result.Samples {
+		res.Samples = append(res.Samples, &TestSample{
+			Metric:      s.Metric,
+			Value:       strconv.FormatFloat(s.Value, 'e', -1, 64),
+			Labels:      s.Labels,
+			Annotations: s.Annotations,
+			Dropped:     s.Dropped,
+			Inhibited:   s.Inhibited,
+			Silenced:    s.Silenced,
+		})
+	}
+
+	respond(w, res)
+}
+
+// BacktestRequest 规则回测请求, 未指定结束时间时回测到当前时间, 未指定步长时使用规则评估间隔
+type BacktestRequest struct {
+	Rule  rule.Rule      `json:"rule"`
+	Start time.Time      `json:"start"`
+	End   time.Time      `json:"end"`
+	Step  model.Duration `json:"step"`
+}
+
+// BacktestResult 规则回测结果
+type BacktestResult struct {
+	Query          string           `json:"query"`
+	Start          time.Time        `json:"start"`
+	End            time.Time        `json:"end"`
+	Step           float64          `json:"step"`
+	FiringCount    int              `json:"firingCount"`
+	FiringDuration float64          `json:"firingDuration"`
+	Events         []*BacktestEvent `json:"events"`
+}
+
+// BacktestEvent 回测产生的告警事件
+type BacktestEvent struct {
+	Time    time.Time     `json:"time"`
+	State   string        `json:"state"`
+	Value   string        `json:"value"`
+	Labels  common.Labels `json:"labels"`
+	Dropped bool          `json:"dropped"`
+}
+
+func (a *API) backtestRule(w http.ResponseWriter, r *http.Request) {
+	var req BacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid backtest request: %w", err))
+		return
+	}
+	if req.Start.IsZero() {
+		respondError(w, errorBadData, errors.New("start is required"))
+		return
+	}
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+
+	m, ok := a.reloader.Manager(req.Rule.PromID)
+	if !ok {
+		respondError(w, errorNotFound, fmt.Errorf("prom %d not found", req.Rule.PromID))
+		return
+	}
+
+	result, err := m.Backtest(r.Context(), req.Rule, req.Start, req.End, time.Duration(req.Step))
+	switch {
+	case errors.Is(err, engine.ErrInvalidRule), errors.Is(err, engine.ErrInvalidRange):
+		respondError(w, errorBadData, err)
+		return
+	case err != nil:
+		respondError(w, errorExec, err)
+		return
+	}
+
+	respond(w, NewBacktestResult(result))
+}
+
+// NewBacktestResult 转换回测结果为接口响应格式
+func NewBacktestResult(result *engine.BacktestResult) *BacktestResult {
+	res := &BacktestResult{
+		Query:          result.Expr,
+		Start:          result.Start,
+		End:            result.End,
+		Step:           result.Step.Seconds(),
+		FiringCount:    result.FiringCount,
+		FiringDuration: result.FiringDuration.Seconds(),
+		Events:         make([]*BacktestEvent, 0, len(result.Events)),
+	}
+	for _, e := range result.Events {
+		res.Events = append(res.Events, &BacktestEvent{
+			Time:    e.Time,
+			State:   e.State,
+			Value:   strconv.FormatFloat(e.Value, 'e', -1, 64),
+			Labels:  e.Labels,
+			Dropped: e.Dropped,
+		})
+	}
+	return res
+}
+
+func newAlert(alert engine.ActiveAlert) *Alert {
+	a := &Alert{
+		Labels:      alert.Labels,
+		Annotations: alert.Annotations,
+		State:       alert.State.String(),
+		Value:       strconv.FormatFloat(alert.Value, 'e', -1, 64),
+	}
+	if !alert.ActiveAt.IsZero() {
+		a.ActiveAt = &alert.ActiveAt
+	}
+	if !alert.FiredAt.IsZero() {
+		a.FiredAt = &alert.FiredAt
+	}
+	return a
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// RuleVersion 规则历史版本
+type RuleVersion struct {
+	Name      string    `json:"name"`
+	Version   int64     `json:"version"`
+	RuleCount int       `json:"ruleCount"`
+	CreatedAt time.Time `json:"createdAt"`
+	Hash      string    `json:"hash"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason,omitempty"`
+	Pinned    bool      `json:"pinned"`
+}
+
+// VersionDiff 两个规则版本之间的行级差异
+type VersionDiff struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Lines []rule.DiffLine `json:"lines"`
+}
+
+func (a *API) listVersions(w http.ResponseWriter, r *http.Request) {
+	promID, err := strconv.ParseInt(r.PathValue("prom_id"), 10, 64)
+	if err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid prom_id: %w", err))
+		return
+	}
+	limit := 0
+	if s := r.FormValue("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil {
+			respondError(w, errorBadData, fmt.Errorf("invalid limit: %w", err))
+			return
+		}
+	}
+
+	versions, err := a.storage.ListVersions(promID, limit)
+	if err != nil {
+		respondError(w, errorInternal, err)
+		return
+	}
+	pin, err := a.storage.Pinned(promID)
+	if err != nil {
+		respondError(w, errorInternal, err)
+		return
+	}
+
+	res := make([]*RuleVersion, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, &RuleVersion{
+			Name:      v.Name,
+			Version:   v.Version,
+			RuleCount: v.RuleCount,
+			CreatedAt: v.CreatedAt,
+			Hash:      v.Hash,
+			Source:    v.Source,
+			Reason:    v.Reason,
+			Pinned:    pin != nil && pin.Version == v.Version,
+		})
+	}
+
+	respond(w, res)
+}
+
+func (a *API) versionContent(w http.ResponseWriter, r *http.Request) {
+	promID, err := strconv.ParseInt(r.PathValue("prom_id"), 10, 64)
+	if err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid prom_id: %w", err))
+		return
+	}
+
+	content, err := a.storage.ReadVersion(promID, r.FormValue("version"))
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+
+	respond(w, map[string]string{"content": string(content)})
+}
+
+func (a *API) versionDiff(w http.ResponseWriter, r *http.Request) {
+	promID, err := strconv.ParseInt(r.PathValue("prom_id"), 10, 64)
+	if err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid prom_id: %w", err))
+		return
+	}
+
+	from, to := r.FormValue("from"), r.FormValue("to")
+	if from == "" {
+		respondError(w, errorBadData, errors.New("from version is required"))
+		return
+	}
+	fromContent, err := a.storage.ReadVersion(promID, from)
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+	toContent, err := a.storage.ReadVersion(promID, to)
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+
+	respond(w, &VersionDiff{
+		From:  from,
+		To:    to,
+		Lines: rule.DiffText(string(fromContent), string(toContent)),
+	})
+}
+
+// RuleDiff 两个规则版本之间的规则级差异
+type RuleDiff struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Changes []rule.RuleChange `json:"changes"`
+}
+
+func (a *API) ruleDiff(w http.ResponseWriter, r *http.Request) {
+	promID, err := strconv.ParseInt(r.PathValue("prom_id"), 10, 64)
+	if err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid prom_id: %w", err))
+		return
+	}
+
+	from, to := r.FormValue("from"), r.FormValue("to")
+	if from == "" {
+		respondError(w, errorBadData, errors.New("from version is required"))
+		return
+	}
+	fromContent, err := a.storage.ReadVersion(promID, from)
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+	toContent, err := a.storage.ReadVersion(promID, to)
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+
+	changes, err := rule.DiffContent(fromContent, toContent)
+	if err != nil {
+		respondError(w, errorExec, err)
+		return
+	}
+
+	respond(w, &RuleDiff{
+		From:    from,
+		To:      to,
+		Changes: changes,
+	})
+}
+
+// RollbackRequest 回滚请求
+type RollbackRequest struct {
+	Version int64  `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+// RollbackResult 回滚结果, Version 为回滚后生成并固定的版本
+type RollbackResult struct {
+	Version  RuleVersion `json:"version"`
+	Added    []int64     `json:"added"`
+	Removed  []int64     `json:"removed"`
+	Modified []int64     `json:"modified"`
+}
+
+func (a *API) rollback(w http.ResponseWriter, r *http.Request) {
+	promID, err := strconv.ParseInt(r.PathValue("prom_id"), 10, 64)
+	if err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid prom_id: %w", err))
+		return
+	}
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid rollback request: %w", err))
+		return
+	}
+	if req.Version <= 0 {
+		respondError(w, errorBadData, errors.New("version is required"))
+		return
+	}
+
+	manager, ok := a.reloader.Manager(promID)
+	if !ok {
+		respondError(w, errorNotFound, fmt.Errorf("prom %d not found", promID))
+		return
+	}
+
+	v, diff, err := manager.Rollback(req.Version, rule.SourceAPI, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, rule.ErrVersionNotFound):
+			respondError(w, errorNotFound, err)
+		case errors.Is(err, rule.ErrHistoryDisabled):
+			respondError(w, errorBadData, err)
+		default:
+			respondError(w, errorExec, err)
+		}
+		return
+	}
+
+	a.logger.Info("rules rolled back via API",
+		zap.Int64("prom_id", promID),
+		zap.Int64("version", req.Version),
+		zap.String("reason", req.Reason),
+		zap.String("remote_addr", r.RemoteAddr),
+	)
+
+	respond(w, &RollbackResult{
+		Version: RuleVersion{
+			Name:      v.Name,
+			Version:   v.Version,
+			RuleCount: v.RuleCount,
+			CreatedAt: v.CreatedAt,
+			Hash:      v.Hash,
+			Source:    v.Source,
+			Reason:    v.Reason,
+			Pinned:    true,
+		},
+		Added:    diff.Added,
+		Removed:  diff.Removed,
+		Modified: diff.Modified,
+	})
+}
+
+func (a *API) listSilences(w http.ResponseWriter, r *http.Request) {
+	respond(w, a.silences.List())
+}
+
+func (a *API) createSilence(w http.ResponseWriter, r *http.Request) {
+	var sil silence.Silence
+	if err := json.NewDecoder(r.Body).Decode(&sil); err != nil {
+		respondError(w, errorBadData, fmt.Errorf("invalid silence: %w", err))
+		return
+	}
+
+	id, err := a.silences.Create(sil)
+	switch {
+	case errors.Is(err, silence.ErrInvalid):
+		respondError(w, errorBadData, err)
+		return
+	case err != nil:
+		a.logger.Error("failed to create silence", zap.Error(err))
+		respondError(w, errorInternal, err)
+		return
+	}
+
+	respond(w, map[string]string{"silence_id": id})
+}
+
+func (a *API) getSilence(w http.ResponseWriter, r *http.Request) {
+	sil, err := a.silences.Get(r.PathValue("id"))
+	if err != nil {
+		respondError(w, errorNotFound, err)
+		return
+	}
+
+	respond(w, sil)
+}
+
+func (a *API) expireSilence(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := a.silences.Expire(id)
+	switch {
+	case errors.Is(err, silence.ErrNotFound):
+		respondError(w, errorNotFound, err)
+	case errors.Is(err, silence.ErrAlreadyExpired):
+		respondError(w, errorBadData, err)
+	case err != nil:
+		a.logger.Error("failed to expire silence", zap.String("silence_id", id), zap.Error(err))
+		respondError(w, errorInternal, err)
+	default:
+		respond(w, nil)
+	}
+}
@@ -0,0 +1,358 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"alertengine/config"
+	"alertengine/engine"
+
+	config_util "github.com/prometheus/common/config"
+)
+
+// decodeResponse 解析接口响应, 状态码不符时测试失败
+func decodeResponse(t *testing.T, code int, body []byte, wantCode int) map[string]interface{} {
+	t.Helper()
+	if code != wantCode {
+		t.Fatalf("got status %d, want %d: %s", code, wantCode, body)
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", body, err)
+	}
+	return resp
+}
+
+// keys 返回 JSON 对象的字段名, 用于校验响应结构
+func keys(v interface{}) []string {
+	obj, _ := v.(map[string]interface{})
+	var ks []string
+	for k := range obj {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func TestRulesAPI(t *testing.T) {
+	env := newTestEnv(t)
+	env.prom.setResult("up == 0", "0")
+	env.waitFor("alert firing", func() bool {
+		m, _ := env.reloader.Manager(1)
+		alerts := m.ActiveAlerts()
+		return len(alerts) == 1 && alerts[0].State == engine.StateFiring
+	})
+
+	rec := env.do(http.MethodGet, "/api/v1/rules", "")
+	resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	if got := keys(resp); !reflect.DeepEqual(got, []string{"data", "status"}) || resp["status"] != "success" {
+		t.Fatalf("response = %v", resp)
+	}
+	if got := keys(resp["data"]); !reflect.DeepEqual(got, []string{"groups"}) {
+		t.Fatalf("data fields = %v", got)
+	}
+
+	groups := resp["data"].(map[string]interface{})["groups"].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	group := groups[0].(map[string]interface{})
+	wantGroupKeys := []string{"evaluationTime", "file", "interval", "lastEvaluation", "name", "promId", "promUrl", "rules"}
+	if got := keys(group); !reflect.DeepEqual(got, wantGroupKeys) {
+		t.Errorf("group fields = %v, want %v", got, wantGroupKeys)
+	}
+	if group["name"] != "prom_1" || group["file"] != filepath.Join(env.cfg.Storage.RuleDir, "prom_1", "current.yml") || group["promId"] != 1.0 ||
+		group["promUrl"] != env.promURL || group["interval"] != 0.02 {
+		t.Errorf("group = %v", group)
+	}
+
+	rules := group["rules"].([]interface{})
+	if len(rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(rules))
+	}
+	r := rules[0].(map[string]interface{})
+	wantRuleKeys := []string{"alerts", "annotations", "duration", "evaluationTime", "health", "labels", "lastEvaluation", "name", "query", "state", "type"}
+	if got := keys(r); !reflect.DeepEqual(got, wantRuleKeys) {
+		t.Errorf("rule fields = %v, want %v", got, wantRuleKeys)
+	}
+	if r["name"] != "1" || r["state"] != "firing" || r["health"] != "ok" || r["type"] != "alerting" ||
+		r["duration"] != 0.0 || !reflect.DeepEqual(r["labels"], map[string]interface{}{"severity": "critical"}) {
+		t.Errorf("rule = %v", r)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, r["lastEvaluation"].(string)); err != nil {
+		t.Errorf("lastEvaluation: %v", err)
+	}
+
+	alerts := r["alerts"].([]interface{})
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	checkAlert(t, alerts[0])
+
+	// 按规则组和规则名称过滤, 没有结果时返回空数组
+	tests := []struct {
+		query  url.Values
+		groups int
+	}{
+		{url.Values{"rule_group[]": {"prom_1"}}, 1},
+		{url.Values{"rule_group[]": {"prom_2"}}, 0},
+		{url.Values{"rule_name[]": {"1", "2"}}, 1},
+		{url.Values{"rule_name[]": {"2"}}, 0},
+	}
+	for _, tc := range tests {
+		rec := env.do(http.MethodGet, "/api/v1/rules?"+tc.query.Encode(), "")
+		resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+		groups, ok := resp["data"].(map[string]interface{})["groups"].([]interface{})
+		if !ok || len(groups) != tc.groups {
+			t.Errorf("%s: groups = %v, want %d", tc.query.Encode(), resp["data"], tc.groups)
+		}
+	}
+
+	rec = env.do(http.MethodGet, "/api/v1/rules?rule_group[]=%zz", "")
+	resp = decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusBadRequest)
+	if resp["status"] != "error" || resp["errorType"] != "bad_data" {
+		t.Errorf("response = %v", resp)
+	}
+}
+
+func TestAlertsAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	rec := env.do(http.MethodGet, "/api/v1/alerts", "")
+	resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	if alerts, ok := resp["data"].(map[string]interface{})["alerts"].([]interface{}); !ok || len(alerts) != 0 {
+		t.Errorf("data = %v, want no alerts", resp["data"])
+	}
+
+	env.prom.setResult("up == 0", "0")
+	env.waitFor("alert firing", func() bool {
+		m, _ := env.reloader.Manager(1)
+		alerts := m.ActiveAlerts()
+		return len(alerts) == 1 && alerts[0].State == engine.StateFiring
+	})
+
+	rec = env.do(http.MethodGet, "/api/v1/alerts", "")
+	resp = decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	if got := keys(resp["data"]); !reflect.DeepEqual(got, []string{"alerts"}) {
+		t.Fatalf("data fields = %v", got)
+	}
+	alerts := resp["data"].(map[string]interface{})["alerts"].([]interface{})
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	checkAlert(t, alerts[0])
+}
+
+// checkAlert 校验 firing 告警的结构, 字段与 Prometheus 告警接口一致
+func checkAlert(t *testing.T, v interface{}) {
+	t.Helper()
+	alert := v.(map[string]interface{})
+	wantKeys := []string{"activeAt", "annotations", "firedAt", "labels", "state", "value"}
+	if got := keys(alert); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("alert fields = %v, want %v", got, wantKeys)
+	}
+	if alert["state"] != "firing" || alert["value"] != "0e+00" {
+		t.Errorf("alert = %v", alert)
+	}
+	labels, _ := alert["labels"].(map[string]interface{})
+	if labels["__name__"] != "up" || labels["instance"] != "node-1:9100" || labels["severity"] != "critical" {
+		t.Errorf("alert labels = %v", labels)
+	}
+	annotations, _ := alert["annotations"].(map[string]interface{})
+	if annotations["summary"] != "node-1:9100 is down" || annotations["rule_id"] != "1" || annotations["prom_id"] != "1" {
+		t.Errorf("alert annotations = %v", annotations)
+	}
+	for _, k := range []string{"activeAt", "firedAt"} {
+		if _, err := time.Parse(time.RFC3339Nano, alert[k].(string)); err != nil {
+			t.Errorf("%s: %v", k, err)
+		}
+	}
+}
+
+func TestTestRuleAPI(t *testing.T) {
+	env := newTestEnv(t)
+	env.prom.setResult("up == 1", "1")
+
+	body := `{"prom_id": 1, "expr": "up", "op": "==", "value": "1", "labels": {"severity": "warning"}, "summary": "{{ $labels.instance }} is up"}`
+	rec := env.do(http.MethodPost, "/api/v1/rules/test", body)
+	resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	data := resp["data"].(map[string]interface{})
+	if got := keys(data); !reflect.DeepEqual(got, []string{"evaluationTime", "query", "samples", "timestamp"}) || data["query"] != "up == 1" {
+		t.Fatalf("data = %v", data)
+	}
+	samples := data["samples"].([]interface{})
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples, want 1", len(samples))
+	}
+	sample := samples[0].(map[string]interface{})
+	labels, _ := sample["labels"].(map[string]interface{})
+	annotations, _ := sample["annotations"].(map[string]interface{})
+	if sample["value"] != "1e+00" || labels["severity"] != "warning" || annotations["summary"] != "node-1:9100 is up" ||
+		sample["dropped"] != false || sample["inhibited"] != false || sample["silenced"] != false {
+		t.Errorf("sample = %v", sample)
+	}
+
+	// 试运行不改变告警状态, 也不发送通知
+	m, _ := env.reloader.Manager(1)
+	lastEval, _ := m.LastEvaluation()
+	env.waitFor("next evaluations", func() bool {
+		at, _ := m.LastEvaluation()
+		return at.After(lastEval.Add(3 * time.Duration(env.cfg.EvaluationInterval)))
+	})
+	if n := env.gateway.count(); n != 0 {
+		t.Errorf("got %d notifications after a rule test, want 0", n)
+	}
+	if alerts := m.ActiveAlerts(); len(alerts) != 0 {
+		t.Errorf("got active alerts %v after a rule test", alerts)
+	}
+
+	// 已加载的规则触发时正常发送通知
+	env.prom.setResult("up == 0", "0")
+	env.waitFor("notification", func() bool { return env.gateway.count() > 0 })
+}
+
+func TestTestRuleAPIErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name      string
+		body      string
+		code      int
+		errorType string
+	}{
+		{"invalid json", `{"prom_id": `, http.StatusBadRequest, "bad_data"},
+		{"unknown prom", `{"prom_id": 2, "expr": "up"}`, http.StatusNotFound, "not_found"},
+		{"missing expr", `{"prom_id": 1}`, http.StatusBadRequest, "bad_data"},
+		{"invalid for", `{"prom_id": 1, "expr": "up", "for": "soon"}`, http.StatusBadRequest, "bad_data"},
+	}
+	for _, tc := range tests {
+		rec := env.do(http.MethodPost, "/api/v1/rules/test", tc.body)
+		if rec.Code != tc.code {
+			t.Errorf("%s: got status %d, want %d: %s", tc.name, rec.Code, tc.code, rec.Body)
+			continue
+		}
+		resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), tc.code)
+		if resp["status"] != "error" || resp["errorType"] != tc.errorType {
+			t.Errorf("%s: response = %v", tc.name, resp)
+		}
+	}
+	if rec := env.do(http.MethodGet, "/api/v1/rules/test", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %d, want 405", rec.Code)
+	}
+}
+
+func TestSilencesAPI(t *testing.T) {
+	env := newTestEnv(t)
+	startsAt := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	endsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	silenceBody := func(matchers string) string {
+		return `{"matchers": ` + matchers + `, "starts_at": "` + startsAt + `", "ends_at": "` + endsAt + `", "created_by": "admin", "comment": "maintenance"}`
+	}
+
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"invalid json", `{"matchers": `, "invalid silence: "},
+		{"null matcher", silenceBody(`[null]`), "matcher 0 is empty"},
+		{"no matchers", silenceBody(`[]`), "at least one matcher is required"},
+		{"invalid matcher type", silenceBody(`[{"name": "instance", "value": "node-1", "type": "~"}]`), "invalid silence: "},
+		{"matches empty string", silenceBody(`[{"name": "instance", "value": ".*", "type": "=~"}]`), "must not match the empty string"},
+		{"missing comment", `{"matchers": [{"name": "instance", "value": "node-1", "type": "="}], "starts_at": "` + startsAt + `", "ends_at": "` + endsAt + `", "created_by": "admin"}`, "comment cannot be empty"},
+	}
+	for _, tc := range tests {
+		rec := env.do(http.MethodPost, "/api/v1/silences", tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400: %s", tc.name, rec.Code, rec.Body)
+			continue
+		}
+		resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusBadRequest)
+		if resp["errorType"] != "bad_data" || !strings.Contains(resp["error"].(string), tc.err) {
+			t.Errorf("%s: response = %v, want error containing %q", tc.name, resp, tc.err)
+		}
+	}
+	if n := len(env.silences.List()); n != 0 {
+		t.Fatalf("got %d silences after invalid requests, want 0", n)
+	}
+
+	rec := env.do(http.MethodPost, "/api/v1/silences", silenceBody(`[{"name": "instance", "value": "node-1:9100", "type": "="}]`))
+	resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	id, _ := resp["data"].(map[string]interface{})["silence_id"].(string)
+	if id == "" {
+		t.Fatalf("response = %v, want a silence id", resp)
+	}
+
+	rec = env.do(http.MethodGet, "/api/v1/silences/"+id, "")
+	resp = decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	if sil := resp["data"].(map[string]interface{}); sil["id"] != id || sil["status"] != "active" {
+		t.Errorf("silence = %v", sil)
+	}
+
+	// 过期不存在或已过期的静默分别返回 404 和 400
+	steps := []struct {
+		method    string
+		id        string
+		code      int
+		errorType string
+	}{
+		{http.MethodGet, "missing", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, "missing", http.StatusNotFound, "not_found"},
+		{http.MethodDelete, id, http.StatusOK, ""},
+		{http.MethodDelete, id, http.StatusBadRequest, "bad_data"},
+	}
+	for _, s := range steps {
+		rec := env.do(s.method, "/api/v1/silences/"+s.id, "")
+		resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), s.code)
+		if errorType, _ := resp["errorType"].(string); errorType != s.errorType {
+			t.Errorf("%s %s: response = %v, want error type %q", s.method, s.id, resp, s.errorType)
+		}
+	}
+
+	rec = env.do(http.MethodGet, "/api/v1/silences/"+id, "")
+	resp = decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	if sil := resp["data"].(map[string]interface{}); sil["status"] != "expired" {
+		t.Errorf("silence = %v, want expired", sil)
+	}
+}
+
+func TestConfigAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	secrets := []string{"gateway-token", "datasource-bearer", "datasource-password", "header-value", "hmac-secret", "bcrypt-hash"}
+	cfg := *env.cfg
+	cfg.Gateway.Auth = &config.GatewayAuth{HMAC: &config.HMACConfig{Secret: "hmac-secret"}}
+	cfg.DatasourceAuth.Default = &config.DatasourceAuth{
+		BearerToken: "datasource-bearer",
+		Headers:     map[string]config_util.Secret{"X-Scope-OrgID": "header-value"},
+	}
+	cfg.DatasourceAuth.Proms = map[int64]*config.DatasourceAuth{
+		2: {BasicAuth: &config.BasicAuth{Username: "prometheus", Password: "datasource-password"}},
+	}
+	cfg.Web.BasicAuthUsers = map[string]config_util.Secret{"admin": "bcrypt-hash"}
+	if err := env.reloader.ApplyConfig(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.do(http.MethodGet, "/api/v1/status/config", "")
+	resp := decodeResponse(t, rec.Code, rec.Body.Bytes(), http.StatusOK)
+	yml, _ := resp["data"].(map[string]interface{})["yaml"].(string)
+	for _, s := range secrets {
+		if strings.Contains(yml, s) {
+			t.Errorf("config contains secret %q:\n%s", s, yml)
+		}
+	}
+	// 非敏感字段正常展示
+	for _, s := range []string{"username: prometheus", "X-Scope-OrgID: <secret>", "admin: <secret>", "auth_token: <secret>"} {
+		if !strings.Contains(yml, s) {
+			t.Errorf("config does not contain %q:\n%s", s, yml)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+
+	config_util "github.com/prometheus/common/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash 用于不存在的用户, 使校验耗时与存在的用户一致
+var dummyHash = []byte("$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi")
+
+// maxAuthCacheSize 认证结果缓存的最大条目数
+const maxAuthCacheSize = 1024
+
+// basicAuthHandler 使用 bcrypt 哈希校验 Basic 认证, 缓存校验结果避免每次请求都计算 bcrypt
+type basicAuthHandler struct {
+	handler http.Handler
+	users   map[string]config_util.Secret
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]bool
+}
+
+func newBasicAuthHandler(handler http.Handler, users map[string]config_util.Secret) *basicAuthHandler {
+	return &basicAuthHandler{
+		handler: handler,
+		users:   users,
+		cache:   make(map[[sha256.Size]byte]bool),
+	}
+}
+
+func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || !h.authenticate(user, pass) {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *basicAuthHandler) authenticate(user, pass string) bool {
+	hash, exists := h.users[user]
+	hashed := []byte(hash)
+	if !exists {
+		hashed = dummyHash
+	}
+
+	key := sha256.Sum256([]byte(user + "\xff" + string(hash) + "\xff" + pass))
+
+	h.mu.Lock()
+	authOK, cached := h.cache[key]
+	h.mu.Unlock()
+
+	if !cached {
+		authOK = bcrypt.CompareHashAndPassword(hashed, []byte(pass)) == nil && exists
+
+		h.mu.Lock()
+		if len(h.cache) >= maxAuthCacheSize {
+			h.cache = make(map[[sha256.Size]byte]bool)
+		}
+		h.cache[key] = authOK
+		h.mu.Unlock()
+	}
+
+	return authOK && exists
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config_util "github.com/prometheus/common/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testBasicAuthUsers(t *testing.T) map[string]config_util.Secret {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]config_util.Secret{"admin": config_util.Secret(hash)}
+}
+
+func TestBasicAuthHandler(t *testing.T) {
+	h := newBasicAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), testBasicAuthUsers(t))
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		code     int
+	}{
+		{name: "no credentials", noAuth: true, code: http.StatusUnauthorized},
+		{name: "wrong password", user: "admin", password: "wrong", code: http.StatusUnauthorized},
+		{name: "unknown user", user: "guest", password: "secret", code: http.StatusUnauthorized},
+		{name: "valid", user: "admin", password: "secret", code: http.StatusOK},
+		// 第二次使用缓存的校验结果
+		{name: "valid cached", user: "admin", password: "secret", code: http.StatusOK},
+		{name: "wrong password cached", user: "admin", password: "wrong", code: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/rules", nil)
+		if !tc.noAuth {
+			req.SetBasicAuth(tc.user, tc.password)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tc.code {
+			t.Errorf("%s: got status %d, want %d", tc.name, rec.Code, tc.code)
+		}
+		if tc.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Basic" {
+			t.Errorf("%s: missing WWW-Authenticate header", tc.name)
+		}
+	}
+	if len(h.cache) != 3 {
+		t.Errorf("got %d cached results, want 3", len(h.cache))
+	}
+}
+
+func TestBasicAuthCacheLimit(t *testing.T) {
+	h := newBasicAuthHandler(http.NotFoundHandler(), testBasicAuthUsers(t))
+	for i := 0; i < maxAuthCacheSize; i++ {
+		h.cache[[32]byte{byte(i), byte(i >> 8)}] = false
+	}
+
+	// 缓存满后清空, 不会无限增长
+	if !h.authenticate("admin", "secret") {
+		t.Fatal("valid credentials rejected")
+	}
+	if len(h.cache) != 1 {
+		t.Errorf("got %d cached results, want 1", len(h.cache))
+	}
+}
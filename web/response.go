@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type status string
+
+const (
+	statusSuccess status = "success"
+	statusError   status = "error"
+)
+
+type errorType string
+
+const (
+	errorBadData  errorType = "bad_data"
+	errorNotFound errorType = "not_found"
+	errorExec     errorType = "execution"
+	errorInternal errorType = "internal"
+)
+
+// response 接口响应, 与 Prometheus HTTP API 的格式保持一致
+type response struct {
+	Status    status      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType errorType   `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+func respond(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, &response{
+		Status: statusSuccess,
+		Data:   data,
+	})
+}
+
+func respondError(w http.ResponseWriter, typ errorType, err error) {
+	code := http.StatusInternalServerError
+	switch typ {
+	case errorBadData:
+		code = http.StatusBadRequest
+	case errorNotFound:
+		code = http.StatusNotFound
+	case errorExec:
+		code = http.StatusUnprocessableEntity
+	}
+
+	writeJSON(w, code, &response{
+		Status:    statusError,
+		ErrorType: typ,
+		Error:     err.Error(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
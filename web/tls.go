@@ -0,0 +1,167 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"alertengine/config"
+
+	"go.uber.org/zap"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"":      tls.VersionTLS12,
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+// ListenAndServe 按 web 配置启动HTTP服务, 启用 TLS 与 Basic 认证
+func ListenAndServe(srv *http.Server, cfg config.WebConfig, logger *zap.Logger) error {
+	if len(cfg.BasicAuthUsers) > 0 {
+		srv.Handler = newBasicAuthHandler(srv.Handler, cfg.BasicAuthUsers)
+	}
+
+	if cfg.TLSServerConfig == nil {
+		logger.Info("TLS is disabled", zap.String("addr", srv.Addr))
+		return srv.ListenAndServe()
+	}
+
+	reloader := &certReloader{cfg: *cfg.TLSServerConfig, logger: logger}
+	if _, err := reloader.getConfig(); err != nil {
+		return err
+	}
+
+	srv.TLSConfig = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.getConfig()
+		},
+	}
+
+	logger.Info("TLS is enabled", zap.String("addr", srv.Addr))
+	return srv.ListenAndServeTLS("", "")
+}
+
+// certReloader 在证书文件变更时重新加载 TLS 配置, 加载失败时继续使用旧配置
+type certReloader struct {
+	cfg    config.TLSServerConfig
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	tlsCfg  *tls.Config
+	modTime map[string]time.Time
+}
+
+func (r *certReloader) getConfig() (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := r.stat()
+	if err != nil {
+		if r.tlsCfg != nil {
+			r.logger.Warn("failed to stat TLS files, keeping previous config", zap.Error(err))
+			return r.tlsCfg, nil
+		}
+		return nil, err
+	}
+
+	if r.tlsCfg != nil && !r.changed(modTime) {
+		return r.tlsCfg, nil
+	}
+
+	tlsCfg, err := r.load()
+	if err != nil {
+		if r.tlsCfg != nil {
+			r.logger.Warn("failed to reload TLS config, keeping previous config", zap.Error(err))
+			return r.tlsCfg, nil
+		}
+		return nil, err
+	}
+
+	if r.tlsCfg != nil {
+		r.logger.Info("TLS config reloaded", zap.String("cert_file", r.cfg.CertFile))
+	}
+	r.tlsCfg = tlsCfg
+	r.modTime = modTime
+	return r.tlsCfg, nil
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (r *certReloader) stat() (map[string]time.Time, error) {
+	modTime := make(map[string]time.Time)
+	for _, f := range r.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		modTime[f] = fi.ModTime()
+	}
+	return modTime, nil
+}
+
+func (r *certReloader) changed(modTime map[string]time.Time) bool {
+	for f, t := range modTime {
+		if !t.Equal(r.modTime[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load X509KeyPair: %w", err)
+	}
+
+	clientAuth, ok := clientAuthTypes[r.cfg.ClientAuthType]
+	if !ok {
+		return nil, fmt.Errorf("invalid client_auth_type %q", r.cfg.ClientAuthType)
+	}
+	minVersion, ok := tlsVersions[r.cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid min_version %q", r.cfg.MinVersion)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		MinVersion:   minVersion,
+	}
+
+	if r.cfg.ClientCAFile != "" {
+		ca, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificates found in client_ca_file %s", r.cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+	}
+
+	return tlsCfg, nil
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"alertengine/config"
+
+	"go.uber.org/zap"
+)
+
+// writeTestCert 生成 127.0.0.1 的自签名证书, 返回证书文件、私钥文件路径和证书
+func writeTestCert(t *testing.T, dir, name string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+// freeAddr 返回一个空闲的本地监听地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startTestServer 使用 ListenAndServe 启动服务, 等待端口可以连接
+func startTestServer(t *testing.T, cfg config.WebConfig) string {
+	t.Helper()
+	addr := freeAddr(t)
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}),
+		// 握手失败是预期的结果, 不输出日志
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- ListenAndServe(srv, cfg, zap.NewNop()) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("ListenAndServe: %v", err)
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server %s did not start", addr)
+	return ""
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCert(t, dir, "server")
+	clientCert, clientKey, _ := writeTestCert(t, dir, "client")
+
+	addr := startTestServer(t, config.WebConfig{
+		TLSServerConfig: &config.TLSServerConfig{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ClientAuthType: "RequireAndVerifyClientCert",
+			ClientCAFile:   clientCert,
+			MinVersion:     "TLS13",
+		},
+		BasicAuthUsers: testBasicAuthUsers(t),
+	})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(tlsCfg *tls.Config, auth bool) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}, Timeout: 5 * time.Second}
+		req, _ := http.NewRequest(http.MethodGet, "https://"+addr+"/", nil)
+		if auth {
+			req.SetBasicAuth("admin", "secret")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+
+	if code, err := get(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{pair}}, true); err != nil || code != http.StatusOK {
+		t.Errorf("got %d, %v, want 200", code, err)
+	}
+	// TLS 之上同样需要 Basic 认证
+	if code, err := get(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{pair}}, false); err != nil || code != http.StatusUnauthorized {
+		t.Errorf("got %d, %v without basic auth, want 401", code, err)
+	}
+	if _, err := get(&tls.Config{RootCAs: pool}, true); err == nil {
+		t.Error("request without client certificate succeeded")
+	}
+	if _, err := get(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{pair}, MaxVersion: tls.VersionTLS12}, true); err == nil {
+		t.Error("request with TLS 1.2 succeeded, want min_version TLS13 enforced")
+	}
+}
+
+func TestListenAndServeTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCert(t, dir, "server")
+
+	tests := []struct {
+		name string
+		cfg  config.TLSServerConfig
+		err  string
+	}{
+		{"missing cert", config.TLSServerConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile}, "no such file"},
+		{"key mismatch", config.TLSServerConfig{CertFile: certFile, KeyFile: certFile}, "failed to load X509KeyPair"},
+		{"client auth type", config.TLSServerConfig{CertFile: certFile, KeyFile: keyFile, ClientAuthType: "Always"}, `invalid client_auth_type "Always"`},
+		{"min version", config.TLSServerConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "TLS9"}, `invalid min_version "TLS9"`},
+		{"client ca", config.TLSServerConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, "no valid certificates found"},
+	}
+
+	for _, tc := range tests {
+		cfg := tc.cfg
+		srv := &http.Server{Addr: freeAddr(t), Handler: http.NotFoundHandler()}
+		err := ListenAndServe(srv, config.WebConfig{TLSServerConfig: &cfg}, zap.NewNop())
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCert(t, dir, "server")
+	r := &certReloader{cfg: config.TLSServerConfig{CertFile: certFile, KeyFile: keyFile}, logger: zap.NewNop()}
+
+	first, err := r.getConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := r.getConfig(); again != first {
+		t.Error("config reloaded without file changes")
+	}
+
+	// 证书文件变更后重新加载
+	newCert, newKey, cert := writeTestCert(t, t.TempDir(), "server")
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(dst, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second, err := r.getConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || !bytes.Equal(second.Certificates[0].Certificate[0], cert.Raw) {
+		t.Error("config not reloaded after the certificate changed")
+	}
+
+	// 加载失败时继续使用旧配置
+	if err := os.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(2 * time.Minute)
+	os.Chtimes(keyFile, later, later)
+	if got, err := r.getConfig(); err != nil || got != second {
+		t.Errorf("got %v, %v, want the previous config", got, err)
+	}
+	os.Remove(certFile)
+	if got, err := r.getConfig(); err != nil || got != second {
+		t.Errorf("got %v, %v after the certificate was removed, want the previous config", got, err)
+	}
+}
@@ -0,0 +1,23 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFS 内嵌的 Web UI 静态资源, 不依赖外部 CDN
+//
+//go:embed ui/static
+var uiFS embed.FS
+
+// registerUI 注册 Web UI 路由
+func (h *Handler) registerUI(mux *http.ServeMux) {
+	static, err := fs.Sub(uiFS, "ui/static")
+	if err != nil {
+		panic(err)
+	}
+
+	mux.Handle("GET /ui/", http.StripPrefix("/ui/", http.FileServer(http.FS(static))))
+	mux.Handle("GET /{$}", http.RedirectHandler("ui/", http.StatusFound))
+}
@@ -0,0 +1,430 @@
+(function () {
+  'use strict';
+
+  // 页面挂载在 /ui/ 下, 使用相对路径访问接口以兼容反向代理前缀
+  var API = '../api/v1';
+
+  var state = {
+    tab: 'managers',
+    groups: [],
+    alerts: [],
+    silences: [],
+    versions: []
+  };
+
+  function $(id) { return document.getElementById(id); }
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === 'text') node.textContent = attrs[k];
+      else if (k === 'class') node.className = attrs[k];
+      else if (k.indexOf('on') === 0) node.addEventListener(k.slice(2), attrs[k]);
+      else node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) {
+      if (c == null) return;
+      node.appendChild(typeof c === 'string' ? document.createTextNode(c) : c);
+    });
+    return node;
+  }
+
+  function td(content) {
+    if (content instanceof Node) return el('td', {}, [content]);
+    return el('td', { text: content == null ? '' : String(content) });
+  }
+
+  function fill(tbody, rows, cols) {
+    tbody.textContent = '';
+    if (rows.length === 0) {
+      tbody.appendChild(el('tr', {}, [el('td', { colspan: cols, class: 'muted', text: '无数据' })]));
+      return;
+    }
+    rows.forEach(function (r) { tbody.appendChild(r); });
+  }
+
+  function request(method, path, body) {
+    var opts = { method: method, headers: {} };
+    if (body !== undefined) {
+      opts.headers['Content-Type'] = 'application/json';
+      opts.body = JSON.stringify(body);
+    }
+    return fetch(API + path, opts).then(function (resp) {
+      return resp.json().then(function (res) {
+        if (res.status !== 'success') throw new Error(res.error || resp.statusText);
+        return res.data;
+      });
+    });
+  }
+
+  function showError(err) {
+    var box = $('error');
+    if (!err) { box.hidden = true; return; }
+    box.textContent = String(err.message || err);
+    box.hidden = false;
+  }
+
+  function badge(value) {
+    return el('span', { class: 'state state-' + value, text: value });
+  }
+
+  function labelList(labels) {
+    var span = el('span');
+    Object.keys(labels || {}).sort().forEach(function (k) {
+      span.appendChild(el('span', { class: 'label', text: k + '="' + labels[k] + '"' }));
+    });
+    return span;
+  }
+
+  function labelText(labels) {
+    return Object.keys(labels || {}).map(function (k) { return k + '=' + labels[k]; }).join(' ');
+  }
+
+  function fmtTime(t) {
+    if (!t || t.indexOf('0001-') === 0) return '-';
+    return new Date(t).toLocaleString();
+  }
+
+  function fmtSeconds(s) {
+    if (!s) return '-';
+    if (s < 1) return (s * 1000).toFixed(1) + 'ms';
+    return s.toFixed(2) + 's';
+  }
+
+  function fmtDuration(s) {
+    if (!s) return '0s';
+    var parts = [];
+    [['h', 3600], ['m', 60], ['s', 1]].forEach(function (u) {
+      var n = Math.floor(s / u[1]);
+      if (n > 0) { parts.push(n + u[0]); s -= n * u[1]; }
+    });
+    return parts.join('') || '0s';
+  }
+
+  // ---- 数据源 ----
+
+  function renderManagers() {
+    var rows = state.groups.map(function (g) {
+      var firing = 0, pending = 0, errs = 0;
+      g.rules.forEach(function (r) {
+        if (r.state === 'firing') firing++;
+        if (r.state === 'pending') pending++;
+        if (r.health === 'err') errs++;
+      });
+      return el('tr', {}, [
+        td(g.promId), td(g.promUrl), td(g.rules.length),
+        td(firing), td(pending), td(errs),
+        td(fmtDuration(g.interval)), td(fmtTime(g.lastEvaluation)), td(fmtSeconds(g.evaluationTime))
+      ]);
+    });
+    fill($('managers-body'), rows, 9);
+  }
+
+  // ---- 规则 ----
+
+  function renderRules() {
+    var prom = $('rules-prom').value;
+    var st = $('rules-state').value;
+    var health = $('rules-health').value;
+    var q = $('rules-search').value.trim().toLowerCase();
+
+    var rows = [];
+    state.groups.forEach(function (g) {
+      if (prom && String(g.promId) !== prom) return;
+      g.rules.forEach(function (r) {
+        if (st && r.state !== st) return;
+        if (health && r.health !== health) return;
+        if (q && (r.name + ' ' + r.query + ' ' + labelText(r.labels)).toLowerCase().indexOf(q) < 0) return;
+        var healthCell = el('td', {}, [badge(r.health)]);
+        if (r.lastError) healthCell.title = r.lastError;
+        rows.push(el('tr', {}, [
+          td(g.promId), td(r.name), td(el('code', { text: r.query })),
+          td(fmtDuration(r.duration)), td(labelList(r.labels)),
+          td(badge(r.state)), healthCell,
+          td(fmtTime(r.lastEvaluation)), td(fmtSeconds(r.evaluationTime))
+        ]));
+      });
+    });
+    fill($('rules-body'), rows, 9);
+  }
+
+  function updatePromOptions() {
+    [$('rules-prom'), $('history-prom')].forEach(function (sel) {
+      var current = sel.value;
+      var keepAll = sel.id === 'rules-prom';
+      sel.textContent = '';
+      if (keepAll) sel.appendChild(el('option', { value: '', text: '全部数据源' }));
+      state.groups.forEach(function (g) {
+        sel.appendChild(el('option', { value: String(g.promId), text: g.promId + ' - ' + g.promUrl }));
+      });
+      if (current) sel.value = current;
+    });
+  }
+
+  // ---- 告警 ----
+
+  function renderAlerts() {
+    var st = $('alerts-state').value;
+    var q = $('alerts-search').value.trim().toLowerCase();
+    var rows = state.alerts.filter(function (a) {
+      if (st && a.state !== st) return false;
+      return !q || labelText(a.labels).toLowerCase().indexOf(q) >= 0;
+    }).map(function (a) {
+      var silenceBtn = el('button', {
+        type: 'button', text: '静默',
+        onclick: function () { prefillSilence(a.labels); }
+      });
+      return el('tr', {}, [
+        td(badge(a.state)), td(labelList(a.labels)),
+        td((a.annotations && (a.annotations.summary || a.annotations.description)) || ''),
+        td(a.value), td(fmtTime(a.activeAt)), td(fmtTime(a.firedAt)), td(silenceBtn)
+      ]);
+    });
+    fill($('alerts-body'), rows, 7);
+  }
+
+  // ---- 静默 ----
+
+  function matcherText(m) {
+    return m.name + m.type + JSON.stringify(m.value);
+  }
+
+  function renderSilences() {
+    var st = $('silences-status').value;
+    var rows = state.silences.filter(function (s) {
+      return !st || s.status === st;
+    }).map(function (s) {
+      var action = s.status === 'expired' ? td('') : td(el('button', {
+        type: 'button', text: '使失效',
+        onclick: function () { expireSilence(s.id); }
+      }));
+      var matchers = el('span');
+      (s.matchers || []).forEach(function (m) {
+        matchers.appendChild(el('span', { class: 'label', text: matcherText(m) }));
+      });
+      return el('tr', {}, [
+        td(badge(s.status)), td(matchers), td(fmtTime(s.starts_at)), td(fmtTime(s.ends_at)),
+        td(s.created_by), td(s.comment), action
+      ]);
+    });
+    fill($('silences-body'), rows, 7);
+  }
+
+  // parseMatchers 解析 {name="value", name=~"regex"} 格式的匹配器, 与服务端 ParseMatchers 的语法保持一致
+  function parseMatchers(text) {
+    var s = text.trim();
+    if (s.charAt(0) === '{') {
+      if (s.charAt(s.length - 1) !== '}') throw new Error('匹配器缺少右括号');
+      s = s.slice(1, -1);
+    }
+    var re = /^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*"|`[^`]*`|[^,]*?)\s*(,|$)/;
+    var out = [];
+    while (s.trim() !== '') {
+      var m = re.exec(s);
+      if (!m) throw new Error('无法解析匹配器: ' + s);
+      var value = m[3];
+      if (value.charAt(0) === '"') value = JSON.parse(value);
+      else if (value.charAt(0) === '`') value = value.slice(1, -1);
+      out.push({ name: m[1], type: m[2], value: value });
+      s = s.slice(m[0].length);
+    }
+    if (out.length === 0) throw new Error('至少需要一个匹配器');
+    return out;
+  }
+
+  function toLocalInput(d) {
+    var pad = function (n) { return (n < 10 ? '0' : '') + n; };
+    return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) +
+      'T' + pad(d.getHours()) + ':' + pad(d.getMinutes());
+  }
+
+  function prefillSilence(labels) {
+    var form = $('silence-form');
+    form.matchers.value = '{' + Object.keys(labels).sort().map(function (k) {
+      return k + '=' + JSON.stringify(labels[k]);
+    }).join(', ') + '}';
+    form.starts_at.value = toLocalInput(new Date());
+    form.ends_at.value = toLocalInput(new Date(Date.now() + 2 * 3600 * 1000));
+    location.hash = '#silences';
+    form.comment.focus();
+  }
+
+  function createSilence(ev) {
+    ev.preventDefault();
+    var form = ev.target;
+    var body;
+    try {
+      body = {
+        matchers: parseMatchers(form.matchers.value),
+        starts_at: form.starts_at.value ? new Date(form.starts_at.value).toISOString() : new Date().toISOString(),
+        ends_at: new Date(form.ends_at.value).toISOString(),
+        created_by: form.created_by.value,
+        comment: form.comment.value
+      };
+    } catch (err) {
+      showError(err);
+      return;
+    }
+    request('POST', '/silences', body).then(function () {
+      form.reset();
+      showError(null);
+      return loadSilences();
+    }).catch(showError);
+  }
+
+  function expireSilence(id) {
+    if (!window.confirm('确认使该静默失效?')) return;
+    request('DELETE', '/silences/' + encodeURIComponent(id)).then(loadSilences).catch(showError);
+  }
+
+  // ---- 规则历史 ----
+
+  function renderVersions() {
+    var rows = state.versions.map(function (v, i) {
+      var check = el('input', { type: 'checkbox', value: v.version, onchange: updateDiffButton });
+      var link = el('a', {
+        href: '#history', text: v.name,
+        onclick: function (ev) { ev.preventDefault(); showVersion(v.version); }
+      });
+      var label = v.version + (v.pinned ? ' (已固定)' : i === 0 ? ' (最新)' : '');
+      var action = i === 0 ? '' : el('button', {
+        type: 'button', text: '回滚',
+        onclick: function () { rollback(v.version); }
+      });
+      return el('tr', {}, [
+        td(check), td(label), td(link), td(v.ruleCount), td(v.source), td(v.reason || ''),
+        td(fmtTime(v.createdAt)), td(el('code', { text: (v.hash || '').slice(0, 12) })), td(action)
+      ]);
+    });
+    fill($('history-body'), rows, 9);
+    updateDiffButton();
+  }
+
+  function rollback(version) {
+    // 回滚后的版本会一直固定, 直到网关规则发生变化
+    var reason = window.prompt('回滚到版本 ' + version + ', 该版本将固定到网关规则下次变化为止。回滚原因:');
+    if (reason === null) return;
+    request('POST', '/history/' + historyProm() + '/rollback', { version: version, reason: reason }).then(function () {
+      showError(null);
+      return loadHistory();
+    }).catch(showError);
+  }
+
+  function selectedVersions() {
+    return Array.prototype.slice.call($('history-body').querySelectorAll('input:checked')).map(function (c) {
+      return c.value;
+    });
+  }
+
+  function updateDiffButton() {
+    $('history-diff').disabled = selectedVersions().length !== 2;
+  }
+
+  function historyProm() {
+    return encodeURIComponent($('history-prom').value);
+  }
+
+  function showVersion(version) {
+    request('GET', '/history/' + historyProm() + '/content?version=' + encodeURIComponent(version)).then(function (data) {
+      $('history-view').textContent = data.content;
+    }).catch(showError);
+  }
+
+  function showDiff() {
+    // 版本列表按版本号倒序, 较旧的版本作为比较基准
+    var sel = selectedVersions();
+    var from = sel[1], to = sel[0];
+    var path = '/history/' + historyProm() + '/diff?from=' + encodeURIComponent(from) + '&to=' + encodeURIComponent(to);
+    request('GET', path).then(function (data) {
+      var view = $('history-view');
+      view.textContent = '';
+      view.appendChild(el('span', { class: 'diff-equal', text: '--- ' + data.from + '\n+++ ' + data.to }));
+      data.lines.forEach(function (l) {
+        var prefix = l.op === 'insert' ? '+ ' : l.op === 'delete' ? '- ' : '  ';
+        view.appendChild(el('span', { class: 'diff-' + l.op, text: prefix + l.text }));
+      });
+    }).catch(showError);
+  }
+
+  // ---- 数据加载 ----
+
+  function loadRules() {
+    return request('GET', '/rules').then(function (data) {
+      state.groups = data.groups || [];
+      updatePromOptions();
+      renderManagers();
+      renderRules();
+    });
+  }
+
+  function loadAlerts() {
+    return request('GET', '/alerts').then(function (data) {
+      state.alerts = data.alerts || [];
+      renderAlerts();
+    });
+  }
+
+  function loadSilences() {
+    return request('GET', '/silences').then(function (data) {
+      state.silences = data || [];
+      renderSilences();
+    });
+  }
+
+  function loadHistory() {
+    var chain = state.groups.length ? Promise.resolve() : loadRules();
+    return chain.then(function () {
+      if (!$('history-prom').value) {
+        state.versions = [];
+        renderVersions();
+        return;
+      }
+      return request('GET', '/history/' + historyProm()).then(function (data) {
+        state.versions = data || [];
+        $('history-view').textContent = '';
+        renderVersions();
+      });
+    });
+  }
+
+  var loaders = {
+    managers: loadRules,
+    rules: loadRules,
+    alerts: loadAlerts,
+    silences: loadSilences,
+    history: loadHistory
+  };
+
+  function refresh() {
+    showError(null);
+    loaders[state.tab]().catch(showError);
+  }
+
+  function switchTab() {
+    var tab = location.hash.slice(1);
+    if (!loaders[tab]) tab = 'managers';
+    state.tab = tab;
+    Array.prototype.forEach.call(document.querySelectorAll('.tab'), function (s) {
+      s.hidden = s.id !== 'tab-' + tab;
+    });
+    Array.prototype.forEach.call(document.querySelectorAll('#tabs a'), function (a) {
+      a.classList.toggle('active', a.getAttribute('data-tab') === tab);
+    });
+    refresh();
+  }
+
+  ['rules-prom', 'rules-state', 'rules-health', 'rules-search'].forEach(function (id) {
+    $(id).addEventListener('input', renderRules);
+  });
+  ['alerts-state', 'alerts-search'].forEach(function (id) {
+    $(id).addEventListener('input', renderAlerts);
+  });
+  $('silences-status').addEventListener('input', renderSilences);
+  $('silence-form').addEventListener('submit', createSilence);
+  $('history-prom').addEventListener('change', function () { loadHistory().catch(showError); });
+  $('history-diff').addEventListener('click', showDiff);
+  $('refresh').addEventListener('click', refresh);
+  window.addEventListener('hashchange', switchTab);
+
+  switchTab();
+})();
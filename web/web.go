@@ -0,0 +1,179 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"alertengine/config"
+	"alertengine/engine"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Handler 告警引擎HTTP服务, 按接口角色监听不同地址
+type Handler struct {
+	config       *config.Config
+	reloader     *engine.Reloader
+	reloadConfig func() error
+	api          *API
+	logger       *zap.Logger
+}
+
+// New 创建HTTP服务, reloadConfig 在 /-/reload 时重新加载配置文件, 为空时仅重载规则
+func New(cfg *config.Config, reloader *engine.Reloader, reloadConfig func() error, api *API, logger *zap.Logger) *Handler {
+	return &Handler{
+		config:       cfg,
+		reloader:     reloader,
+		reloadConfig: reloadConfig,
+		api:          api,
+		logger:       logger,
+	}
+}
+
+// Run 启动HTTP服务, ctx 取消后优雅退出
+func (h *Handler) Run(ctx context.Context) error {
+	muxes := h.muxes()
+
+	addrs := make([]string, 0, len(muxes))
+	for addr := range muxes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	servers := make([]*http.Server, 0, len(addrs))
+	errc := make(chan error, len(addrs))
+	for _, addr := range addrs {
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           muxes[addr],
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		servers = append(servers, srv)
+
+		h.logger.Info("starting web server", zap.String("addr", addr))
+		go func() {
+			if err := ListenAndServe(srv, h.config.Web, h.logger); !errors.Is(err, http.ErrServerClosed) {
+				errc <- err
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+	}
+
+	h.shutdown(servers)
+	return err
+}
+
+// shutdown 等待正在处理的请求完成后关闭所有监听
+func (h *Handler) shutdown(servers []*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.config.Web.ShutdownTimeout))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				h.logger.Error("web server shutdown failed", zap.String("addr", srv.Addr), zap.Error(err))
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	h.logger.Info("web server stopped")
+}
+
+// muxes 按监听地址组装路由
+func (h *Handler) muxes() map[string]*http.ServeMux {
+	addrs := h.config.ListenAddresses()
+	muxes := map[string]*http.ServeMux{}
+	mux := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+
+	h.api.Register(mux(addrs.API))
+	h.registerUI(mux(addrs.API))
+	h.registerMetrics(mux(addrs.Metrics))
+	h.registerHealth(mux(addrs.Health))
+	h.registerAdmin(mux(addrs.Admin))
+
+	return muxes
+}
+
+func (h *Handler) registerMetrics(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", promhttp.Handler())
+}
+
+func (h *Handler) registerHealth(mux *http.ServeMux) {
+	mux.HandleFunc("GET /-/healthy", h.healthy)
+	mux.HandleFunc("GET /-/ready", h.ready)
+
+	// 兼容旧版本的健康检查路径
+	mux.HandleFunc("GET /health", h.healthy)
+	mux.HandleFunc("GET /ready", h.ready)
+}
+
+func (h *Handler) registerAdmin(mux *http.ServeMux) {
+	mux.HandleFunc("POST /-/reload", h.reload)
+	mux.HandleFunc("PUT /-/reload", h.reload)
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// 网关不可用时使用快照中的规则仍然可以正常评估, 健康检查返回成功并说明处于降级状态
+func (h *Handler) healthy(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("AlertEngine is Healthy.\n"))
+	h.writeDegraded(w)
+}
+
+func (h *Handler) ready(w http.ResponseWriter, r *http.Request) {
+	if h.reloader.GetManagerCount() > 0 {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("AlertEngine is Ready.\n"))
+		h.writeDegraded(w)
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte("AlertEngine is not ready.\n"))
+}
+
+func (h *Handler) writeDegraded(w http.ResponseWriter) {
+	if createdAt, ok := h.reloader.Degraded(); ok {
+		fmt.Fprintf(w, "Degraded: rule sync failed, serving rules from snapshot taken at %s.\n", createdAt.Format(time.RFC3339))
+	}
+}
+
+func (h *Handler) reload(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("reload triggered via web")
+	if h.reloadConfig != nil {
+		if err := h.reloadConfig(); err != nil {
+			http.Error(w, "failed to reload config: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.reloader.Reload(r.Context()); err != nil {
+		http.Error(w, "failed to reload rules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("rules reloaded\n"))
+}
@@ -0,0 +1,281 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alertengine/config"
+	"alertengine/engine"
+	"alertengine/rule"
+	"alertengine/silence"
+
+	"github.com/prometheus/common/model"
+	"go.uber.org/zap"
+)
+
+// testMetrics 指标注册到默认注册表, 所有测试共用
+var testMetrics = engine.NewMetrics()
+
+// testPrometheus 模拟 Prometheus 即时查询接口, results 为查询语句到返回值的映射, 其他查询没有结果
+type testPrometheus struct {
+	mu      sync.Mutex
+	results map[string]string
+}
+
+func (p *testPrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	value, ok := p.results[r.FormValue("query")]
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		fmt.Fprint(w, `{"status": "success", "data": {"resultType": "vector", "result": []}}`)
+		return
+	}
+	fmt.Fprintf(w, `{"status": "success", "data": {"resultType": "vector", "result": [
+		{"metric": {"__name__": "up", "instance": "node-1:9100"}, "value": [%d, %q]}]}}`, time.Now().Unix(), value)
+}
+
+// setResult 设置查询的返回值, value 为空时查询没有结果
+func (p *testPrometheus) setResult(query, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.results == nil {
+		p.results = make(map[string]string)
+	}
+	if value == "" {
+		delete(p.results, query)
+		return
+	}
+	p.results[query] = value
+}
+
+// testGateway 模拟网关的通知接口, 记录收到的通知数量
+type testGateway struct {
+	mu            sync.Mutex
+	notifications int
+}
+
+func (g *testGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	g.mu.Lock()
+	g.notifications++
+	g.mu.Unlock()
+}
+
+func (g *testGateway) count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.notifications
+}
+
+// testEnv 使用本地规则文件运行的告警引擎, 规则文件中的数据源指向模拟的 Prometheus
+type testEnv struct {
+	t        *testing.T
+	cfg      *config.Config
+	ruleFile string
+	prom     *testPrometheus
+	promURL  string
+	gateway  *testGateway
+	reloader *engine.Reloader
+	storage  *rule.Storage
+	silences *silence.Store
+	mux      *http.ServeMux
+
+	// reloadConfig 为空时 /-/reload 只重载规则
+	reloadConfig func() error
+}
+
+const testRuleFile = `
+proms:
+  - id: 1
+    url: %s
+rules:
+  - id: 1
+    prom_id: 1
+    expr: up
+    op: "=="
+    value: "0"
+    labels:
+      severity: critical
+    summary: "{{ $labels.instance }} is down"
+`
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	dir := t.TempDir()
+	env := &testEnv{
+		t:        t,
+		ruleFile: filepath.Join(dir, "rules.yml"),
+		prom:     &testPrometheus{},
+		gateway:  &testGateway{},
+	}
+
+	promSrv := httptest.NewServer(env.prom)
+	t.Cleanup(promSrv.Close)
+	env.promURL = promSrv.URL
+	gatewaySrv := httptest.NewServer(env.gateway)
+	t.Cleanup(gatewaySrv.Close)
+
+	env.writeRules(fmt.Sprintf(testRuleFile, env.promURL))
+
+	watch := false
+	cfg := config.DefaultConfig()
+	cfg.AuthToken = "gateway-token"
+	cfg.Gateway.URL = gatewaySrv.URL
+	cfg.NotifyRetries = 1
+	cfg.EvaluationInterval = model.Duration(20 * time.Millisecond)
+	cfg.RuleSources = []config.RuleSourceConfig{{Type: config.SourceFile, Files: []string{env.ruleFile}, Watch: &watch}}
+	cfg.Storage.RuleDir = filepath.Join(dir, "data")
+	env.cfg = cfg
+
+	var err error
+	env.storage, err = rule.NewStorage(cfg.Storage.RuleDir, cfg.Storage.RetentionDays, cfg.Storage.EnableHistory, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.silences, err = silence.NewStore(filepath.Join(dir, "silences.json"), time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.reloader, err = engine.NewReloader(cfg, env.storage, env.silences, zap.NewNop(), testMetrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.reloader.Run()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		env.reloader.Loop()
+	}()
+	t.Cleanup(func() {
+		env.reloader.Stop()
+		<-done
+	})
+
+	h := New(cfg, env.reloader, func() error {
+		if env.reloadConfig == nil {
+			return nil
+		}
+		return env.reloadConfig()
+	}, NewAPI(env.reloader, env.storage, env.silences, zap.NewNop()), zap.NewNop())
+	env.mux = h.muxes()[cfg.ListenAddresses().API]
+
+	env.waitFor("manager started", func() bool { return env.reloader.GetManagerCount() == 1 })
+	return env
+}
+
+func (env *testEnv) writeRules(content string) {
+	env.t.Helper()
+	if err := os.WriteFile(env.ruleFile, []byte(content), 0644); err != nil {
+		env.t.Fatal(err)
+	}
+}
+
+// do 发送请求并返回响应
+func (env *testEnv) do(method, target, body string) *httptest.ResponseRecorder {
+	env.t.Helper()
+	rec := httptest.NewRecorder()
+	env.mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+// waitFor 等待条件成立, 超时后测试失败
+func (env *testEnv) waitFor(what string, cond func() bool) {
+	env.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			env.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReload(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 规则文件变化后未监听, 重载后才生效
+	env.writeRules(fmt.Sprintf(testRuleFile, env.promURL) + `
+  - id: 2
+    prom_id: 1
+    expr: node_load1
+`)
+	m, _ := env.reloader.Manager(1)
+	if n := len(m.Rules()); n != 1 {
+		t.Fatalf("got %d rules before reload, want 1", n)
+	}
+
+	var configReloads int
+	env.reloadConfig = func() error {
+		configReloads++
+		return nil
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		rec := env.do(method, "/-/reload", "")
+		if rec.Code != http.StatusOK || rec.Body.String() != "rules reloaded\n" {
+			t.Fatalf("%s: got %d %q", method, rec.Code, rec.Body)
+		}
+	}
+	if configReloads != 2 {
+		t.Errorf("config reloaded %d times, want 2", configReloads)
+	}
+	m, _ = env.reloader.Manager(1)
+	if n := len(m.Rules()); n != 2 {
+		t.Errorf("got %d rules after reload, want 2", n)
+	}
+
+	if rec := env.do(http.MethodGet, "/-/reload", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: got status %d, want 405", rec.Code)
+	}
+}
+
+func TestReloadErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	// 配置文件加载失败时不重载规则
+	env.reloadConfig = func() error { return errors.New("invalid config") }
+	env.writeRules("rules: [")
+	rec := env.do(http.MethodPost, "/-/reload", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "failed to reload config: invalid config") {
+		t.Errorf("got %d %q", rec.Code, rec.Body)
+	}
+
+	env.reloadConfig = nil
+	rec = env.do(http.MethodPost, "/-/reload", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "failed to reload rules: ") {
+		t.Errorf("got %d %q", rec.Code, rec.Body)
+	}
+	// 规则同步失败时保留已加载的规则
+	if m, ok := env.reloader.Manager(1); !ok || len(m.Rules()) != 1 {
+		t.Error("rules were not kept after a failed reload")
+	}
+
+	// 重载器停止后立即返回错误
+	env.reloader.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := env.reloader.Reload(ctx); err == nil {
+		t.Error("reload succeeded after the reloader was stopped")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	env := newTestEnv(t)
+	for _, path := range []string{"/-/healthy", "/-/ready", "/health", "/ready"} {
+		rec := env.do(http.MethodGet, path, "")
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "Degraded") {
+			t.Errorf("%s: got %d %q", path, rec.Code, rec.Body)
+		}
+	}
+}